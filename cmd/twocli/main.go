@@ -1,18 +1,39 @@
 package main
 
 import (
+	"flag"
+
 	"github.com/bykclk/twocli/internal/cli"
 	"github.com/bykclk/twocli/internal/commands"
 )
 
 func main() {
+	vaultPath := flag.String("vault", "", "Path of the vault file (default $TWOCLI_VAULT or the profile's vault)")
+	profile := flag.String("profile", "", "Profile whose vault to use (default from the configuration file, or \"default\")")
+
 	cmds := []cli.Command{
+		commands.NewInitCommand(),
 		commands.NewAddCommand(),
 		commands.NewListCommand(),
 		commands.NewCodeCommand(),
 		commands.NewDeleteCommand(),
+		commands.NewRestoreCommand(),
+		commands.NewTrashCommand(),
 		commands.NewUpdateCommand(),
+		commands.NewRenameCommand(),
+		commands.NewResyncCommand(),
+		commands.NewURICommand(),
+		commands.NewQRCommand(),
+		commands.NewVerifyCommand(),
+		commands.NewExportCommand(),
+		commands.NewImportCommand(),
+		commands.NewChangePasswordCommand(),
+		commands.NewRestoreBackupCommand(),
+		commands.NewFsckCommand(),
+		commands.NewProfileCommand(),
 	}
 
-	cli.Run(cmds)
+	cli.Run(cmds, func() error {
+		return commands.UseVault(*vaultPath, *profile)
+	})
 }
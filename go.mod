@@ -3,3 +3,5 @@ module github.com/bykclk/twocli
 go 1.23.3
 
 require golang.org/x/crypto v0.29.0
+
+require golang.org/x/sys v0.27.0
@@ -1,6 +1,8 @@
 package cli
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 )
@@ -11,19 +13,50 @@ type Command interface {
 	Run(args []string) error
 }
 
-func Run(commands []Command) {
-	if len(os.Args) < 2 {
+// ExitError is returned by a command that needs a specific exit status.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// Run parses the global options registered on flag.CommandLine, calls setup
+// if it is not nil, and runs the command named by the first remaining argument.
+func Run(commands []Command, setup func() error) {
+	flag.Usage = func() {
+		printUsage(commands)
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 {
 		fmt.Println("Please provide a command.")
 		printUsage(commands)
 		os.Exit(1)
 	}
 
-	cmdName := os.Args[1]
+	cmdName := flag.Arg(0)
 
 	for _, cmd := range commands {
 		if cmd.Name() == cmdName {
-			if err := cmd.Run(os.Args[2:]); err != nil {
+			if setup != nil {
+				if err := setup(); err != nil {
+					fmt.Printf("Error: %v\n", err)
+					os.Exit(1)
+				}
+			}
+			if err := cmd.Run(flag.Args()[1:]); err != nil {
 				fmt.Printf("Error: %v\n", err)
+				var exitErr *ExitError
+				if errors.As(err, &exitErr) {
+					os.Exit(exitErr.Code)
+				}
 				os.Exit(1)
 			}
 			return
@@ -36,8 +69,15 @@ func Run(commands []Command) {
 }
 
 func printUsage(commands []Command) {
-	fmt.Println("Usage:")
+	fmt.Println("Usage: twocli [global options] <command> [options]")
+	fmt.Println()
+	fmt.Println("Commands:")
 	for _, cmd := range commands {
 		fmt.Printf("  %s - %s\n", cmd.Name(), cmd.Description())
 	}
+
+	fmt.Println()
+	fmt.Println("Global options:")
+	flag.CommandLine.SetOutput(os.Stdout)
+	flag.PrintDefaults()
 }
@@ -4,7 +4,9 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"strings"
 
+	"github.com/bykclk/twocli/internal/qr"
 	"github.com/bykclk/twocli/internal/storage"
 	"github.com/bykclk/twocli/internal/totp"
 )
@@ -27,32 +29,189 @@ func (c *AddCommand) Run(args []string) error {
 	fs := flag.NewFlagSet("add", flag.ContinueOnError)
 	name := fs.String("name", "", "Account name")
 	secret := fs.String("secret", "", "Account secret key (base32 encoded)")
+	uri := fs.String("uri", "", "otpauth:// URI to import the account from")
+	qrFile := fs.String("qr", "", "PNG, JPEG or GIF image with QR codes to import accounts from")
+	pf := addParamFlags(fs)
+	otpType := fs.String("type", totp.TypeTOTP, "Account type (totp or hotp)")
+	counter := fs.Uint64("counter", 0, "Initial counter for hotp accounts")
+	mf := addMetadataFlags(fs)
 
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
 
-	if *name == "" || *secret == "" {
-		fs.Usage()
-		return errors.New("both -name and -secret are required")
+	if *qrFile != "" {
+		if *secret != "" || *uri != "" {
+			fs.Usage()
+			return errors.New("-qr cannot be combined with -secret or -uri")
+		}
+		if f := firstFlagSet(fs, keyParamFlags...); f != "" {
+			fs.Usage()
+			return fmt.Errorf("-%s cannot be combined with -qr, as the QR codes set it", f)
+		}
+		return addFromQR(*qrFile, *name, mf)
 	}
 
-	if err := totp.ValidateSecret(*secret); err != nil {
-		return fmt.Errorf("invalid secret key: %v", err)
+	var key *totp.Key
+	if *uri != "" {
+		if *secret != "" {
+			fs.Usage()
+			return errors.New("-secret cannot be combined with -uri")
+		}
+		if f := firstFlagSet(fs, keyParamFlags...); f != "" {
+			fs.Usage()
+			return fmt.Errorf("-%s cannot be combined with -uri, as the URI sets it", f)
+		}
+
+		var err error
+		if key, err = totp.ParseURI(*uri); err != nil {
+			return err
+		}
+		if *name == "" {
+			*name = defaultAccountName(key)
+		}
+	} else {
+		if *name == "" || *secret == "" {
+			fs.Usage()
+			return errors.New("both -name and -secret are required, or -uri")
+		}
+
+		if err := totp.ValidateSecret(*secret); err != nil {
+			return fmt.Errorf("invalid secret key: %v", err)
+		}
+
+		params, err := pf.params()
+		if err != nil {
+			return err
+		}
+
+		if *otpType != totp.TypeTOTP && *otpType != totp.TypeHOTP {
+			return fmt.Errorf("invalid account type: %s", *otpType)
+		}
+
+		key = &totp.Key{
+			Type:    *otpType,
+			Secret:  *secret,
+			Params:  params,
+			Counter: *counter,
+		}
 	}
 
-	masterPassword, err := promptForMasterPassword()
+	store, err := openStoreWithAttempts()
 	if err != nil {
 		return err
 	}
+	defer store.Close()
 
-	if err = storage.AddAccount(*name, *secret, masterPassword); err != nil {
-		if err.Error() == "incorrect master password" {
-			fmt.Println("Incorrect master password.")
+	if err = addKey(store, *name, key, mf); err != nil {
+		return err
+	}
+
+	fmt.Printf("Account '%s' added successfully.\n", *name)
+	return nil
+}
+
+// keyParamFlags are the add flags for values an otpauth URI sets itself.
+var keyParamFlags = []string{"type", "algorithm", "digits", "period", "counter"}
+
+// defaultAccountName names an imported account after its issuer, or its
+// account name when the key has no issuer.
+func defaultAccountName(key *totp.Key) string {
+	if key.Issuer != "" {
+		return key.Issuer
+	}
+	return key.AccountName
+}
+
+// addKey stores a key as a new account with the given name. The issuer and
+// label come from the key unless they were given as flags.
+func addKey(store storage.Store, name string, key *totp.Key, mf *metadataFlags) error {
+	account := keyAccount(name, key)
+	mf.apply(&account)
+	return storage.AddAccount(store, account)
+}
+
+// keyAccount builds an account with the given name from a key.
+func keyAccount(name string, key *totp.Key) storage.Account {
+	account := storage.Account{
+		Name:   name,
+		Secret: key.Secret,
+		Issuer: key.Issuer,
+		Label:  key.AccountName,
+	}
+	if key.Type == totp.TypeHOTP {
+		account.Type = totp.TypeHOTP
+		account.Counter = key.Counter
+	}
+	account.SetParams(key.Params)
+	return account
+}
+
+// keyNames names accounts for keys after their issuer, or their full label
+// when several keys share an issuer.
+func keyNames(keys []*totp.Key) []string {
+	seen := map[string]int{}
+	for _, key := range keys {
+		seen[strings.ToLower(defaultAccountName(key))]++
+	}
+	names := make([]string, len(keys))
+	for i, key := range keys {
+		if seen[strings.ToLower(defaultAccountName(key))] > 1 {
+			names[i] = key.Label()
+		} else {
+			names[i] = defaultAccountName(key)
+		}
+	}
+	return names
+}
+
+// addFromQR adds an account for every otpauth QR code found in an image.
+func addFromQR(path, name string, mf *metadataFlags) error {
+	texts, err := qr.DecodeFile(path)
+	if err != nil {
+		return err
+	}
+
+	var keys []*totp.Key
+	for i, text := range texts {
+		key, err := totp.ParseURI(text)
+		if err != nil {
+			fmt.Printf("Skipping QR code %d: %v\n", i+1, err)
+			continue
 		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return errors.New("no otpauth URI found in the QR codes")
+	}
+	if name != "" && len(keys) > 1 {
+		return fmt.Errorf("-name cannot be used when the image contains %d accounts", len(keys))
+	}
+
+	names := keyNames(keys)
+	if name != "" {
+		names[0] = name
+	}
+
+	accounts := make([]storage.Account, len(keys))
+	for i, key := range keys {
+		accounts[i] = keyAccount(names[i], key)
+		mf.apply(&accounts[i])
+	}
+
+	store, err := openStoreWithAttempts()
+	if err != nil {
 		return err
 	}
+	defer store.Close()
+
+	// Add every account or none, so a failure never leaves part of the image added
+	if err = storage.AddAccounts(store, accounts); err != nil {
+		return fmt.Errorf("no accounts were added: %v", err)
+	}
+	for _, name := range names {
+		fmt.Printf("Account '%s' added successfully.\n", name)
+	}
 
-	fmt.Println("Account added successfully.")
 	return nil
 }
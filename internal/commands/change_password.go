@@ -0,0 +1,39 @@
+package commands
+
+import (
+	"fmt"
+)
+
+type ChangePasswordCommand struct{}
+
+func NewChangePasswordCommand() *ChangePasswordCommand {
+	return &ChangePasswordCommand{}
+}
+
+func (c *ChangePasswordCommand) Name() string {
+	return "change-password"
+}
+
+func (c *ChangePasswordCommand) Description() string {
+	return "Change the master password and re-encrypt the vault"
+}
+
+func (c *ChangePasswordCommand) Run(_ []string) error {
+	store, err := openStoreWithAttempts()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	newPassword, err := promptNewPassword()
+	if err != nil {
+		return err
+	}
+
+	if err = store.Rekey(newPassword, store.KDF()); err != nil {
+		return err
+	}
+
+	fmt.Println("Master password changed successfully.")
+	return nil
+}
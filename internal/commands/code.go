@@ -29,11 +29,13 @@ const (
 	emptyBlock   = "·"
 )
 
-func getProgressColor(remaining int64) string {
+// getProgressColor picks the color for the remaining time, relative to the
+// period: green for more than half, yellow for more than a sixth, red otherwise.
+func getProgressColor(remaining, total int64) string {
 	switch {
-	case remaining > 15:
+	case remaining*2 > total:
 		return colorGreen
-	case remaining > 5:
+	case remaining*6 > total:
 		return colorYellow
 	default:
 		return colorRed
@@ -43,7 +45,7 @@ func getProgressColor(remaining int64) string {
 func generateProgressBar(remaining, total int64) string {
 	width := 20 // Progress bar width
 	filled := int(float64(remaining) / float64(total) * float64(width))
-	color := getProgressColor(remaining)
+	color := getProgressColor(remaining, total)
 
 	// Build progress bar
 	progress := leftBracket
@@ -70,20 +72,20 @@ func (c *CodeCommand) Name() string {
 }
 
 func (c *CodeCommand) Description() string {
-	return "Generate TOTP code for an account"
+	return "Generate TOTP or HOTP code for an account"
 }
 
-func generateAndDisplayCode(name, secret string, quit chan struct{}) error {
-	totpInfo, err := totp.GenerateCode(secret)
+func generateAndDisplayCode(name, secret string, params totp.Params, quit chan struct{}) error {
+	totpInfo, err := totp.GenerateCodeWithParams(secret, params)
 	if err != nil {
 		return err
 	}
 
 	// Clear the line and move cursor to beginning
 	fmt.Print("\033[2K\r")
-	fmt.Printf("%sYour TOTP code for '%s' is:%s %s%06d%s",
+	fmt.Printf("%sYour TOTP code for '%s' is:%s %s%s%s",
 		colorCyan, name, colorReset,
-		colorGreen, totpInfo.Code, colorReset)
+		colorGreen, totpInfo, colorReset)
 
 	// Display countdown
 	remaining := totpInfo.RemainingSeconds
@@ -96,14 +98,14 @@ func generateAndDisplayCode(name, secret string, quit chan struct{}) error {
 			fmt.Println("\nExiting...")
 			return nil
 		case <-ticker.C:
-			progressBar := generateProgressBar(remaining, 30)
-			timeColor := getProgressColor(remaining)
+			progressBar := generateProgressBar(remaining, totpInfo.Period)
+			timeColor := getProgressColor(remaining, totpInfo.Period)
 
 			// Clear the line and move cursor to beginning
 			fmt.Printf("\033[2K\r")
-			fmt.Printf("%sYour TOTP code for '%s' is:%s %s%06d%s %s %s%ds%s",
+			fmt.Printf("%sYour TOTP code for '%s' is:%s %s%s%s %s %s%ds%s",
 				colorCyan, name, colorReset,
-				colorGreen, totpInfo.Code, colorReset,
+				colorGreen, totpInfo, colorReset,
 				progressBar,
 				timeColor, remaining, colorReset)
 
@@ -113,6 +115,20 @@ func generateAndDisplayCode(name, secret string, quit chan struct{}) error {
 	return nil
 }
 
+// displayHOTPCode generates the next counter-based code and advances the stored counter.
+func displayHOTPCode(store storage.Store, acc storage.Account) error {
+	code, counter, err := storage.NextHOTPCode(store, acc.Name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%sYour HOTP code for '%s' is:%s %s%s%s (counter %d)\n",
+		colorCyan, acc.Name, colorReset,
+		colorGreen, totp.FormatCode(code, acc.Params().Digits), colorReset,
+		counter)
+	return nil
+}
+
 func (c *CodeCommand) Run(args []string) error {
 	fs := flag.NewFlagSet("code", flag.ContinueOnError)
 	name := fs.String("name", "", "Account name")
@@ -127,20 +143,33 @@ func (c *CodeCommand) Run(args []string) error {
 		return errors.New("-name is required")
 	}
 
-	_, masterPassword, err := loadAccountsWithAttempts()
+	store, err := openStoreWithAttempts()
 	if err != nil {
 		return err
 	}
+	defer store.Close()
 
-	secret, err := storage.GetAccountSecret(*name, masterPassword)
+	acc, err := store.Get(*name)
 	if err != nil {
 		return err
 	}
 
+	if acc.IsHOTP() {
+		return displayHOTPCode(store, acc)
+	}
+
+	secret := acc.Secret
 	if err = totp.ValidateSecret(secret); err != nil {
 		return fmt.Errorf("invalid secret key for account '%s': %v", *name, err)
 	}
 
+	if err = storage.MarkUsed(store, acc.Name); err != nil {
+		return err
+	}
+
+	// Let other twocli processes use the vault while codes are displayed
+	store.Close()
+
 	// Setup signal handling for graceful exit
 	quit := make(chan struct{})
 	sigChan := make(chan os.Signal, 1)
@@ -154,7 +183,7 @@ func (c *CodeCommand) Run(args []string) error {
 	fmt.Println("Press Ctrl+C to exit")
 
 	for {
-		if err := generateAndDisplayCode(*name, secret, quit); err != nil {
+		if err := generateAndDisplayCode(*name, secret, acc.Params(), quit); err != nil {
 			return err
 		}
 
@@ -4,8 +4,6 @@ import (
 	"errors"
 	"flag"
 	"fmt"
-
-	"github.com/bykclk/twocli/internal/storage"
 )
 
 type DeleteCommand struct{}
@@ -35,12 +33,7 @@ func (c *DeleteCommand) Run(args []string) error {
 		return errors.New("-name is required")
 	}
 
-	_, masterPassword, err := loadAccountsWithAttempts()
-	if err != nil {
-		return err
-	}
-
-	// Confirm deletion
+	// Confirm deletion before opening the vault, so it is not locked while waiting
 	confirmed, err := confirmAction(fmt.Sprintf("Are you sure you want to delete the account '%s'? (yes/no): ", *name))
 	if err != nil {
 		return err
@@ -50,10 +43,21 @@ func (c *DeleteCommand) Run(args []string) error {
 		return nil
 	}
 
-	if err = storage.DeleteAccount(*name, masterPassword); err != nil {
+	store, err := openStoreWithAttempts()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	// The account may be given by an alias, but the trash keeps its name
+	acc, err := store.Get(*name)
+	if err != nil {
+		return err
+	}
+	if err = store.Delete(acc.Name); err != nil {
 		return err
 	}
 
-	fmt.Printf("Account '%s' deleted successfully.\n", *name)
+	fmt.Printf("Account '%s' moved to the trash. Restore it with: twocli restore -name %q\n", acc.Name, acc.Name)
 	return nil
 }
@@ -0,0 +1,308 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bykclk/twocli/internal/crypto"
+	"github.com/bykclk/twocli/internal/qr"
+	"github.com/bykclk/twocli/internal/storage"
+	"github.com/bykclk/twocli/internal/totp"
+	"github.com/bykclk/twocli/internal/transfer"
+)
+
+type ExportCommand struct{}
+
+func NewExportCommand() *ExportCommand {
+	return &ExportCommand{}
+}
+
+func (c *ExportCommand) Name() string {
+	return "export"
+}
+
+func (c *ExportCommand) Description() string {
+	return "Export accounts to a file, or as Google Authenticator transfer QR codes"
+}
+
+func (c *ExportCommand) Run(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", formatTwocli, "Format to export (twocli, aegis or google-migration)")
+	file := fs.String("file", "", "File to write the export to, for twocli and aegis")
+	plaintext := fs.Bool("plaintext", false, "Write the export unencrypted, for twocli and aegis")
+	var names, tags tagFlags
+	fs.Var(&names, "name", "Only export this account (may be repeated)")
+	issuer := fs.String("issuer", "", "Only export accounts with this issuer")
+	label := fs.String("label", "", "Only export accounts with this label")
+	fs.Var(&tags, "tag", "Only export accounts with this tag (may be repeated)")
+	batchSize := fs.Int("batch-size", 5, "Accounts per QR code, for google-migration")
+	pngFile := fs.String("png", "", "Write the QR codes to PNG files named after this one instead of showing them, for google-migration")
+	scale := fs.Int("scale", 8, "Pixels per module in the PNG files")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	switch *format {
+	case formatTwocli, formatAegis:
+		if *file == "" {
+			fs.Usage()
+			return errors.New("-file is required")
+		}
+	case formatGoogleMigration:
+		if *file != "" || *plaintext {
+			return errors.New("-file and -plaintext cannot be used with -format google-migration")
+		}
+		if *batchSize < 1 {
+			return errors.New("-batch-size must be at least 1")
+		}
+		if *scale < 1 {
+			return errors.New("-scale must be at least 1")
+		}
+	default:
+		return fmt.Errorf("unknown export format: %s", *format)
+	}
+
+	store, err := openStoreWithAttempts()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	accounts, err := selectAccounts(store, names, *issuer, *label, tags)
+	if err != nil {
+		return err
+	}
+	if len(accounts) == 0 {
+		return errors.New("no accounts to export")
+	}
+
+	// Let other twocli processes use the vault during the prompts that follow
+	store.Close()
+
+	if *format == formatGoogleMigration {
+		return exportGoogleMigration(accounts, *batchSize, *pngFile, *scale)
+	}
+	return exportFile(accounts, *format, *file, *plaintext)
+}
+
+// selectAccounts returns the named accounts, or every account matching the
+// filters if no names are given.
+func selectAccounts(store storage.Store, names []string, issuer, label string, tags []string) ([]storage.Account, error) {
+	if len(names) > 0 {
+		if issuer != "" || label != "" || len(tags) > 0 {
+			return nil, errors.New("-name cannot be combined with -issuer, -label or -tag")
+		}
+		accounts := make([]storage.Account, len(names))
+		for i, name := range names {
+			acc, err := store.Get(name)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			accounts[i] = acc
+		}
+		return accounts, nil
+	}
+
+	accounts, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	// Listing leaves out the secrets and notes, which are decrypted for the selected accounts only
+	var selected []storage.Account
+	for _, acc := range accounts {
+		if !matchesFilters(acc, issuer, label, tags) {
+			continue
+		}
+		full, err := store.Get(acc.Name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", acc.Name, err)
+		}
+		selected = append(selected, full)
+	}
+	return selected, nil
+}
+
+// exportFile writes the accounts to a twocli export or an Aegis backup,
+// encrypted with an export passphrase unless plaintext is set.
+func exportFile(accounts []storage.Account, format, file string, plaintext bool) error {
+	var passphrase string
+	if plaintext {
+		// Anyone who can read the file can generate codes for every account
+		confirmed, err := confirmAction(fmt.Sprintf("The export will contain every secret unencrypted. Write %d accounts to %s? (yes/no): ", len(accounts), file))
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Println("Export cancelled.")
+			return nil
+		}
+	} else {
+		var err error
+		if passphrase, err = promptNewPassphrase(); err != nil {
+			return err
+		}
+	}
+
+	data, err := marshalExport(accounts, format, passphrase)
+	if err != nil {
+		return err
+	}
+	if err = writeNewFile(file, data); err != nil {
+		return err
+	}
+
+	fmt.Printf("Exported %d accounts to %s.\n", len(accounts), file)
+	return nil
+}
+
+// marshalExport encodes the accounts in a file format, encrypted with the
+// passphrase unless it is empty.
+func marshalExport(accounts []storage.Account, format, passphrase string) ([]byte, error) {
+	if format == formatAegis {
+		entries := make([]transfer.Entry, len(accounts))
+		for i, acc := range accounts {
+			entries[i] = transfer.Entry{Key: keyFromAccount(acc), Tags: acc.Tags, Notes: acc.Notes}
+		}
+		if passphrase == "" {
+			return transfer.MarshalAegis(entries)
+		}
+		return transfer.MarshalAegisEncrypted(entries, passphrase)
+	}
+
+	data, err := transfer.MarshalTwocli(accounts)
+	if err != nil || passphrase == "" {
+		return data, err
+	}
+	return transfer.EncryptTwocli(data, passphrase, crypto.DefaultKDFParams())
+}
+
+// exportGoogleMigration shows the accounts as numbered otpauth-migration QR
+// codes for Google Authenticator to scan, one at a time, or writes them to
+// PNG files. Accounts Google Authenticator cannot use are left out.
+func exportGoogleMigration(accounts []storage.Account, batchSize int, pngFile string, scale int) error {
+	var keys []*totp.Key
+	for _, acc := range accounts {
+		key := keyFromAccount(acc)
+		if reason := transfer.GoogleUnsupported(key); reason != "" {
+			fmt.Printf("Skipping '%s': %s\n", acc.Name, reason)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return errors.New("none of the accounts can be used by Google Authenticator")
+	}
+
+	uris, err := transfer.MigrationURIs(keys, batchSize)
+	if err != nil {
+		return err
+	}
+
+	// Fail before writing any batch rather than leave a partial set of files
+	if pngFile != "" {
+		for i := range uris {
+			path := batchFileName(pngFile, i+1, len(uris))
+			if _, err = os.Lstat(path); err == nil {
+				return fmt.Errorf("%s already exists", path)
+			}
+		}
+	}
+
+	// The QR codes contain the secrets, so anyone who sees them can generate codes
+	confirmed, err := confirmAction(fmt.Sprintf("The QR codes reveal the secrets of %d accounts. Continue? (yes/no): ", len(keys)))
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		fmt.Println("Export cancelled.")
+		return nil
+	}
+
+	for i, uri := range uris {
+		code, err := qr.Encode(uri, qr.M)
+		if err != nil {
+			return err
+		}
+
+		if pngFile != "" {
+			path := batchFileName(pngFile, i+1, len(uris))
+			data, err := encodePNG(code, scale)
+			if err != nil {
+				return err
+			}
+			if err = writeNewFile(path, data); err != nil {
+				return err
+			}
+			fmt.Printf("Batch %d of %d written to %s\n", i+1, len(uris), path)
+			continue
+		}
+
+		fmt.Printf("Batch %d of %d:\n", i+1, len(uris))
+		fmt.Print(code.Terminal(qr.QuietZone))
+		if i < len(uris)-1 {
+			fmt.Print("Scan the code, then press Enter for the next batch.")
+			if _, err = stdin.ReadString('\n'); err != nil {
+				return err
+			}
+		}
+	}
+
+	fmt.Printf("Exported %d accounts in %d batches.\n", len(keys), len(uris))
+	return nil
+}
+
+// batchFileName numbers a file name with the batch index and total, such as
+// transfer-1-of-3.png for transfer.png.
+func batchFileName(path string, index, total int) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s-%d-of-%d%s", strings.TrimSuffix(path, ext), index, total, ext)
+}
+
+// promptNewPassphrase asks for a new export passphrase twice, checks that
+// both entries match and that the passphrase is strong enough.
+func promptNewPassphrase() (string, error) {
+	passphrase, err := promptPassword("Enter export passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", errors.New("export passphrase cannot be empty")
+	}
+	if entropy := crypto.PasswordEntropy(passphrase); entropy < crypto.MinPasswordEntropy {
+		return "", fmt.Errorf("export passphrase is too weak (about %.0f bits, at least %d required); use a longer passphrase of several words",
+			entropy, crypto.MinPasswordEntropy)
+	}
+
+	confirmation, err := promptPassword("Confirm export passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if confirmation != passphrase {
+		return "", errors.New("passphrases do not match")
+	}
+
+	return passphrase, nil
+}
+
+// writeNewFile writes data to a file only the user can read, failing if the
+// file exists so an export never replaces another file.
+func writeNewFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("%s already exists", path)
+		}
+		return err
+	}
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
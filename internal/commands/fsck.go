@@ -0,0 +1,73 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/bykclk/twocli/internal/storage"
+)
+
+type FsckCommand struct{}
+
+func NewFsckCommand() *FsckCommand {
+	return &FsckCommand{}
+}
+
+func (c *FsckCommand) Name() string {
+	return "fsck"
+}
+
+func (c *FsckCommand) Description() string {
+	return "Check every account in the vault, and optionally repair problems"
+}
+
+func (c *FsckCommand) Run(args []string) error {
+	fs := flag.NewFlagSet("fsck", flag.ContinueOnError)
+	repair := fs.Bool("repair", false, "Quarantine accounts that cannot be used and rename hidden duplicates")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	store, err := openStoreWithAttempts()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	report, err := storage.Check(store, *repair)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Checked %d accounts and %d trashed accounts.\n", report.Accounts, report.Trashed)
+
+	unrepaired := 0
+	for _, p := range report.Problems {
+		name := p.Account
+		if p.Trashed {
+			name += " (in the trash)"
+		}
+
+		switch {
+		case p.Repaired:
+			fmt.Printf("- %s: %s, repaired: %s\n", name, p.Message, p.Repair)
+		case p.Repair != "":
+			fmt.Printf("- %s: %s, -repair will %s\n", name, p.Message, p.Repair)
+			unrepaired++
+		default:
+			fmt.Printf("- %s: %s\n", name, p.Message)
+		}
+	}
+	if len(report.Problems) == 0 {
+		fmt.Println("No problems found.")
+	}
+	if report.Quarantined > 0 {
+		fmt.Printf("%d records are in the quarantine.\n", report.Quarantined)
+	}
+
+	if unrepaired > 0 {
+		return fmt.Errorf("found %d problems that need repair, run twocli fsck -repair to fix them", unrepaired)
+	}
+	return nil
+}
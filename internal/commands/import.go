@@ -0,0 +1,256 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bykclk/twocli/internal/qr"
+	"github.com/bykclk/twocli/internal/storage"
+	"github.com/bykclk/twocli/internal/totp"
+	"github.com/bykclk/twocli/internal/transfer"
+)
+
+// Import and export formats
+const (
+	formatTwocli          = "twocli"
+	formatGoogleMigration = "google-migration"
+	formatAegis           = "aegis"
+	format2FAS            = "2fas"
+	formatEnte            = "ente"
+)
+
+// backupFormat reads the backup files of another authenticator.
+type backupFormat struct {
+	name      string
+	encrypted func(data []byte) bool
+	read      func(data []byte, password string) (*transfer.Backup, error)
+}
+
+var backupFormats = map[string]backupFormat{
+	formatAegis: {"Aegis backup", transfer.IsAegisEncrypted, transfer.ReadAegis},
+	format2FAS:  {"2FAS backup", transfer.Is2FASEncrypted, transfer.Read2FAS},
+	formatEnte:  {"Ente Auth export", transfer.IsEnteEncrypted, transfer.ReadEnte},
+}
+
+type ImportCommand struct{}
+
+func NewImportCommand() *ImportCommand {
+	return &ImportCommand{}
+}
+
+func (c *ImportCommand) Name() string {
+	return "import"
+}
+
+func (c *ImportCommand) Description() string {
+	return "Import accounts from an export file, another authenticator's backup or a Google Authenticator transfer"
+}
+
+func (c *ImportCommand) Run(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := fs.String("format", formatTwocli, "Format to import (twocli, aegis, 2fas, ente or google-migration)")
+	file := fs.String("file", "", "File to import; for google-migration, a text file with one otpauth-migration URI per line")
+	var uris, qrFiles tagFlags
+	fs.Var(&uris, "uri", "otpauth-migration URI to import, for google-migration (may be repeated)")
+	fs.Var(&qrFiles, "qr", "Image with otpauth-migration QR codes to import, for google-migration (may be repeated)")
+	onConflict := fs.String("on-conflict", string(storage.ConflictSkip), "What to do with an account whose name is taken (skip, rename or overwrite)")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	policy, err := storage.ParseConflictPolicy(*onConflict)
+	if err != nil {
+		return err
+	}
+
+	if *format == formatGoogleMigration {
+		if *file == "" && len(uris) == 0 && len(qrFiles) == 0 {
+			fs.Usage()
+			return errors.New("-file, -uri or -qr is required")
+		}
+	} else {
+		if *file == "" {
+			fs.Usage()
+			return errors.New("-file is required")
+		}
+		if len(uris) > 0 || len(qrFiles) > 0 {
+			return errors.New("-uri and -qr can only be used with -format google-migration")
+		}
+	}
+
+	// Read the input before unlocking the vault, so a bad file fails early
+	var accounts []storage.Account
+	var backup *transfer.Backup
+	switch *format {
+	case formatTwocli:
+		accounts, err = readTwocliExport(*file)
+	case formatGoogleMigration:
+		backup, err = readGoogleMigration(*file, uris, qrFiles)
+	default:
+		bf, ok := backupFormats[*format]
+		if !ok {
+			return fmt.Errorf("unknown import format: %s", *format)
+		}
+		backup, err = readBackup(bf, *file)
+	}
+	if err != nil {
+		return err
+	}
+
+	var unsupported []string
+	if backup != nil {
+		accounts, unsupported = backupAccounts(backup), backup.Skipped
+	}
+	if len(accounts) == 0 {
+		printImportSummary(nil, unsupported)
+		return nil
+	}
+
+	store, err := openStoreWithAttempts()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	results, err := storage.Import(store, accounts, policy)
+	if err != nil {
+		return err
+	}
+	printImportSummary(results, unsupported)
+	return nil
+}
+
+// readTwocliExport reads the accounts from a twocli export file, asking for
+// the export passphrase if it is encrypted.
+func readTwocliExport(path string) ([]storage.Account, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var passphrase string
+	if transfer.IsEncrypted(data) {
+		if passphrase, err = promptPassword("Enter export passphrase: "); err != nil {
+			return nil, err
+		}
+	}
+	return transfer.ReadTwocli(data, passphrase)
+}
+
+// readGoogleMigration reads the accounts from Google Authenticator
+// otpauth-migration URIs, given in a text file, directly or as QR codes in
+// images. Missing batches are reported.
+func readGoogleMigration(path string, uris, qrFiles []string) (*transfer.Backup, error) {
+	uris = append([]string(nil), uris...)
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				uris = append(uris, line)
+			}
+		}
+	}
+	for _, qrFile := range qrFiles {
+		texts, err := qr.DecodeFile(qrFile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", qrFile, err)
+		}
+		uris = append(uris, texts...)
+	}
+
+	// The same batch may be scanned twice, such as from overlapping screenshots
+	var batches []*transfer.MigrationBatch
+	backup := &transfer.Backup{}
+	seen := map[string]bool{}
+	for _, uri := range uris {
+		if seen[uri] {
+			continue
+		}
+		seen[uri] = true
+
+		batch, err := transfer.ParseMigrationURI(uri)
+		if err != nil {
+			return nil, err
+		}
+		batches = append(batches, batch)
+		for _, key := range batch.Keys {
+			backup.Entries = append(backup.Entries, transfer.Entry{Key: key})
+		}
+		backup.Skipped = append(backup.Skipped, batch.Skipped...)
+	}
+
+	for _, missing := range transfer.MissingBatches(batches) {
+		fmt.Printf("Warning: missing %s of the export, whose accounts are not imported.\n", missing)
+	}
+
+	return backup, nil
+}
+
+// readBackup reads another authenticator's backup file, asking for its
+// password if it is encrypted.
+func readBackup(bf backupFormat, path string) (*transfer.Backup, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var password string
+	if bf.encrypted(data) {
+		if password, err = promptPassword(fmt.Sprintf("Enter %s password: ", bf.name)); err != nil {
+			return nil, err
+		}
+	}
+	return bf.read(data, password)
+}
+
+// backupAccounts converts the entries of another authenticator's backup to
+// accounts, named like accounts added from QR codes.
+func backupAccounts(backup *transfer.Backup) []storage.Account {
+	keys := make([]*totp.Key, len(backup.Entries))
+	for i, entry := range backup.Entries {
+		keys[i] = entry.Key
+	}
+	names := keyNames(keys)
+
+	accounts := make([]storage.Account, len(backup.Entries))
+	for i, entry := range backup.Entries {
+		accounts[i] = keyAccount(names[i], entry.Key)
+		accounts[i].Tags = entry.Tags
+		accounts[i].Notes = entry.Notes
+	}
+	return accounts
+}
+
+// printImportSummary reports what happened to each imported account, and
+// sums up how many were imported, skipped because their name is taken, or
+// not imported because twocli cannot use them.
+func printImportSummary(results []storage.ImportResult, unsupported []string) {
+	imported, skipped := 0, 0
+	for _, r := range results {
+		switch r.Result {
+		case storage.ImportAdded:
+			fmt.Printf("Account '%s' added.\n", r.Name)
+		case storage.ImportRenamed:
+			fmt.Printf("Account '%s' added as '%s', as the name is taken.\n", r.Name, r.StoredAs)
+		case storage.ImportOverwritten:
+			fmt.Printf("Account '%s' overwritten.\n", r.Name)
+		case storage.ImportSkipped:
+			fmt.Printf("Account '%s' skipped, as the name is taken.\n", r.Name)
+			skipped++
+			continue
+		}
+		imported++
+	}
+	for _, u := range unsupported {
+		fmt.Printf("Not imported: %s\n", u)
+	}
+
+	fmt.Printf("Summary: %d imported, %d skipped as the name is taken, %d unsupported.\n", imported, skipped, len(unsupported))
+}
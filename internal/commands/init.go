@@ -0,0 +1,41 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+)
+
+type InitCommand struct{}
+
+func NewInitCommand() *InitCommand {
+	return &InitCommand{}
+}
+
+func (c *InitCommand) Name() string {
+	return "init"
+}
+
+func (c *InitCommand) Description() string {
+	return "Create a new vault protected by a master password"
+}
+
+func (c *InitCommand) Run(args []string) error {
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	kdf := addKDFFlags(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	params, err := kdf.params()
+	if err != nil {
+		return err
+	}
+
+	if err = createStore(newStore(), params); err != nil {
+		return err
+	}
+
+	fmt.Println("Vault created successfully.")
+	return nil
+}
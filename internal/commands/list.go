@@ -1,7 +1,12 @@
 package commands
 
 import (
+	"flag"
 	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bykclk/twocli/internal/storage"
 )
 
 type ListCommand struct{}
@@ -15,24 +20,127 @@ func (c *ListCommand) Name() string {
 }
 
 func (c *ListCommand) Description() string {
-	return "List all saved accounts"
+	return "List saved accounts, optionally filtered by issuer, label or tag"
 }
 
-func (c *ListCommand) Run(_ []string) error {
-	accounts, _, err := loadAccountsWithAttempts()
+// tagFlags collects the values of a flag that may be given several times.
+type tagFlags []string
+
+func (t *tagFlags) String() string {
+	return strings.Join(*t, ",")
+}
+
+func (t *tagFlags) Set(value string) error {
+	*t = append(*t, value)
+	return nil
+}
+
+func (c *ListCommand) Run(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	long := fs.Bool("long", false, "Show the issuer, label, tags and timestamps of each account")
+	notes := fs.Bool("notes", false, "Show the notes of each account")
+	issuer := fs.String("issuer", "", "Only list accounts with this issuer")
+	label := fs.String("label", "", "Only list accounts with this label")
+	var tags tagFlags
+	fs.Var(&tags, "tag", "Only list accounts with this tag (may be repeated)")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	store, err := openStoreWithAttempts()
 	if err != nil {
 		return err
 	}
+	defer store.Close()
 
-	if len(accounts) == 0 {
-		fmt.Println("No accounts found.")
+	accounts, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	if activeProfile != "" {
+		fmt.Printf("Profile: %s\n", activeProfile)
+	} else {
+		fmt.Printf("Vault: %s\n", storage.Path())
+	}
+
+	filtered := accounts[:0]
+	for _, acc := range accounts {
+		if matchesFilters(acc, *issuer, *label, tags) {
+			filtered = append(filtered, acc)
+		}
+	}
+	if len(filtered) == 0 {
+		if len(accounts) == 0 {
+			fmt.Println("No accounts found.")
+		} else {
+			fmt.Println("No accounts match the filters.")
+		}
 		return nil
 	}
 
 	fmt.Println("Saved accounts:")
-	for _, acc := range accounts {
-		fmt.Printf("- %s\n", acc.Name)
+	for _, acc := range filtered {
+		if len(acc.Aliases) > 0 {
+			fmt.Printf("- %s (%s)\n", acc.Name, strings.Join(acc.Aliases, ", "))
+		} else {
+			fmt.Printf("- %s\n", acc.Name)
+		}
+		if *long {
+			printAccountDetails(acc)
+		}
+		if *notes {
+			printAccountNotes(store, acc.Name)
+		}
 	}
 
 	return nil
 }
+
+// matchesFilters reports whether the account has the issuer and label, if
+// given, and every tag, all compared case-insensitively.
+func matchesFilters(acc storage.Account, issuer, label string, tags []string) bool {
+	if issuer != "" && !strings.EqualFold(acc.Issuer, issuer) {
+		return false
+	}
+	if label != "" && !strings.EqualFold(acc.Label, label) {
+		return false
+	}
+	for _, tag := range tags {
+		if !acc.HasTag(tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// printAccountNotes decrypts and prints the notes of an account, if it has
+// any. An account whose notes do not decrypt is reported without failing the listing.
+func printAccountNotes(store storage.Store, name string) {
+	acc, err := store.Get(name)
+	if err != nil {
+		fmt.Printf("    Notes:     cannot be read (%v), run twocli fsck\n", err)
+		return
+	}
+	if acc.Notes != "" {
+		fmt.Printf("    Notes:     %s\n", strings.ReplaceAll(acc.Notes, "\n", "\n               "))
+	}
+}
+
+func printAccountDetails(acc storage.Account) {
+	fmt.Printf("    Issuer:    %s\n", acc.Issuer)
+	fmt.Printf("    Label:     %s\n", acc.Label)
+	fmt.Printf("    Tags:      %s\n", strings.Join(acc.Tags, ", "))
+	fmt.Printf("    Created:   %s\n", formatTime(acc.CreatedAt))
+	fmt.Printf("    Updated:   %s\n", formatTime(acc.UpdatedAt))
+	fmt.Printf("    Last used: %s\n", formatTime(acc.LastUsedAt))
+}
+
+// formatTime formats a timestamp in local time, or "unknown" for the zero time.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "unknown"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
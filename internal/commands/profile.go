@@ -0,0 +1,219 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/bykclk/twocli/internal/config"
+	"github.com/bykclk/twocli/internal/storage"
+)
+
+type ProfileCommand struct{}
+
+func NewProfileCommand() *ProfileCommand {
+	return &ProfileCommand{}
+}
+
+func (c *ProfileCommand) Name() string {
+	return "profile"
+}
+
+func (c *ProfileCommand) Description() string {
+	return "Manage profiles, each with its own vault (list, create, remove, default)"
+}
+
+func (c *ProfileCommand) Run(args []string) error {
+	if len(args) == 0 {
+		return errors.New("a profile command is required: list, create, remove or default")
+	}
+
+	switch args[0] {
+	case "list":
+		return listProfiles()
+	case "create":
+		return createProfile(args[1:])
+	case "remove":
+		return removeProfile(args[1:])
+	case "default":
+		return setDefaultProfile(args[1:])
+	default:
+		return fmt.Errorf("unknown profile command: %s", args[0])
+	}
+}
+
+// listProfiles prints the profiles, marking the active one and the configured default.
+func listProfiles() error {
+	profiles, err := storage.Profiles()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	defaultProfile := cfg.DefaultProfile
+	if defaultProfile == "" {
+		defaultProfile = storage.DefaultProfile
+	}
+
+	fmt.Println("Profiles:")
+	for _, name := range profiles {
+		marker := "-"
+		if name == activeProfile {
+			marker = "*"
+		}
+
+		var notes []string
+		if name == defaultProfile {
+			notes = append(notes, "default")
+		}
+		if name == storage.DefaultProfile {
+			path, err := storage.ProfilePath(name)
+			if err != nil {
+				return err
+			}
+			exists, err := storage.NewFileStore(path).Exists()
+			if err != nil {
+				return err
+			}
+			if !exists {
+				notes = append(notes, "not created")
+			}
+		}
+
+		if len(notes) > 0 {
+			fmt.Printf("%s %s (%s)\n", marker, name, strings.Join(notes, ", "))
+		} else {
+			fmt.Printf("%s %s\n", marker, name)
+		}
+	}
+	return nil
+}
+
+// createProfile creates a profile's vault with its own master password.
+func createProfile(args []string) error {
+	fs := flag.NewFlagSet("profile create", flag.ContinueOnError)
+	name := fs.String("name", "", "Profile name")
+	kdf := addKDFFlags(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *name == "" {
+		fs.Usage()
+		return errors.New("-name is required")
+	}
+
+	params, err := kdf.params()
+	if err != nil {
+		return err
+	}
+
+	path, err := storage.ProfilePath(*name)
+	if err != nil {
+		return err
+	}
+
+	err = createStore(storage.NewFileStore(path), params)
+	if errors.Is(err, storage.ErrVaultExists) {
+		return fmt.Errorf("profile '%s' already exists", *name)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Profile '%s' created successfully. Use it with --profile %s.\n", *name, *name)
+	return nil
+}
+
+// removeProfile deletes a profile's vault and backups after confirmation.
+func removeProfile(args []string) error {
+	fs := flag.NewFlagSet("profile remove", flag.ContinueOnError)
+	name := fs.String("name", "", "Profile name")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *name == "" {
+		fs.Usage()
+		return errors.New("-name is required")
+	}
+
+	confirmed, err := confirmAction(fmt.Sprintf("Remove profile '%s' and every account and backup in it? This cannot be undone. (yes/no): ", *name))
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		fmt.Println("Removal cancelled.")
+		return nil
+	}
+
+	if err = storage.RemoveProfile(*name); err != nil {
+		return err
+	}
+
+	// A removed profile can no longer be the default
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if cfg.DefaultProfile == *name {
+		cfg.DefaultProfile = ""
+		if err = cfg.Save(); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Profile '%s' removed successfully.\n", *name)
+	return nil
+}
+
+// setDefaultProfile records the profile used when none is selected.
+func setDefaultProfile(args []string) error {
+	fs := flag.NewFlagSet("profile default", flag.ContinueOnError)
+	name := fs.String("name", "", "Profile name")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *name == "" {
+		fs.Usage()
+		return errors.New("-name is required")
+	}
+
+	// The default profile may not have been created yet, as after installing
+	if *name != storage.DefaultProfile {
+		path, err := storage.ProfilePath(*name)
+		if err != nil {
+			return err
+		}
+		exists, err := storage.NewFileStore(path).Exists()
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return storage.ErrProfileNotFound
+		}
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	cfg.DefaultProfile = *name
+	if *name == storage.DefaultProfile {
+		cfg.DefaultProfile = ""
+	}
+	if err = cfg.Save(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Default profile set to '%s'.\n", *name)
+	return nil
+}
@@ -0,0 +1,116 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"image/png"
+	"os"
+
+	"github.com/bykclk/twocli/internal/qr"
+)
+
+type QRCommand struct{}
+
+func NewQRCommand() *QRCommand {
+	return &QRCommand{}
+}
+
+func (c *QRCommand) Name() string {
+	return "qr"
+}
+
+func (c *QRCommand) Description() string {
+	return "Show an account as a QR code for scanning with another authenticator"
+}
+
+func (c *QRCommand) Run(args []string) error {
+	fs := flag.NewFlagSet("qr", flag.ContinueOnError)
+	name := fs.String("name", "", "Account name")
+	pngFile := fs.String("png", "", "Also write the QR code to this PNG file")
+	svgFile := fs.String("svg", "", "Also write the QR code to this SVG file")
+	scale := fs.Int("scale", 8, "Pixels per module in the PNG file")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *name == "" {
+		fs.Usage()
+		return errors.New("-name is required")
+	}
+	if *scale < 1 {
+		return errors.New("-scale must be at least 1")
+	}
+
+	// Fail before revealing the secret rather than write only one of the files
+	for _, path := range []string{*pngFile, *svgFile} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Lstat(path); err == nil {
+			return fmt.Errorf("%s already exists", path)
+		}
+	}
+
+	store, err := openStoreWithAttempts()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	key, err := accountKey(store, *name)
+	if err != nil {
+		return err
+	}
+
+	// Let other twocli processes use the vault while waiting for confirmation
+	store.Close()
+
+	// The QR code contains the secret, so anyone who sees it can generate codes
+	confirmed, err := confirmAction(fmt.Sprintf("The QR code reveals the secret of '%s'. Show it? (yes/no): ", *name))
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		fmt.Println("Cancelled.")
+		return nil
+	}
+
+	code, err := qr.Encode(key.URI(), qr.M)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(code.Terminal(qr.QuietZone))
+
+	if *pngFile != "" {
+		data, err := encodePNG(code, *scale)
+		if err != nil {
+			return err
+		}
+		if err = writeNewFile(*pngFile, data); err != nil {
+			return err
+		}
+		fmt.Printf("QR code written to %s\n", *pngFile)
+	}
+
+	if *svgFile != "" {
+		if err = writeNewFile(*svgFile, []byte(code.SVG(qr.QuietZone))); err != nil {
+			return err
+		}
+		fmt.Printf("QR code written to %s\n", *svgFile)
+	}
+
+	return nil
+}
+
+// encodePNG encodes a QR code as a PNG image.
+func encodePNG(code *qr.Code, scale int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, code.Image(scale, qr.QuietZone)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
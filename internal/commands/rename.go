@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+)
+
+type RenameCommand struct{}
+
+func NewRenameCommand() *RenameCommand {
+	return &RenameCommand{}
+}
+
+func (c *RenameCommand) Name() string {
+	return "rename"
+}
+
+func (c *RenameCommand) Description() string {
+	return "Rename an existing account"
+}
+
+func (c *RenameCommand) Run(args []string) error {
+	fs := flag.NewFlagSet("rename", flag.ContinueOnError)
+	name := fs.String("name", "", "Account name or alias")
+	to := fs.String("to", "", "New account name")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *name == "" || *to == "" {
+		fs.Usage()
+		return errors.New("both -name and -to are required")
+	}
+
+	store, err := openStoreWithAttempts()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	// The account may be given by an alias, but its name is reported
+	acc, err := store.Get(*name)
+	if err != nil {
+		return err
+	}
+	if err = store.Rename(acc.Name, *to); err != nil {
+		return err
+	}
+
+	fmt.Printf("Account '%s' renamed to '%s'.\n", acc.Name, *to)
+	return nil
+}
@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+)
+
+type RestoreCommand struct{}
+
+func NewRestoreCommand() *RestoreCommand {
+	return &RestoreCommand{}
+}
+
+func (c *RestoreCommand) Name() string {
+	return "restore"
+}
+
+func (c *RestoreCommand) Description() string {
+	return "Restore a deleted account or a replaced secret from the trash"
+}
+
+func (c *RestoreCommand) Run(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	name := fs.String("name", "", "Account name")
+	version := fs.Int("version", 1, "Version to restore, from 1 (most recently trashed), as shown by trash list")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *name == "" {
+		fs.Usage()
+		return errors.New("-name is required")
+	}
+	if *version < 1 {
+		return errors.New("-version must be at least 1")
+	}
+
+	store, err := openStoreWithAttempts()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if err = store.Restore(*name, *version); err != nil {
+		return err
+	}
+
+	fmt.Printf("Account '%s' restored from the trash.\n", *name)
+	return nil
+}
@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/bykclk/twocli/internal/storage"
+)
+
+type RestoreBackupCommand struct{}
+
+func NewRestoreBackupCommand() *RestoreBackupCommand {
+	return &RestoreBackupCommand{}
+}
+
+func (c *RestoreBackupCommand) Name() string {
+	return "restore-backup"
+}
+
+func (c *RestoreBackupCommand) Description() string {
+	return "List the vault backups or roll the vault back to one of them"
+}
+
+func (c *RestoreBackupCommand) Run(args []string) error {
+	fs := flag.NewFlagSet("restore-backup", flag.ContinueOnError)
+	list := fs.Bool("list", false, "List the backups without restoring")
+	generation := fs.Int("generation", 1, fmt.Sprintf("Backup to restore, from 1 (most recent) to %d", storage.MaxBackups))
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	store := newStore()
+	backups, err := store.Backups()
+	if err != nil {
+		return err
+	}
+
+	if *list {
+		if len(backups) == 0 {
+			fmt.Println("No backups found.")
+			return nil
+		}
+
+		fmt.Println("Backups:")
+		for _, backup := range backups {
+			fmt.Printf("- %d: %s\n", backup.Generation, backup.ModTime.Format("2006-01-02 15:04:05"))
+		}
+		return nil
+	}
+
+	var backup *storage.Backup
+	for i := range backups {
+		if backups[i].Generation == *generation {
+			backup = &backups[i]
+		}
+	}
+	if backup == nil {
+		return storage.ErrBackupNotFound
+	}
+
+	confirmed, err := confirmAction(fmt.Sprintf("Replace the vault with backup %d from %s? (yes/no): ",
+		backup.Generation, backup.ModTime.Format("2006-01-02 15:04:05")))
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		fmt.Println("Restore cancelled.")
+		return nil
+	}
+
+	if err = store.RestoreBackup(backup.Generation); err != nil {
+		return err
+	}
+
+	fmt.Printf("Vault restored from backup %d. It opens with the master password it had then.\n", backup.Generation)
+	fmt.Println("The replaced vault was kept as backup 1.")
+	return nil
+}
@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/bykclk/twocli/internal/totp"
+)
+
+type ResyncCommand struct{}
+
+func NewResyncCommand() *ResyncCommand {
+	return &ResyncCommand{}
+}
+
+func (c *ResyncCommand) Name() string {
+	return "resync"
+}
+
+func (c *ResyncCommand) Description() string {
+	return "Resynchronize the counter of an HOTP account"
+}
+
+func (c *ResyncCommand) Run(args []string) error {
+	fs := flag.NewFlagSet("resync", flag.ContinueOnError)
+	name := fs.String("name", "", "Account name")
+	code1 := fs.String("code1", "", "First code shown by the token")
+	code2 := fs.String("code2", "", "Next code shown by the token")
+	window := fs.Int("window", totp.DefaultResyncWindow, "Number of counter values to search ahead")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *name == "" || *code1 == "" || *code2 == "" {
+		fs.Usage()
+		return errors.New("-name, -code1 and -code2 are required")
+	}
+	if *window < 0 {
+		return errors.New("-window cannot be negative")
+	}
+
+	store, err := openStoreWithAttempts()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	acc, err := store.Get(*name)
+	if err != nil {
+		return err
+	}
+
+	if !acc.IsHOTP() {
+		return fmt.Errorf("account '%s' is not an HOTP account", acc.Name)
+	}
+
+	counter, err := totp.Resync(acc.Secret, *code1, *code2, acc.Counter, *window, acc.Params())
+	if err != nil {
+		return err
+	}
+
+	acc.Counter = counter
+	if err = store.Put(acc); err != nil {
+		return err
+	}
+
+	fmt.Printf("Account '%s' resynchronized, next counter is %d.\n", acc.Name, counter)
+	return nil
+}
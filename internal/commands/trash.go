@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type TrashCommand struct{}
+
+func NewTrashCommand() *TrashCommand {
+	return &TrashCommand{}
+}
+
+func (c *TrashCommand) Name() string {
+	return "trash"
+}
+
+func (c *TrashCommand) Description() string {
+	return "Manage deleted accounts and replaced secrets (list, purge)"
+}
+
+func (c *TrashCommand) Run(args []string) error {
+	if len(args) == 0 {
+		return errors.New("a trash command is required: list or purge")
+	}
+
+	switch args[0] {
+	case "list":
+		return listTrash()
+	case "purge":
+		return purgeTrash(args[1:])
+	default:
+		return fmt.Errorf("unknown trash command: %s", args[0])
+	}
+}
+
+// listTrash prints the account versions in the trash, most recently trashed first.
+func listTrash() error {
+	store, err := openStoreWithAttempts()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	trash, err := store.Trash()
+	if err != nil {
+		return err
+	}
+	if len(trash) == 0 {
+		fmt.Println("The trash is empty.")
+		return nil
+	}
+
+	fmt.Println("Trash:")
+	for _, acc := range trash {
+		fmt.Printf("- %s (version %d, %s %s)\n", acc.Name, acc.Version, acc.Reason, formatTime(acc.TrashedAt))
+	}
+	return nil
+}
+
+// purgeTrash permanently removes account versions from the trash.
+func purgeTrash(args []string) error {
+	fs := flag.NewFlagSet("trash purge", flag.ContinueOnError)
+	olderThan := fs.String("older-than", "", "Only purge accounts trashed longer ago than this, such as 30d or 12h")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	before := time.Now()
+	prompt := "Are you sure you want to permanently delete everything in the trash, and the vault backups? (yes/no): "
+	if *olderThan != "" {
+		age, err := parseAge(*olderThan)
+		if err != nil {
+			return err
+		}
+		before = before.Add(-age)
+		prompt = fmt.Sprintf("Are you sure you want to permanently delete accounts trashed more than %s ago, and the vault backups? (yes/no): ", *olderThan)
+	}
+
+	// Confirm before opening the vault, so it is not locked while waiting
+	confirmed, err := confirmAction(prompt)
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		fmt.Println("Purge cancelled.")
+		return nil
+	}
+
+	store, err := openStoreWithAttempts()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	purged, err := store.PurgeTrash(before)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Purged %d accounts from the trash.\n", purged)
+	return nil
+}
+
+// parseAge parses an age given in days, such as 30d, or as a duration
+// accepted by time.ParseDuration, such as 12h.
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age: %s", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	age, err := time.ParseDuration(s)
+	if err != nil || age < 0 {
+		return 0, fmt.Errorf("invalid age: %s", s)
+	}
+	return age, nil
+}
@@ -4,8 +4,8 @@ import (
 	"errors"
 	"flag"
 	"fmt"
-	"github.com/bykclk/twocli/internal/storage"
 
+	"github.com/bykclk/twocli/internal/storage"
 	"github.com/bykclk/twocli/internal/totp"
 )
 
@@ -20,33 +20,74 @@ func (c *UpdateCommand) Name() string {
 }
 
 func (c *UpdateCommand) Description() string {
-	return "Update the secret key of an existing account"
+	return "Update the secret key, code parameters or details of an existing account"
 }
 
 func (c *UpdateCommand) Run(args []string) error {
 	fs := flag.NewFlagSet("update", flag.ContinueOnError)
 	name := fs.String("name", "", "Account name")
 	secret := fs.String("secret", "", "New account secret key (base32 encoded)")
+	pf := addParamFlags(fs)
+	mf := addMetadataFlags(fs)
 
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
 
-	if *name == "" || *secret == "" {
+	paramsSet := isFlagSet(fs, "algorithm") || isFlagSet(fs, "digits") || isFlagSet(fs, "period")
+
+	if *name == "" || (*secret == "" && !paramsSet && !mf.set()) {
 		fs.Usage()
-		return errors.New("both -name and -secret are required")
+		return errors.New("-name and at least one of -secret, -algorithm, -digits, -period, -issuer, -label, -tags, -aliases or -notes are required")
 	}
 
-	if err := totp.ValidateSecret(*secret); err != nil {
-		return fmt.Errorf("invalid secret key: %v", err)
+	if *secret != "" {
+		if err := totp.ValidateSecret(*secret); err != nil {
+			return fmt.Errorf("invalid secret key: %v", err)
+		}
 	}
 
-	_, masterPassword, err := loadAccountsWithAttempts()
+	store, err := openStoreWithAttempts()
 	if err != nil {
 		return err
 	}
+	defer store.Close()
+
+	// All changes are saved together, or not at all
+	err = store.Update(func(tx *storage.Tx) error {
+		acc, err := tx.Get(*name)
+		if err != nil {
+			return err
+		}
 
-	if err = storage.UpdateAccount(*name, *secret, masterPassword); err != nil {
+		if paramsSet {
+			// Flags that were not given keep the account's current values
+			current := acc.Params()
+			if !isFlagSet(fs, "algorithm") {
+				*pf.algorithm = current.Algorithm
+			}
+			if !isFlagSet(fs, "digits") {
+				*pf.digits = current.Digits
+			}
+			if !isFlagSet(fs, "period") {
+				*pf.period = current.Period
+			}
+
+			params, err := pf.params()
+			if err != nil {
+				return err
+			}
+			acc.SetParams(params)
+		}
+
+		if *secret != "" {
+			acc.Secret = *secret
+		}
+		mf.apply(&acc)
+
+		return tx.Put(acc)
+	})
+	if err != nil {
 		return err
 	}
 
@@ -0,0 +1,84 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/bykclk/twocli/internal/storage"
+	"github.com/bykclk/twocli/internal/totp"
+)
+
+type URICommand struct{}
+
+func NewURICommand() *URICommand {
+	return &URICommand{}
+}
+
+func (c *URICommand) Name() string {
+	return "uri"
+}
+
+func (c *URICommand) Description() string {
+	return "Print the otpauth:// URI of an account"
+}
+
+func (c *URICommand) Run(args []string) error {
+	fs := flag.NewFlagSet("uri", flag.ContinueOnError)
+	name := fs.String("name", "", "Account name")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *name == "" {
+		fs.Usage()
+		return errors.New("-name is required")
+	}
+
+	store, err := openStoreWithAttempts()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	key, err := accountKey(store, *name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(key.URI())
+	return nil
+}
+
+// accountKey builds the otpauth key of a stored account, including its decrypted secret.
+func accountKey(store storage.Store, name string) (*totp.Key, error) {
+	acc, err := store.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return keyFromAccount(acc), nil
+}
+
+// keyFromAccount builds the otpauth key of an account. The account name is
+// used as the key's account name if the account has no label.
+func keyFromAccount(acc storage.Account) *totp.Key {
+	otpType := totp.TypeTOTP
+	if acc.IsHOTP() {
+		otpType = totp.TypeHOTP
+	}
+
+	accountName := acc.Label
+	if accountName == "" {
+		accountName = acc.Name
+	}
+
+	return &totp.Key{
+		Type:        otpType,
+		Issuer:      acc.Issuer,
+		AccountName: accountName,
+		Secret:      acc.Secret,
+		Params:      acc.Params(),
+		Counter:     acc.Counter,
+	}
+}
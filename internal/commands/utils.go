@@ -3,16 +3,25 @@ package commands
 import (
 	"bufio"
 	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"os/exec"
+	"slices"
 	"strings"
 
+	"github.com/bykclk/twocli/internal/config"
+	"github.com/bykclk/twocli/internal/crypto"
 	"github.com/bykclk/twocli/internal/storage"
+	"github.com/bykclk/twocli/internal/totp"
 )
 
 const maxPasswordAttempts = 3
 
+// stdin is shared by all prompts, so input buffered while reading one answer
+// is not lost to the next.
+var stdin = bufio.NewReader(os.Stdin)
+
 func promptPassword(prompt string) (string, error) {
 	fmt.Print(prompt)
 	password, err := readPassword()
@@ -30,9 +39,9 @@ func readPassword() (string, error) {
 		return "", err
 	}
 
-	var password string
-	// Handle the error from Scanln
-	if _, err := fmt.Scanln(&password); err != nil {
+	// Read the whole line so passphrases may contain spaces
+	password, err := stdin.ReadString('\n')
+	if err != nil {
 		// Re-enable input echoing before returning the error
 		cmd = exec.Command("stty", "echo")
 		cmd.Stdin = os.Stdin
@@ -52,9 +61,8 @@ func readPassword() (string, error) {
 }
 
 func confirmAction(prompt string) (bool, error) {
-	reader := bufio.NewReader(os.Stdin)
 	fmt.Print(prompt)
-	response, err := reader.ReadString('\n')
+	response, err := stdin.ReadString('\n')
 	if err != nil {
 		return false, err
 	}
@@ -69,29 +77,292 @@ func promptForMasterPassword() (string, error) {
 	return promptPassword("Enter master password: ")
 }
 
-func loadAccountsWithAttempts() ([]storage.Account, string, error) {
-	var masterPassword string
-	var accounts []storage.Account
-	var err error
+// promptNewPassword asks for a new master password twice, checks that both
+// entries match and that the password is strong enough.
+func promptNewPassword() (string, error) {
+	password, err := promptPassword("Enter new master password: ")
+	if err != nil {
+		return "", err
+	}
+	if password == "" {
+		return "", errors.New("master password cannot be empty")
+	}
+	if entropy := crypto.PasswordEntropy(password); entropy < crypto.MinPasswordEntropy {
+		return "", fmt.Errorf("master password is too weak (about %.0f bits, at least %d required); use a longer password or a passphrase of several words",
+			entropy, crypto.MinPasswordEntropy)
+	}
+
+	confirmation, err := promptPassword("Confirm new master password: ")
+	if err != nil {
+		return "", err
+	}
+	if confirmation != password {
+		return "", errors.New("passwords do not match")
+	}
+
+	return password, nil
+}
+
+// activeProfile is the profile selected by UseVault, or empty when a vault
+// file was chosen directly.
+var activeProfile string
+
+// UseVault selects the vault file from, in order of precedence, the -vault
+// flag, the -profile flag, the TWOCLI_VAULT environment variable and the
+// default profile from the configuration file. When the default profile is
+// used, a vault left in ./data/accounts.db by earlier versions is moved to it.
+func UseVault(flagPath, flagProfile string) error {
+	if flagPath != "" && flagProfile != "" {
+		return errors.New("-vault and -profile cannot be used together")
+	}
+
+	if flagPath == "" && flagProfile == "" {
+		flagPath = os.Getenv(storage.EnvVault)
+	}
+	if flagPath != "" {
+		storage.SetPath(flagPath)
+		return nil
+	}
+
+	profile := flagProfile
+	if profile == "" {
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		profile = cfg.DefaultProfile
+	}
+	if profile == "" {
+		profile = storage.DefaultProfile
+	}
+
+	path, err := storage.ProfilePath(profile)
+	if err != nil {
+		return err
+	}
+	storage.SetPath(path)
+	activeProfile = profile
+
+	// Profiles other than the default one never existed in the old location
+	if profile != storage.DefaultProfile {
+		return nil
+	}
+
+	moved, err := storage.MigrateLegacyVault()
+	if err != nil {
+		return fmt.Errorf("failed to move the vault from data/accounts.db to %s: %v", path, err)
+	}
+	if moved {
+		fmt.Printf("Moved the vault from data/accounts.db to %s.\n", path)
+	}
+	return nil
+}
+
+// createStore creates a vault with a new master password for the store.
+func createStore(store *storage.FileStore, kdf crypto.KDFParams) error {
+	exists, err := store.Exists()
+	if err != nil {
+		return err
+	}
+	if exists {
+		return storage.ErrVaultExists
+	}
+
+	masterPassword, err := promptNewPassword()
+	if err != nil {
+		return err
+	}
+
+	if err = store.Create(masterPassword, kdf); err != nil {
+		return err
+	}
+	return store.Close()
+}
+
+// newStore returns the store for the vault selected by UseVault.
+func newStore() *storage.FileStore {
+	return storage.NewFileStore(storage.Path())
+}
+
+// openStoreWithAttempts prompts for the master password until it unlocks the vault.
+func openStoreWithAttempts() (storage.Store, error) {
+	store := newStore()
+
+	// Fail before asking for a password that could not be checked
+	exists, err := store.Exists()
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, storage.ErrVaultNotFound
+	}
 
 	for attempts := 0; attempts < maxPasswordAttempts; attempts++ {
-		masterPassword, err = promptForMasterPassword()
+		masterPassword, err := promptForMasterPassword()
 		if err != nil {
-			return nil, "", err
+			return nil, err
 		}
 
-		accounts, err = storage.LoadAccounts(masterPassword)
+		err = store.Open(masterPassword)
 		if err == nil {
-			return accounts, masterPassword, nil
+			return store, nil
 		}
 
-		if err.Error() == "incorrect master password" {
+		if errors.Is(err, storage.ErrIncorrectPassword) {
 			fmt.Println("Incorrect master password. Please try again.")
 			continue
 		} else {
-			return nil, "", err
+			return nil, err
+		}
+	}
+
+	return nil, errors.New("maximum password attempts exceeded")
+}
+
+// paramFlags holds the TOTP parameter flags shared by the add and update commands.
+type paramFlags struct {
+	algorithm *string
+	digits    *int
+	period    *int
+}
+
+func addParamFlags(fs *flag.FlagSet) *paramFlags {
+	defaults := totp.DefaultParams()
+	return &paramFlags{
+		algorithm: fs.String("algorithm", defaults.Algorithm, "HMAC algorithm (SHA1, SHA256 or SHA512)"),
+		digits:    fs.Int("digits", defaults.Digits, "Number of code digits (6-10)"),
+		period:    fs.Int("period", defaults.Period, "Code validity period in seconds"),
+	}
+}
+
+// params returns the parameters from the flags, validated.
+func (p *paramFlags) params() (totp.Params, error) {
+	params := totp.Params{
+		Algorithm: totp.NormalizeAlgorithm(*p.algorithm),
+		Digits:    *p.digits,
+		Period:    *p.period,
+	}
+	if err := params.Validate(); err != nil {
+		return totp.Params{}, fmt.Errorf("invalid code parameters: %v", err)
+	}
+	return params, nil
+}
+
+// isFlagSet reports whether the named flag was given on the command line.
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// firstFlagSet returns the first of the named flags given on the command line, or "".
+func firstFlagSet(fs *flag.FlagSet, names ...string) string {
+	for _, name := range names {
+		if isFlagSet(fs, name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// metadataFlags holds the account metadata flags shared by the add and update commands.
+type metadataFlags struct {
+	fs      *flag.FlagSet
+	issuer  *string
+	label   *string
+	tags    *string
+	aliases *string
+	notes   *string
+}
+
+func addMetadataFlags(fs *flag.FlagSet) *metadataFlags {
+	return &metadataFlags{
+		fs:      fs,
+		issuer:  fs.String("issuer", "", "Service the account belongs to"),
+		label:   fs.String("label", "", "Account label, such as a username or email address"),
+		tags:    fs.String("tags", "", "Comma-separated list of tags"),
+		aliases: fs.String("aliases", "", "Comma-separated list of other names to look the account up by"),
+		notes:   fs.String("notes", "", "Free-form notes, stored encrypted"),
+	}
+}
+
+// set reports whether any metadata flag was given on the command line.
+func (m *metadataFlags) set() bool {
+	for _, name := range []string{"issuer", "label", "tags", "aliases", "notes"} {
+		if isFlagSet(m.fs, name) {
+			return true
 		}
 	}
+	return false
+}
 
-	return nil, "", errors.New("maximum password attempts exceeded")
+// apply sets the account fields whose flags were given on the command line,
+// so an empty value clears a field.
+func (m *metadataFlags) apply(acc *storage.Account) {
+	if isFlagSet(m.fs, "issuer") {
+		acc.Issuer = *m.issuer
+	}
+	if isFlagSet(m.fs, "label") {
+		acc.Label = *m.label
+	}
+	if isFlagSet(m.fs, "tags") {
+		acc.Tags = parseList(*m.tags)
+	}
+	if isFlagSet(m.fs, "aliases") {
+		acc.Aliases = parseList(*m.aliases)
+	}
+	if isFlagSet(m.fs, "notes") {
+		acc.Notes = *m.notes
+	}
+}
+
+// parseList splits a comma-separated list, such as tags or aliases, dropping
+// empty and duplicate items, compared case-insensitively.
+func parseList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" || slices.ContainsFunc(items, func(i string) bool { return strings.EqualFold(i, item) }) {
+			continue
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// kdfFlags holds the Argon2id cost flags.
+type kdfFlags struct {
+	time    *uint
+	memory  *uint
+	threads *uint
+}
+
+func addKDFFlags(fs *flag.FlagSet) *kdfFlags {
+	return &kdfFlags{
+		time:    fs.Uint("kdf-time", crypto.DefaultArgon2Time, "Argon2id passes over memory"),
+		memory:  fs.Uint("kdf-memory", crypto.DefaultArgon2Memory/1024, "Argon2id memory in MiB"),
+		threads: fs.Uint("kdf-threads", crypto.DefaultArgon2Threads, "Argon2id parallelism"),
+	}
+}
+
+// params returns the key derivation parameters from the flags, validated.
+func (k *kdfFlags) params() (crypto.KDFParams, error) {
+	if *k.time > 1<<16 || *k.memory > 1<<20 || *k.threads > 255 {
+		return crypto.KDFParams{}, errors.New("invalid key derivation cost: value out of range")
+	}
+
+	params := crypto.KDFParams{
+		Algorithm: crypto.KDFArgon2id,
+		Time:      uint32(*k.time),
+		Memory:    uint32(*k.memory) * 1024,
+		Threads:   uint8(*k.threads),
+	}
+	if err := params.Validate(); err != nil {
+		return crypto.KDFParams{}, fmt.Errorf("invalid key derivation cost: %v", err)
+	}
+	return params, nil
 }
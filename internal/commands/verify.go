@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/bykclk/twocli/internal/cli"
+	"github.com/bykclk/twocli/internal/storage"
+	"github.com/bykclk/twocli/internal/totp"
+)
+
+// Exit statuses of the verify command
+const (
+	verifyExitInvalid = 1
+	verifyExitError   = 2
+)
+
+type VerifyCommand struct{}
+
+func NewVerifyCommand() *VerifyCommand {
+	return &VerifyCommand{}
+}
+
+func (c *VerifyCommand) Name() string {
+	return "verify"
+}
+
+func (c *VerifyCommand) Description() string {
+	return "Check a code against an account (exit status 0 valid, 1 invalid, 2 error)"
+}
+
+func (c *VerifyCommand) Run(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	name := fs.String("name", "", "Account name")
+	code := fs.String("code", "", "Code to check")
+	skew := fs.Int("skew", totp.DefaultSkew, "Number of time steps before and after the current one to accept")
+	replayGuard := fs.Bool("replay-guard", false, "Reject codes for a time step at or before the last accepted one")
+
+	if err := fs.Parse(args); err != nil {
+		return &cli.ExitError{Code: verifyExitError, Err: err}
+	}
+
+	if *name == "" || *code == "" {
+		fs.Usage()
+		return &cli.ExitError{Code: verifyExitError, Err: errors.New("both -name and -code are required")}
+	}
+
+	store, err := openStoreWithAttempts()
+	if err != nil {
+		return &cli.ExitError{Code: verifyExitError, Err: err}
+	}
+	defer store.Close()
+
+	match, err := storage.VerifyCode(store, *name, *code, *skew, *replayGuard)
+	if errors.Is(err, totp.ErrInvalidCode) || errors.Is(err, totp.ErrReplayedCode) {
+		return &cli.ExitError{Code: verifyExitInvalid, Err: err}
+	}
+	if err != nil {
+		return &cli.ExitError{Code: verifyExitError, Err: err}
+	}
+
+	fmt.Printf("Code is valid (step offset %+d).\n", match.Offset)
+	return nil
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Config holds the user's settings, read from config.json in the twocli
+// directory of the user's configuration directory.
+type Config struct {
+	// DefaultProfile is the profile used when no other is selected.
+	DefaultProfile string `json:"default_profile,omitempty"`
+}
+
+// Path returns the location of the configuration file,
+// $XDG_CONFIG_HOME/twocli/config.json or ~/.config/twocli/config.json on Linux.
+func Path() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "twocli", "config.json"), nil
+}
+
+// Load reads the configuration file. A missing file is an empty configuration.
+func Load() (Config, error) {
+	var cfg Config
+
+	path, err := Path()
+	if err != nil {
+		return cfg, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+
+	if err = json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("invalid configuration file %s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// Save writes the configuration file.
+func (c Config) Save() error {
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadSave(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+
+	// A missing file is an empty configuration
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() unexpected error = %v", err)
+	}
+	if cfg != (Config{}) {
+		t.Fatalf("Load() = %+v, want empty configuration", cfg)
+	}
+
+	cfg.DefaultProfile = "work"
+	if err = cfg.Save(); err != nil {
+		t.Fatalf("Save() unexpected error = %v", err)
+	}
+
+	loaded, err := Load()
+	if err != nil {
+		t.Fatalf("Load() unexpected error = %v", err)
+	}
+	if loaded != cfg {
+		t.Fatalf("Load() = %+v, want %+v", loaded, cfg)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+
+	path, err := Path()
+	if err != nil {
+		t.Fatalf("Path() unexpected error = %v", err)
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err = os.WriteFile(path, []byte("default_profile = work"), 0600); err != nil {
+		t.Fatalf("Failed to write configuration: %v", err)
+	}
+
+	if _, err = Load(); err == nil {
+		t.Fatalf("Load() expected error for an invalid file")
+	}
+}
@@ -0,0 +1,106 @@
+package crypto
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// MinPasswordEntropy is the estimated entropy, in bits, a new master password must reach.
+const MinPasswordEntropy = 50
+
+// commonPasswords are rejected outright, along with any password that is
+// one of them followed by digits or symbols.
+var commonPasswords = []string{
+	"123456", "password", "qwerty", "abc123", "letmein", "welcome", "monkey",
+	"dragon", "iloveyou", "admin", "login", "master", "sunshine", "princess",
+	"football", "baseball", "shadow", "superman", "trustno1", "passw0rd",
+}
+
+// keyboardRows are used to spot runs of adjacent keys such as "qwerty" or "asdf".
+var keyboardRows = []string{"1234567890", "qwertyuiop", "asdfghjkl", "zxcvbnm"}
+
+// PasswordEntropy estimates the entropy of a password in bits. Each character
+// counts for the size of the character classes used in the password, except
+// characters that repeat the previous one or continue an alphabetical, numeric
+// or keyboard sequence, which count for one bit. The estimate is deliberately
+// conservative and far simpler than a real cracking model.
+func PasswordEntropy(password string) float64 {
+	runes := []rune(password)
+	if len(runes) == 0 || isCommonPassword(password) {
+		return 0
+	}
+
+	bitsPerChar := math.Log2(float64(poolSize(runes)))
+
+	entropy := bitsPerChar
+	for i := 1; i < len(runes); i++ {
+		if predictable(runes[i-1], runes[i]) {
+			entropy++
+		} else {
+			entropy += bitsPerChar
+		}
+	}
+	return entropy
+}
+
+// poolSize returns the number of characters in the classes the password draws from.
+func poolSize(runes []rune) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range runes {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	size := 0
+	for _, class := range []struct {
+		used bool
+		size int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.used {
+			size += class.size
+		}
+	}
+	return size
+}
+
+// predictable reports whether cur repeats prev or continues a sequence from it.
+func predictable(prev, cur rune) bool {
+	prev, cur = unicode.ToLower(prev), unicode.ToLower(cur)
+	if cur == prev || cur == prev+1 || cur == prev-1 {
+		return true
+	}
+
+	for _, row := range keyboardRows {
+		i := strings.IndexRune(row, prev)
+		if i < 0 {
+			continue
+		}
+		if (i+1 < len(row) && rune(row[i+1]) == cur) || (i > 0 && rune(row[i-1]) == cur) {
+			return true
+		}
+	}
+	return false
+}
+
+func isCommonPassword(password string) bool {
+	base := strings.TrimRightFunc(strings.ToLower(password), func(r rune) bool {
+		return unicode.IsDigit(r) || unicode.IsPunct(r) || unicode.IsSymbol(r)
+	})
+	for _, common := range commonPasswords {
+		if base == common || strings.ToLower(password) == common {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package crypto
+
+import "testing"
+
+func TestPasswordEntropy(t *testing.T) {
+	tests := []struct {
+		password string
+		strong   bool
+	}{
+		{"", false},
+		{"password", false},
+		{"Password123!", false},
+		{"hunter22", false},
+		{"aaaaaaaaaaaaaaaaaaaa", false},
+		{"abcdefghijklmnopqrstuvwxyz", false},
+		{"qwertyuiopasdfghjkl", false},
+		{"123456789012345", false},
+		{"Tr0ub4dor&3", true},
+		{"kx9#mPq2vL", true},
+		{"correct horse battery staple", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			entropy := PasswordEntropy(tt.password)
+			if strong := entropy >= MinPasswordEntropy; strong != tt.strong {
+				t.Errorf("PasswordEntropy(%q) = %.1f, strong = %v, want %v", tt.password, entropy, strong, tt.strong)
+			}
+		})
+	}
+}
+
+func TestPasswordEntropyPenalizesSequences(t *testing.T) {
+	if random, sequence := PasswordEntropy("qmzjxv"), PasswordEntropy("abcdef"); sequence >= random {
+		t.Errorf("PasswordEntropy() sequence = %.1f, want less than random %.1f", sequence, random)
+	}
+}
@@ -0,0 +1,79 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// The functions below implement the primitives used by the backups of other
+// authenticators, which twocli reads and writes but does not use itself.
+
+// Highest scrypt costs accepted: N = 2^20 with r = 8 already takes 1 GiB.
+const (
+	maxScryptN = 1 << 20
+	maxScryptR = 32
+	maxScryptP = 16
+)
+
+// ScryptKey derives a 256-bit key from the password with scrypt. As the
+// costs come from files, N must be a power of two of at most 2^20, r at most
+// 32 and p at most 16.
+func ScryptKey(password string, salt []byte, n, r, p int) ([]byte, error) {
+	if n < 2 || n > maxScryptN || n&(n-1) != 0 {
+		return nil, errors.New("scrypt N must be a power of two of at most 2^20")
+	}
+	if r < 1 || r > maxScryptR || p < 1 || p > maxScryptP {
+		return nil, errors.New("scrypt r or p is out of range")
+	}
+	return scrypt.Key([]byte(password), salt, n, r, p, keySize)
+}
+
+// PBKDF2Key derives a 256-bit key from the password with PBKDF2-SHA256.
+func PBKDF2Key(password string, salt []byte, iterations int) ([]byte, error) {
+	if iterations < 1 {
+		return nil, errors.New("pbkdf2 iterations must be at least 1")
+	}
+	return pbkdf2.Key([]byte(password), salt, iterations, keySize, sha256.New), nil
+}
+
+// SealGCM encrypts data with AES-256-GCM under the key and a 12-byte nonce,
+// and returns the ciphertext followed by the tag.
+func SealGCM(key, nonce, data []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != nonceSize {
+		return nil, errors.New("invalid nonce size")
+	}
+	return aead.Seal(nil, nonce, data, nil), nil
+}
+
+// OpenGCM decrypts a ciphertext followed by its tag produced by SealGCM.
+func OpenGCM(key, nonce, data []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != nonceSize || len(data) < tagSize {
+		return nil, errors.New("invalid data")
+	}
+	plaintext, err := aead.Open(nil, nonce, data, nil)
+	if err != nil {
+		return nil, errDecrypt
+	}
+	return plaintext, nil
+}
+
+// RandomBytes returns n bytes from the system's secure random number generator.
+func RandomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
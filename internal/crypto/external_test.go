@@ -0,0 +1,68 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestSealOpenGCM(t *testing.T) {
+	key, err := ScryptKey("test", []byte("salt"), 1024, 8, 1)
+	if err != nil {
+		t.Fatalf("Failed to derive key: %v", err)
+	}
+	nonce, err := RandomBytes(12)
+	if err != nil {
+		t.Fatalf("Failed to generate nonce: %v", err)
+	}
+
+	sealed, err := SealGCM(key, nonce, []byte("secret data"))
+	if err != nil {
+		t.Fatalf("Failed to seal: %v", err)
+	}
+	opened, err := OpenGCM(key, nonce, sealed)
+	if err != nil {
+		t.Fatalf("Failed to open: %v", err)
+	}
+	if !bytes.Equal(opened, []byte("secret data")) {
+		t.Errorf("Opened data does not match original")
+	}
+
+	sealed[0] ^= 1
+	if _, err = OpenGCM(key, nonce, sealed); err == nil {
+		t.Errorf("Expected tampered data to fail")
+	}
+}
+
+func TestScryptKeyLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		n, r, p int
+	}{
+		{"N not a power of two", 1000, 8, 1},
+		{"N too large", 1 << 21, 8, 1},
+		{"r too large", 1024, 33, 1},
+		{"p too large", 1024, 8, 17},
+		{"Zero p", 1024, 8, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ScryptKey("test", []byte("salt"), tt.n, tt.r, tt.p); err == nil {
+				t.Errorf("Expected ScryptKey(%d, %d, %d) to fail", tt.n, tt.r, tt.p)
+			}
+		})
+	}
+}
+
+func TestPBKDF2Key(t *testing.T) {
+	// RFC 7914 section 11, truncated to 32 bytes
+	key, err := PBKDF2Key("passwd", []byte("salt"), 1)
+	if err != nil {
+		t.Fatalf("Failed to derive key: %v", err)
+	}
+	want := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc"
+	if got := hex.EncodeToString(key); got != want {
+		t.Errorf("PBKDF2Key = %s, want %s", got, want)
+	}
+}
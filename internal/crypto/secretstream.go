@@ -0,0 +1,102 @@
+package crypto
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/poly1305"
+)
+
+// libsodium's crypto_secretstream_xchacha20poly1305, as used by Ente Auth
+// exports. Each message is a tag byte and the data, encrypted with ChaCha20
+// under a key derived from the stream key and the header with HChaCha20, and
+// authenticated with Poly1305. Only streams holding a single message, the
+// last one, are supported.
+const (
+	SecretStreamHeaderSize = 24
+
+	secretStreamTagSize  = 1
+	secretStreamMACSize  = 16
+	secretStreamTagFinal = 3
+
+	// libsodium's crypto_pwhash_OPSLIMIT_SENSITIVE and MEMLIMIT_SENSITIVE,
+	// its costliest presets
+	sodiumOpsLimitSensitive = 4
+	sodiumMemLimitSensitive = 1 << 30
+)
+
+// SodiumKey derives a 256-bit key from the password with Argon2id, as
+// libsodium's crypto_pwhash does with the given operations and memory (in
+// bytes) limits. As the limits come from files, they are refused above
+// libsodium's sensitive preset: more memory, or more work. Lower memory with
+// more operations is accepted, as Ente falls back to it on small devices.
+func SodiumKey(password string, salt []byte, opsLimit, memLimit uint32) ([]byte, error) {
+	if opsLimit < 1 || memLimit < 8*1024 {
+		return nil, errors.New("invalid argon2id limits")
+	}
+	if memLimit > sodiumMemLimitSensitive || uint64(opsLimit)*uint64(memLimit) > sodiumOpsLimitSensitive*sodiumMemLimitSensitive {
+		return nil, errors.New("argon2id limits are too high")
+	}
+	return argon2.IDKey([]byte(password), salt, opsLimit, memLimit/1024, 1, keySize), nil
+}
+
+// OpenSecretStream decrypts a stream holding a single, final message.
+func OpenSecretStream(key, header, data []byte) ([]byte, error) {
+	if len(key) != keySize || len(header) != SecretStreamHeaderSize {
+		return nil, errors.New("invalid secret stream key or header")
+	}
+	if len(data) < secretStreamTagSize+secretStreamMACSize {
+		return nil, errors.New("invalid data")
+	}
+
+	subkey, err := chacha20.HChaCha20(key, header[:16])
+	if err != nil {
+		return nil, err
+	}
+	// The nonce is a 32-bit counter, starting at 1, followed by the rest of the header
+	nonce := make([]byte, chacha20.NonceSize)
+	binary.LittleEndian.PutUint32(nonce, 1)
+	copy(nonce[4:], header[16:])
+
+	stream, err := chacha20.NewUnauthenticatedCipher(subkey, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	// Block 0 keys Poly1305, block 1 encrypts the tag and the data starts at block 2
+	var block [64]byte
+	stream.XORKeyStream(block[:], block[:])
+	var macKey [32]byte
+	copy(macKey[:], block[:32])
+	mac := poly1305.New(&macKey)
+
+	block = [64]byte{}
+	block[0] = data[0]
+	stream.XORKeyStream(block[:], block[:])
+	tag := block[0]
+	block[0] = data[0]
+	mac.Write(block[:])
+
+	ciphertext := data[secretStreamTagSize : len(data)-secretStreamMACSize]
+	mac.Write(ciphertext)
+	// libsodium pads the data by its length modulo 16, rather than to a multiple of 16
+	mac.Write(make([]byte, len(ciphertext)&0xf))
+
+	var lengths [16]byte
+	binary.LittleEndian.PutUint64(lengths[8:], uint64(len(block)+len(ciphertext)))
+	mac.Write(lengths[:])
+
+	if subtle.ConstantTimeCompare(mac.Sum(nil), data[len(data)-secretStreamMACSize:]) != 1 {
+		return nil, errDecrypt
+	}
+	if tag != secretStreamTagFinal {
+		return nil, errors.New("secret stream is truncated or holds several messages")
+	}
+
+	plaintext := make([]byte, len(ciphertext))
+	stream.XORKeyStream(plaintext, ciphertext)
+	return plaintext, nil
+}
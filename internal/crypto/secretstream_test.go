@@ -0,0 +1,62 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestOpenSecretStream(t *testing.T) {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i)
+	}
+
+	// Streams written by libsodium's crypto_secretstream_xchacha20poly1305_push with TAG_FINAL
+	tests := []struct {
+		message    string
+		header     string
+		ciphertext string
+	}{
+		{"", "551257839e532cc4872031cd3279895ce2ebecdd3763357c", "033cbbf971fabf1e3c0a76aba6d0346130"},
+		{"hello", "d4972c97cce6da63590d52794d9802eace421c9a5c453602", "413dce5be7a43b78b6e8b9e008d479ef40c241363057"},
+		{strings.Repeat("x", 47), "146204643c6e036744a045fde3a73a90552331d782ca4b53", "5908a1320912da58413784ca0c05ba87647857fd08865bddd19598f426a9607b06fb9112440998574273d3b8042867ab382374ee8af506982754361f106731d5"},
+		{strings.Repeat("y", 100), "954184779b203779316590a8a287d9e6972273ee3492f811", "d3c238b4bae140cb2b02c508ad7227d140c24d906f4118f6729fad0a3ee5460f9f1b0d926725fcc155f4acd1e9f5abe7e5f41b0e7c7d002eaaf49a20586db9589d22e319d29ab2664a8520a2525aa509a0eba1fbc5b0f5127a7c4384d980fb2cd3fd33d5202902d3a8507accd4df98e3ea175f01ad"},
+	}
+	for _, tt := range tests {
+		header, _ := hex.DecodeString(tt.header)
+		ciphertext, _ := hex.DecodeString(tt.ciphertext)
+
+		plaintext, err := OpenSecretStream(key, header, ciphertext)
+		if err != nil {
+			t.Errorf("OpenSecretStream(%q) failed: %v", tt.message, err)
+			continue
+		}
+		if string(plaintext) != tt.message {
+			t.Errorf("OpenSecretStream = %q, want %q", plaintext, tt.message)
+		}
+
+		ciphertext[len(ciphertext)-1] ^= 1
+		if _, err = OpenSecretStream(key, header, ciphertext); err == nil {
+			t.Errorf("Expected a tampered stream to fail")
+		}
+	}
+}
+
+func TestSodiumKeyLimits(t *testing.T) {
+	salt := make([]byte, 16)
+	tests := []struct {
+		opsLimit, memLimit uint32
+	}{
+		{0, 64 * 1024},
+		{1, 1024},
+		{5, 1 << 30},
+		{1, 1<<30 + 1024},
+		{1 << 20, 64 * 1024},
+	}
+	for _, tt := range tests {
+		if _, err := SodiumKey("password", salt, tt.opsLimit, tt.memLimit); err == nil {
+			t.Errorf("Expected opsLimit %d and memLimit %d to be refused", tt.opsLimit, tt.memLimit)
+		}
+	}
+}
@@ -0,0 +1,452 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Key derivation functions
+const (
+	KDFArgon2id = "argon2id"
+	KDFPBKDF2   = "pbkdf2-sha256"
+)
+
+// Default Argon2id cost, the second recommended option of RFC 9106
+const (
+	DefaultArgon2Time    = 3
+	DefaultArgon2Memory  = 64 * 1024 // KiB
+	DefaultArgon2Threads = 4
+)
+
+// Highest costs accepted from files that are not the user's own vault, so
+// that a crafted header cannot hang the process or exhaust its memory. They
+// match libsodium's sensitive Argon2id preset; parallelism is bounded by
+// its single byte in the header.
+const (
+	maxArgon2Memory     = 1024 * 1024 // KiB
+	maxArgon2Work       = 4 * maxArgon2Memory
+	maxPBKDF2Iterations = 10000000
+)
+
+// legacyIterations is the PBKDF2 iteration count of vaults written before the header existed.
+const legacyIterations = 100000
+
+// KDFParams selects the function and cost used to derive a key from the master password.
+type KDFParams struct {
+	Algorithm string
+	Time      uint32 // Argon2id passes, or PBKDF2 iterations
+	Memory    uint32 // Argon2id memory in KiB
+	Threads   uint8  // Argon2id parallelism
+}
+
+// DefaultKDFParams returns the parameters used for new vaults.
+func DefaultKDFParams() KDFParams {
+	return KDFParams{
+		Algorithm: KDFArgon2id,
+		Time:      DefaultArgon2Time,
+		Memory:    DefaultArgon2Memory,
+		Threads:   DefaultArgon2Threads,
+	}
+}
+
+// LegacyKDFParams returns the parameters of vaults written before the header existed.
+func LegacyKDFParams() KDFParams {
+	return KDFParams{
+		Algorithm: KDFPBKDF2,
+		Time:      legacyIterations,
+	}
+}
+
+// Validate checks that the parameters are supported and not trivially weak.
+func (p KDFParams) Validate() error {
+	switch p.Algorithm {
+	case KDFArgon2id:
+		if p.Time < 1 {
+			return errors.New("argon2id time must be at least 1")
+		}
+		if p.Memory < 8*1024 {
+			return errors.New("argon2id memory must be at least 8 MiB")
+		}
+		if p.Threads < 1 {
+			return errors.New("argon2id parallelism must be at least 1")
+		}
+	case KDFPBKDF2:
+		if p.Time < 10000 {
+			return errors.New("pbkdf2 iterations must be at least 10000")
+		}
+	default:
+		return fmt.Errorf("unsupported key derivation function: %s", p.Algorithm)
+	}
+	return nil
+}
+
+// ValidateUntrusted checks the parameters as Validate does, and also refuses
+// costs above the limits accepted from files such as exports.
+func (p KDFParams) ValidateUntrusted() error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	switch p.Algorithm {
+	case KDFArgon2id:
+		if p.Memory > maxArgon2Memory {
+			return errors.New("argon2id memory must be at most 1 GiB")
+		}
+		if uint64(p.Time)*uint64(p.Memory) > maxArgon2Work {
+			return errors.New("argon2id time and memory are too high")
+		}
+	case KDFPBKDF2:
+		if p.Time > maxPBKDF2Iterations {
+			return fmt.Errorf("pbkdf2 iterations must be at most %d", maxPBKDF2Iterations)
+		}
+	}
+	return nil
+}
+
+// DeriveKey derives a 256-bit key from the password.
+func (p KDFParams) DeriveKey(password string, salt []byte) ([]byte, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	if p.Algorithm == KDFArgon2id {
+		return argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, keySize), nil
+	}
+	return pbkdf2.Key([]byte(password), salt, int(p.Time), keySize, sha256.New), nil
+}
+
+// Vault header layout:
+//
+//	magic        4 bytes  "2CLV"
+//	version      1 byte
+//	kdf          1 byte   kdfIDArgon2id or kdfIDPBKDF2
+//	time         4 bytes  big-endian
+//	memory       4 bytes  big-endian
+//	threads      1 byte
+//	salt        16 bytes
+//
+// Version 1 follows this with a 12-byte nonce and the data encrypted under
+// the key derived from the password. Version 2 follows it with the data key,
+// encrypted under the key derived from the password (60 bytes), and then the
+// data encrypted under the data key. The header is authenticated as
+// additional data in both cases.
+const (
+	vaultVersion1   = 1
+	vaultVersion    = 2
+	keySize         = 32
+	saltSize        = 16
+	nonceSize       = 12
+	tagSize         = 16
+	kdfHeaderSize   = 4 + 1 + 1 + 4 + 4 + 1 + saltSize
+	wrappedKeySize  = nonceSize + keySize + tagSize
+	vaultHeaderSize = kdfHeaderSize + wrappedKeySize
+
+	kdfIDArgon2id = 1
+	kdfIDPBKDF2   = 2
+)
+
+var vaultMagic = []byte("2CLV")
+
+var (
+	// ErrUnsupportedVault is returned for a vault written by a newer version of twocli.
+	ErrUnsupportedVault = errors.New("unsupported vault format version")
+
+	errDecrypt = errors.New("incorrect password or corrupted data")
+)
+
+// DataKey encrypts the vault and the secrets in it. It is generated at random
+// and stored in the vault encrypted under the key derived from the master password,
+// so the costly derivation runs once per unlock rather than once per record.
+type DataKey struct {
+	aead cipher.AEAD
+}
+
+func newDataKey(key []byte) (*DataKey, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return &DataKey{aead: aead}, nil
+}
+
+// Encrypt encrypts data with AES-256-GCM and returns nonce + ciphertext.
+func (k *DataKey) Encrypt(data []byte) ([]byte, error) {
+	return seal(k.aead, data, nil)
+}
+
+// Decrypt decrypts data produced by Encrypt.
+func (k *DataKey) Decrypt(data []byte) ([]byte, error) {
+	return open(k.aead, data, nil)
+}
+
+// VaultKey is an unlocked vault key: the data key together with its copy
+// encrypted under the master password.
+type VaultKey struct {
+	*DataKey
+
+	// header is the vault header up to and including the encrypted data key.
+	header []byte
+
+	// version is the format version the vault was read from. Vaults before
+	// version 2 have no data key, and their secrets are encrypted with EncryptData.
+	version int
+}
+
+// NewVaultKey generates a new data key and encrypts it under a key derived
+// from the password with the given parameters.
+func NewVaultKey(password string, kdf KDFParams) (*VaultKey, error) {
+	dataKey, raw, err := generateDataKey()
+	if err != nil {
+		return nil, err
+	}
+
+	k := &VaultKey{DataKey: dataKey, version: vaultVersion}
+	if err = k.wrap(raw, password, kdf); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// KDF returns the key derivation parameters protecting the data key.
+func (k *VaultKey) KDF() KDFParams {
+	kdf, _, _ := ReadVaultKDF(k.header)
+	return kdf
+}
+
+// Legacy reports whether the vault was read from a format without a data key,
+// in which case its secrets are still encrypted with EncryptData under the password.
+// Sealing the vault always writes the current format.
+func (k *VaultKey) Legacy() bool {
+	return k.version < vaultVersion
+}
+
+// Seal encrypts data under the data key and prefixes the vault header.
+func (k *VaultKey) Seal(data []byte) ([]byte, error) {
+	body, err := seal(k.aead, data, k.header)
+	if err != nil {
+		return nil, err
+	}
+	return append(bytes.Clone(k.header), body...), nil
+}
+
+// OpenVault decrypts a vault and returns its contents along with the unlocked
+// vault key. Vaults written by earlier versions are decrypted as well; they are
+// given a new data key, protected by the same password and key derivation
+// parameters, or the default ones for vaults written before the header existed.
+func OpenVault(data []byte, password string) ([]byte, *VaultKey, error) {
+	kdf, ok, err := ReadVaultKDF(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch {
+	case !ok:
+		plaintext, err := DecryptData(data, password)
+		if err != nil {
+			return nil, nil, err
+		}
+		k, err := NewVaultKey(password, DefaultKDFParams())
+		if err != nil {
+			return nil, nil, err
+		}
+		k.version = 0
+		return plaintext, k, nil
+
+	case data[4] == vaultVersion1:
+		return openVersion1(data, password, kdf)
+	}
+
+	if len(data) < vaultHeaderSize {
+		return nil, nil, errors.New("invalid data")
+	}
+
+	header := data[:vaultHeaderSize]
+	masterKey, err := kdf.DeriveKey(password, header[kdfHeaderSize-saltSize:kdfHeaderSize])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	masterAEAD, err := newGCM(masterKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	raw, err := open(masterAEAD, header[kdfHeaderSize:], header[:kdfHeaderSize])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dataKey, err := newDataKey(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	plaintext, err := open(dataKey.aead, data[vaultHeaderSize:], header)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	k := &VaultKey{DataKey: dataKey, header: bytes.Clone(header), version: vaultVersion}
+	return plaintext, k, nil
+}
+
+// openVersion1 decrypts a version 1 vault, whose data is encrypted directly
+// under the key derived from the password.
+func openVersion1(data []byte, password string, kdf KDFParams) ([]byte, *VaultKey, error) {
+	if len(data) < kdfHeaderSize+nonceSize {
+		return nil, nil, errors.New("invalid data")
+	}
+
+	header := data[:kdfHeaderSize+nonceSize]
+	masterKey, err := kdf.DeriveKey(password, header[kdfHeaderSize-saltSize:kdfHeaderSize])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	aesGCM, err := newGCM(masterKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	plaintext, err := aesGCM.Open(nil, header[kdfHeaderSize:], data[len(header):], header)
+	if err != nil {
+		return nil, nil, errDecrypt
+	}
+
+	// Reuse the derived key to protect a new data key
+	dataKey, raw, err := generateDataKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	k := &VaultKey{DataKey: dataKey, version: vaultVersion1}
+	if err = k.wrapWithKey(raw, masterKey, header[:kdfHeaderSize]); err != nil {
+		return nil, nil, err
+	}
+	return plaintext, k, nil
+}
+
+// ReadVaultKDF returns the key derivation parameters recorded in a vault header.
+// It reports false for a vault written before the header existed.
+func ReadVaultKDF(data []byte) (KDFParams, bool, error) {
+	if !bytes.HasPrefix(data, vaultMagic) {
+		return KDFParams{}, false, nil
+	}
+	if len(data) < kdfHeaderSize {
+		return KDFParams{}, false, errors.New("invalid data")
+	}
+	if data[4] != vaultVersion1 && data[4] != vaultVersion {
+		return KDFParams{}, false, ErrUnsupportedVault
+	}
+
+	kdf := KDFParams{
+		Time:    binary.BigEndian.Uint32(data[6:]),
+		Memory:  binary.BigEndian.Uint32(data[10:]),
+		Threads: data[14],
+	}
+	switch data[5] {
+	case kdfIDArgon2id:
+		kdf.Algorithm = KDFArgon2id
+	case kdfIDPBKDF2:
+		kdf.Algorithm = KDFPBKDF2
+	default:
+		return KDFParams{}, false, fmt.Errorf("unsupported key derivation function id %d", data[5])
+	}
+
+	return kdf, true, nil
+}
+
+// generateDataKey returns a new data key along with its raw bytes, for wrapping.
+func generateDataKey() (*DataKey, []byte, error) {
+	raw := make([]byte, keySize)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, nil, err
+	}
+	dataKey, err := newDataKey(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	return dataKey, raw, nil
+}
+
+// wrap builds the header with a new salt and the raw data key encrypted under
+// a key derived from the password.
+func (k *VaultKey) wrap(raw []byte, password string, kdf KDFParams) error {
+	if err := kdf.Validate(); err != nil {
+		return err
+	}
+
+	kdfHeader := make([]byte, kdfHeaderSize)
+	copy(kdfHeader, vaultMagic)
+	kdfHeader[5] = kdfIDArgon2id
+	if kdf.Algorithm == KDFPBKDF2 {
+		kdfHeader[5] = kdfIDPBKDF2
+	}
+	binary.BigEndian.PutUint32(kdfHeader[6:], kdf.Time)
+	binary.BigEndian.PutUint32(kdfHeader[10:], kdf.Memory)
+	kdfHeader[14] = kdf.Threads
+
+	salt := kdfHeader[kdfHeaderSize-saltSize:]
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+
+	masterKey, err := kdf.DeriveKey(password, salt)
+	if err != nil {
+		return err
+	}
+	return k.wrapWithKey(raw, masterKey, kdfHeader)
+}
+
+// wrapWithKey builds the header from the key derivation part of an existing
+// one and the raw data key encrypted under masterKey.
+func (k *VaultKey) wrapWithKey(raw, masterKey, kdfHeader []byte) error {
+	header := bytes.Clone(kdfHeader)
+	header[4] = vaultVersion
+
+	masterAEAD, err := newGCM(masterKey)
+	if err != nil {
+		return err
+	}
+	wrapped, err := seal(masterAEAD, raw, header)
+	if err != nil {
+		return err
+	}
+
+	k.header = append(header, wrapped...)
+	return nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts data with a random nonce and returns nonce + ciphertext.
+func seal(aead cipher.AEAD, data, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, nonceSize, nonceSize+len(data)+tagSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, data, additionalData), nil
+}
+
+// open decrypts nonce + ciphertext produced by seal.
+func open(aead cipher.AEAD, data, additionalData []byte) ([]byte, error) {
+	if len(data) < nonceSize+tagSize {
+		return nil, errors.New("invalid data")
+	}
+	plaintext, err := aead.Open(nil, data[:nonceSize], data[nonceSize:], additionalData)
+	if err != nil {
+		return nil, errDecrypt
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,207 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// testKDF is the cheapest Argon2id cost Validate accepts, to keep tests fast.
+var testKDF = KDFParams{Algorithm: KDFArgon2id, Time: 1, Memory: 8 * 1024, Threads: 1}
+
+// sealVersion1 writes a vault in the version 1 format, without a data key.
+func sealVersion1(t *testing.T, data []byte, password string, kdf KDFParams) []byte {
+	t.Helper()
+
+	header := make([]byte, kdfHeaderSize+nonceSize)
+	copy(header, vaultMagic)
+	header[4] = vaultVersion1
+	header[5] = kdfIDArgon2id
+	binary.BigEndian.PutUint32(header[6:], kdf.Time)
+	binary.BigEndian.PutUint32(header[10:], kdf.Memory)
+	header[14] = kdf.Threads
+	if _, err := rand.Read(header[15:]); err != nil {
+		t.Fatalf("Failed to generate salt and nonce: %v", err)
+	}
+
+	key, err := kdf.DeriveKey(password, header[15:kdfHeaderSize])
+	if err != nil {
+		t.Fatalf("DeriveKey() unexpected error = %v", err)
+	}
+	aesGCM, err := newGCM(key)
+	if err != nil {
+		t.Fatalf("newGCM() unexpected error = %v", err)
+	}
+	return aesGCM.Seal(bytes.Clone(header), header[kdfHeaderSize:], data, header)
+}
+
+func TestSealOpenVault(t *testing.T) {
+	password := "testpassword"
+	data := []byte(`[{"name":"TestAccount"}]`)
+
+	for _, kdf := range []KDFParams{testKDF, {Algorithm: KDFPBKDF2, Time: 10000}} {
+		t.Run(kdf.Algorithm, func(t *testing.T) {
+			key, err := NewVaultKey(password, kdf)
+			if err != nil {
+				t.Fatalf("NewVaultKey() unexpected error = %v", err)
+			}
+			sealed, err := key.Seal(data)
+			if err != nil {
+				t.Fatalf("Seal() unexpected error = %v", err)
+			}
+
+			opened, openedKey, err := OpenVault(sealed, password)
+			if err != nil {
+				t.Fatalf("OpenVault() unexpected error = %v", err)
+			}
+			if !bytes.Equal(opened, data) {
+				t.Fatalf("OpenVault() = %q, want %q", opened, data)
+			}
+			if openedKey.KDF() != kdf || openedKey.Legacy() {
+				t.Fatalf("OpenVault() key KDF = %+v legacy %v, want %+v", openedKey.KDF(), openedKey.Legacy(), kdf)
+			}
+
+			// Records encrypted before sealing decrypt with the unlocked key
+			record, err := key.Encrypt([]byte("JBSWY3DPEHPK3PXP"))
+			if err != nil {
+				t.Fatalf("Encrypt() unexpected error = %v", err)
+			}
+			if got, err := openedKey.Decrypt(record); err != nil || string(got) != "JBSWY3DPEHPK3PXP" {
+				t.Fatalf("Decrypt() = %q, %v", got, err)
+			}
+
+			if _, _, err = OpenVault(sealed, "wrongpassword"); err == nil {
+				t.Fatalf("OpenVault() should have failed with wrong password")
+			}
+		})
+	}
+}
+
+func TestOpenVaultLegacy(t *testing.T) {
+	password := "testpassword"
+	data := []byte("secret data")
+
+	legacy, err := EncryptData(data, password)
+	if err != nil {
+		t.Fatalf("EncryptData() unexpected error = %v", err)
+	}
+
+	if _, ok, err := ReadVaultKDF(legacy); ok || err != nil {
+		t.Fatalf("ReadVaultKDF() = %v, %v, want no header", ok, err)
+	}
+
+	opened, key, err := OpenVault(legacy, password)
+	if err != nil {
+		t.Fatalf("OpenVault() unexpected error = %v", err)
+	}
+	if !bytes.Equal(opened, data) {
+		t.Fatalf("OpenVault() = %q, want %q", opened, data)
+	}
+	if !key.Legacy() || key.KDF() != DefaultKDFParams() {
+		t.Fatalf("OpenVault() key KDF = %+v legacy %v, want default parameters and legacy", key.KDF(), key.Legacy())
+	}
+}
+
+func TestOpenVaultVersion1(t *testing.T) {
+	password := "testpassword"
+	data := []byte("secret data")
+
+	opened, key, err := OpenVault(sealVersion1(t, data, password, testKDF), password)
+	if err != nil {
+		t.Fatalf("OpenVault() unexpected error = %v", err)
+	}
+	if !bytes.Equal(opened, data) {
+		t.Fatalf("OpenVault() = %q, want %q", opened, data)
+	}
+	if !key.Legacy() || key.KDF() != testKDF {
+		t.Fatalf("OpenVault() key KDF = %+v legacy %v, want %+v and legacy", key.KDF(), key.Legacy(), testKDF)
+	}
+
+	// Sealing upgrades to the current format under the same password
+	sealed, err := key.Seal(data)
+	if err != nil {
+		t.Fatalf("Seal() unexpected error = %v", err)
+	}
+	if sealed[4] != vaultVersion {
+		t.Fatalf("Seal() version = %d, want %d", sealed[4], vaultVersion)
+	}
+	if _, key, err = OpenVault(sealed, password); err != nil || key.Legacy() {
+		t.Fatalf("OpenVault() after upgrade legacy = %v, error = %v", key != nil && key.Legacy(), err)
+	}
+}
+
+func TestOpenVaultTamperedHeader(t *testing.T) {
+	key, err := NewVaultKey("testpassword", testKDF)
+	if err != nil {
+		t.Fatalf("NewVaultKey() unexpected error = %v", err)
+	}
+	sealed, err := key.Seal([]byte("secret data"))
+	if err != nil {
+		t.Fatalf("Seal() unexpected error = %v", err)
+	}
+
+	for _, offset := range []int{15, kdfHeaderSize + 20, vaultHeaderSize + 20} {
+		tampered := bytes.Clone(sealed)
+		tampered[offset] ^= 1
+		if _, _, err = OpenVault(tampered, "testpassword"); err == nil {
+			t.Fatalf("OpenVault() should have failed with byte %d changed", offset)
+		}
+	}
+
+	newer := bytes.Clone(sealed)
+	newer[4] = vaultVersion + 1
+	if _, _, err = OpenVault(newer, "testpassword"); !errors.Is(err, ErrUnsupportedVault) {
+		t.Fatalf("OpenVault() error = %v, want ErrUnsupportedVault", err)
+	}
+}
+
+func TestKDFParamsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		kdf     KDFParams
+		wantErr bool
+	}{
+		{"Default", DefaultKDFParams(), false},
+		{"Legacy", LegacyKDFParams(), false},
+		{"Minimum argon2id", testKDF, false},
+		{"Zero time", KDFParams{Algorithm: KDFArgon2id, Time: 0, Memory: 65536, Threads: 1}, true},
+		{"Too little memory", KDFParams{Algorithm: KDFArgon2id, Time: 1, Memory: 1024, Threads: 1}, true},
+		{"Zero threads", KDFParams{Algorithm: KDFArgon2id, Time: 1, Memory: 65536}, true},
+		{"Too few iterations", KDFParams{Algorithm: KDFPBKDF2, Time: 1000}, true},
+		{"Unknown algorithm", KDFParams{Algorithm: "scrypt", Time: 1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.kdf.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKDFParamsValidateUntrusted(t *testing.T) {
+	tests := []struct {
+		name    string
+		kdf     KDFParams
+		wantErr bool
+	}{
+		{"Default", DefaultKDFParams(), false},
+		{"Legacy", LegacyKDFParams(), false},
+		{"Sensitive argon2id", KDFParams{Algorithm: KDFArgon2id, Time: 4, Memory: 1024 * 1024, Threads: 255}, false},
+		{"Too weak", KDFParams{Algorithm: KDFArgon2id, Time: 0, Memory: 65536, Threads: 1}, true},
+		{"Too much memory", KDFParams{Algorithm: KDFArgon2id, Time: 1, Memory: 2 * 1024 * 1024, Threads: 1}, true},
+		{"Too much work", KDFParams{Algorithm: KDFArgon2id, Time: 1 << 20, Memory: 8 * 1024, Threads: 1}, true},
+		{"Too many iterations", KDFParams{Algorithm: KDFPBKDF2, Time: 1 << 30}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.kdf.ValidateUntrusted(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUntrusted() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,364 @@
+package qr
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// grid is a square matrix of modules indexed [row][column], true for dark.
+type grid [][]bool
+
+func (g grid) size() int {
+	return len(g)
+}
+
+// transpose returns the grid mirrored along its main diagonal.
+func (g grid) transpose() grid {
+	t := make(grid, len(g))
+	for y := range t {
+		t[y] = make([]bool, len(g))
+		for x := range t[y] {
+			t[y][x] = g[x][y]
+		}
+	}
+	return t
+}
+
+// readFormat reads the level and mask from either copy of the format information.
+func (g grid) readFormat() (Level, int, error) {
+	size := g.size()
+	bit := func(x, y int) int {
+		if g[y][x] {
+			return 1
+		}
+		return 0
+	}
+
+	first := 0
+	for i := 0; i <= 5; i++ {
+		first |= bit(8, i) << i
+	}
+	first |= bit(8, 7) << 6
+	first |= bit(8, 8) << 7
+	first |= bit(7, 8) << 8
+	for i := 9; i < 15; i++ {
+		first |= bit(14-i, 8) << i
+	}
+
+	second := 0
+	for i := 0; i < 8; i++ {
+		second |= bit(size-1-i, 8) << i
+	}
+	for i := 8; i < 15; i++ {
+		second |= bit(8, size-15+i) << i
+	}
+
+	// Pick the valid format closest to either copy
+	bestDistance, bestLevel, bestMask := 16, L, 0
+	for level := L; level <= H; level++ {
+		for mask := 0; mask < 8; mask++ {
+			info := formatInfo(level, mask)
+			for _, read := range []int{first, second} {
+				if d := bitCount(info ^ read); d < bestDistance {
+					bestDistance, bestLevel, bestMask = d, level, mask
+				}
+			}
+		}
+	}
+	if bestDistance > 3 {
+		return 0, 0, errors.New("unreadable format information")
+	}
+
+	return bestLevel, bestMask, nil
+}
+
+// readVersion reads the version information of symbols of version 7 and up.
+// It returns zero when neither copy is readable.
+func (g grid) readVersion() int {
+	size := g.size()
+	first, second := 0, 0
+	for i := 0; i < 18; i++ {
+		a, b := size-11+i%3, i/3
+		if g[b][a] {
+			first |= 1 << i
+		}
+		if g[a][b] {
+			second |= 1 << i
+		}
+	}
+
+	bestDistance, bestVersion := 4, 0
+	for version := 7; version <= maxVersion; version++ {
+		info := versionInfo(version)
+		for _, read := range []int{first, second} {
+			if d := bitCount(info ^ read); d < bestDistance {
+				bestDistance, bestVersion = d, version
+			}
+		}
+	}
+	return bestVersion
+}
+
+// decodeGrid decodes the contents of a sampled symbol.
+func decodeGrid(g grid) (string, error) {
+	size := g.size()
+	if size < symbolSize(minVersion) || size > symbolSize(maxVersion) || (size-17)%4 != 0 {
+		return "", fmt.Errorf("invalid symbol size %d", size)
+	}
+	version := (size - 17) / 4
+
+	level, mask, err := g.readFormat()
+	if err != nil {
+		return "", err
+	}
+
+	codewords := g.readCodewords(version, mask)
+	data, err := correctBlocks(codewords, version, level)
+	if err != nil {
+		return "", err
+	}
+
+	return decodeSegments(data, version)
+}
+
+// readCodewords reads the unmasked codewords in the zigzag placement order.
+func (g grid) readCodewords(version, mask int) []byte {
+	size := g.size()
+	function := functionMask(version)
+	codewords := make([]byte, rawCodewords(version))
+
+	bitIndex := 0
+	for right := size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				upward := (right+1)&2 == 0
+				y := vert
+				if upward {
+					y = size - 1 - vert
+				}
+				if function[y][x] || bitIndex >= len(codewords)*8 {
+					continue
+				}
+				if g[y][x] != maskBit(mask, x, y) {
+					codewords[bitIndex>>3] |= 1 << (7 - bitIndex&7)
+				}
+				bitIndex++
+			}
+		}
+	}
+
+	return codewords
+}
+
+// correctBlocks de-interleaves the codewords into blocks, corrects them and
+// returns the concatenated data codewords.
+func correctBlocks(codewords []byte, version int, level Level) ([]byte, error) {
+	layout := layoutFor(version, level)
+	blocks := make([][]byte, layout.numBlocks)
+	for i := range blocks {
+		blocks[i] = make([]byte, 0, layout.dataLen(i)+layout.ecLen)
+	}
+
+	// Data codewords are interleaved first, then the error correction codewords
+	index := 0
+	for i := 0; i <= layout.shortDataLen; i++ {
+		for b := range blocks {
+			if i < layout.dataLen(b) {
+				blocks[b] = append(blocks[b], codewords[index])
+				index++
+			}
+		}
+	}
+	for i := 0; i < layout.ecLen; i++ {
+		for b := range blocks {
+			blocks[b] = append(blocks[b], codewords[index])
+			index++
+		}
+	}
+
+	data := make([]byte, 0, dataCodewords(version, level))
+	for b, block := range blocks {
+		if err := rsCorrect(block, layout.ecLen); err != nil {
+			return nil, err
+		}
+		data = append(data, block[:layout.dataLen(b)]...)
+	}
+
+	return data, nil
+}
+
+// bitReader reads big-endian bit fields from a byte slice.
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) available() int {
+	return len(r.data)*8 - r.pos
+}
+
+func (r *bitReader) read(n int) (int, error) {
+	if n > r.available() {
+		return 0, errors.New("unexpected end of data")
+	}
+	v := 0
+	for i := 0; i < n; i++ {
+		bit := r.data[r.pos>>3] >> (7 - r.pos&7) & 1
+		v = v<<1 | int(bit)
+		r.pos++
+	}
+	return v, nil
+}
+
+// Segment modes
+const (
+	modeTerminator       = 0x0
+	modeNumeric          = 0x1
+	modeAlphanumeric     = 0x2
+	modeStructuredAppend = 0x3
+	modeByte             = 0x4
+	modeFNC1First        = 0x5
+	modeECI              = 0x7
+	modeKanji            = 0x8
+	modeFNC1Second       = 0x9
+)
+
+const alphanumericCharset = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+// charCountBits returns the width of the character count field of a mode.
+func charCountBits(mode, version int) int {
+	var widths [3]int
+	switch mode {
+	case modeNumeric:
+		widths = [3]int{10, 12, 14}
+	case modeAlphanumeric:
+		widths = [3]int{9, 11, 13}
+	case modeByte:
+		widths = [3]int{8, 16, 16}
+	case modeKanji:
+		widths = [3]int{8, 10, 12}
+	}
+	switch {
+	case version <= 9:
+		return widths[0]
+	case version <= 26:
+		return widths[1]
+	default:
+		return widths[2]
+	}
+}
+
+// decodeSegments decodes the data segments of a corrected bit stream.
+func decodeSegments(data []byte, version int) (string, error) {
+	r := &bitReader{data: data}
+	var out strings.Builder
+
+	for r.available() >= 4 {
+		mode, _ := r.read(4)
+		switch mode {
+		case modeTerminator:
+			return out.String(), nil
+		case modeStructuredAppend:
+			if _, err := r.read(16); err != nil {
+				return "", err
+			}
+		case modeFNC1First:
+		case modeFNC1Second:
+			if _, err := r.read(8); err != nil {
+				return "", err
+			}
+		case modeECI:
+			// The designator is 1, 2 or 3 bytes long depending on its leading bits
+			first, err := r.read(8)
+			if err != nil {
+				return "", err
+			}
+			switch {
+			case first&0x80 == 0:
+			case first&0xc0 == 0x80:
+				_, err = r.read(8)
+			default:
+				_, err = r.read(16)
+			}
+			if err != nil {
+				return "", err
+			}
+		case modeNumeric, modeAlphanumeric, modeByte, modeKanji:
+			count, err := r.read(charCountBits(mode, version))
+			if err != nil {
+				return "", err
+			}
+			if err = decodeSegment(r, mode, count, &out); err != nil {
+				return "", err
+			}
+		default:
+			return "", fmt.Errorf("unsupported segment mode %d", mode)
+		}
+	}
+
+	return out.String(), nil
+}
+
+func decodeSegment(r *bitReader, mode, count int, out *strings.Builder) error {
+	switch mode {
+	case modeNumeric:
+		for count > 0 {
+			digits := min(count, 3)
+			v, err := r.read([]int{0, 4, 7, 10}[digits])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "%0*d", digits, v)
+			count -= digits
+		}
+	case modeAlphanumeric:
+		for count > 0 {
+			if count == 1 {
+				v, err := r.read(6)
+				if err != nil || v >= len(alphanumericCharset) {
+					return errors.New("invalid alphanumeric segment")
+				}
+				out.WriteByte(alphanumericCharset[v])
+				count--
+				continue
+			}
+			v, err := r.read(11)
+			if err != nil || v/45 >= len(alphanumericCharset) {
+				return errors.New("invalid alphanumeric segment")
+			}
+			out.WriteByte(alphanumericCharset[v/45])
+			out.WriteByte(alphanumericCharset[v%45])
+			count -= 2
+		}
+	case modeByte:
+		for i := 0; i < count; i++ {
+			v, err := r.read(8)
+			if err != nil {
+				return err
+			}
+			out.WriteByte(byte(v))
+		}
+	case modeKanji:
+		// Kanji characters are written back as their Shift JIS bytes
+		for i := 0; i < count; i++ {
+			v, err := r.read(13)
+			if err != nil {
+				return err
+			}
+			sjis := (v/0xc0)<<8 | v%0xc0
+			if sjis < 0x1f00 {
+				sjis += 0x8140
+			} else {
+				sjis += 0xc140
+			}
+			out.WriteByte(byte(sjis >> 8))
+			out.WriteByte(byte(sjis))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,682 @@
+package qr
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// bitmap is a binarized image, true for dark pixels.
+type bitmap struct {
+	width, height int
+	dark          []bool
+}
+
+func (b *bitmap) at(x, y int) bool {
+	if x < 0 || y < 0 || x >= b.width || y >= b.height {
+		return false
+	}
+	return b.dark[y*b.width+x]
+}
+
+// luminance converts an image to 8-bit luminance, compositing transparent pixels over white.
+func luminance(img image.Image) ([]uint8, int, int) {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	lum := make([]uint8, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, b, a := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			// Premultiplied components over a white background
+			white := 0xffff - a
+			r, g, b = r+white, g+white, b+white
+			lum[y*w+x] = uint8((19595*r + 38470*g + 7471*b + 1<<15) >> 24)
+		}
+	}
+	return lum, w, h
+}
+
+// globalBitmap binarizes with a single threshold chosen by Otsu's method.
+func globalBitmap(lum []uint8, w, h int) *bitmap {
+	var histogram [256]int
+	for _, v := range lum {
+		histogram[v]++
+	}
+
+	total := len(lum)
+	sum := 0
+	for i, n := range histogram {
+		sum += i * n
+	}
+
+	sumBackground, weightBackground := 0, 0
+	bestVariance, threshold := 0.0, 127
+	for t := 0; t < 256; t++ {
+		weightBackground += histogram[t]
+		if weightBackground == 0 {
+			continue
+		}
+		weightForeground := total - weightBackground
+		if weightForeground == 0 {
+			break
+		}
+		sumBackground += t * histogram[t]
+		meanBackground := float64(sumBackground) / float64(weightBackground)
+		meanForeground := float64(sum-sumBackground) / float64(weightForeground)
+		variance := float64(weightBackground) * float64(weightForeground) * (meanBackground - meanForeground) * (meanBackground - meanForeground)
+		if variance > bestVariance {
+			bestVariance, threshold = variance, t
+		}
+	}
+
+	b := &bitmap{width: w, height: h, dark: make([]bool, len(lum))}
+	for i, v := range lum {
+		b.dark[i] = int(v) <= threshold
+	}
+	return b
+}
+
+// adaptiveBitmap binarizes against the mean of each pixel's neighbourhood,
+// which copes with uneven lighting in photos.
+func adaptiveBitmap(lum []uint8, w, h int) *bitmap {
+	// Integral image for constant-time window sums
+	integral := make([]int, (w+1)*(h+1))
+	for y := 0; y < h; y++ {
+		rowSum := 0
+		for x := 0; x < w; x++ {
+			rowSum += int(lum[y*w+x])
+			integral[(y+1)*(w+1)+x+1] = integral[y*(w+1)+x+1] + rowSum
+		}
+	}
+
+	radius := max(min(w, h)/16, 4)
+	b := &bitmap{width: w, height: h, dark: make([]bool, len(lum))}
+	for y := 0; y < h; y++ {
+		y0, y1 := max(y-radius, 0), min(y+radius+1, h)
+		for x := 0; x < w; x++ {
+			x0, x1 := max(x-radius, 0), min(x+radius+1, w)
+			area := (x1 - x0) * (y1 - y0)
+			sum := integral[y1*(w+1)+x1] - integral[y0*(w+1)+x1] - integral[y1*(w+1)+x0] + integral[y0*(w+1)+x0]
+			// Pixels must be clearly darker than their surroundings
+			b.dark[y*w+x] = int(lum[y*w+x])*area*100 < sum*90
+		}
+	}
+	return b
+}
+
+// finderPattern is a candidate finder pattern center.
+type finderPattern struct {
+	x, y       float64
+	moduleSize float64
+	count      int
+}
+
+func distance(ax, ay, bx, by float64) float64 {
+	return math.Hypot(ax-bx, ay-by)
+}
+
+// foundPatternCross reports whether five run lengths match the 1:1:3:1:1 finder ratio.
+func foundPatternCross(counts [5]int) bool {
+	total := 0
+	for _, c := range counts {
+		if c == 0 {
+			return false
+		}
+		total += c
+	}
+	if total < 7 {
+		return false
+	}
+
+	module := float64(total) / 7
+	variance := module / 2
+	return math.Abs(module-float64(counts[0])) < variance &&
+		math.Abs(module-float64(counts[1])) < variance &&
+		math.Abs(3*module-float64(counts[2])) < 3*variance &&
+		math.Abs(module-float64(counts[3])) < variance &&
+		math.Abs(module-float64(counts[4])) < variance
+}
+
+// crossCheck measures the finder runs through (x, y) along the direction
+// (dx, dy) and returns the refined center coordinate along that axis and the run total.
+func crossCheck(b *bitmap, x, y, dx, dy, maxCount, originalTotal int) (float64, int, bool) {
+	var counts [5]int
+	pos := func(i int) (int, int) { return x + i*dx, y + i*dy }
+	inside := func(i int) bool {
+		px, py := pos(i)
+		return px >= 0 && py >= 0 && px < b.width && py < b.height
+	}
+	dark := func(i int) bool {
+		px, py := pos(i)
+		return b.at(px, py)
+	}
+
+	// Walk backwards through the center, light and outer dark runs
+	i := 0
+	for inside(i) && dark(i) {
+		counts[2]++
+		i--
+	}
+	if !inside(i) {
+		return 0, 0, false
+	}
+	for inside(i) && !dark(i) && counts[1] <= maxCount {
+		counts[1]++
+		i--
+	}
+	if !inside(i) || counts[1] > maxCount {
+		return 0, 0, false
+	}
+	for inside(i) && dark(i) && counts[0] <= maxCount {
+		counts[0]++
+		i--
+	}
+	if counts[0] > maxCount {
+		return 0, 0, false
+	}
+
+	// Then forwards
+	i = 1
+	for inside(i) && dark(i) {
+		counts[2]++
+		i++
+	}
+	if !inside(i) {
+		return 0, 0, false
+	}
+	for inside(i) && !dark(i) && counts[3] <= maxCount {
+		counts[3]++
+		i++
+	}
+	if !inside(i) || counts[3] > maxCount {
+		return 0, 0, false
+	}
+	for inside(i) && dark(i) && counts[4] <= maxCount {
+		counts[4]++
+		i++
+	}
+	if counts[4] > maxCount {
+		return 0, 0, false
+	}
+
+	total := counts[0] + counts[1] + counts[2] + counts[3] + counts[4]
+	if 5*abs(total-originalTotal) >= 2*originalTotal || !foundPatternCross(counts) {
+		return 0, 0, false
+	}
+
+	end := i
+	center := float64(end-counts[4]-counts[3]) - float64(counts[2])/2
+	start := x
+	if dy != 0 {
+		start = y
+	}
+	return float64(start) + center, total, true
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// findFinderPatterns scans every row for 1:1:3:1:1 runs and confirms them vertically and horizontally.
+func findFinderPatterns(b *bitmap) []finderPattern {
+	var patterns []finderPattern
+
+	handle := func(counts [5]int, end, y int) {
+		total := counts[0] + counts[1] + counts[2] + counts[3] + counts[4]
+		centerX := float64(end-counts[4]-counts[3]) - float64(counts[2])/2
+
+		centerY, vTotal, ok := crossCheck(b, int(centerX), y, 0, 1, counts[2], total)
+		if !ok {
+			return
+		}
+		centerX, hTotal, ok := crossCheck(b, int(centerX), int(centerY), 1, 0, counts[2], total)
+		if !ok {
+			return
+		}
+		moduleSize := float64(vTotal+hTotal) / 14
+
+		for i := range patterns {
+			p := &patterns[i]
+			if math.Abs(p.x-centerX) <= moduleSize && math.Abs(p.y-centerY) <= moduleSize &&
+				math.Abs(p.moduleSize-moduleSize) <= math.Max(1, p.moduleSize/2) {
+				// Merge into the running average of the existing candidate
+				n := float64(p.count)
+				p.x = (p.x*n + centerX) / (n + 1)
+				p.y = (p.y*n + centerY) / (n + 1)
+				p.moduleSize = (p.moduleSize*n + moduleSize) / (n + 1)
+				p.count++
+				return
+			}
+		}
+		patterns = append(patterns, finderPattern{x: centerX, y: centerY, moduleSize: moduleSize, count: 1})
+	}
+
+	for y := 0; y < b.height; y++ {
+		var counts [5]int
+		state := 0
+		for x := 0; x < b.width; x++ {
+			dark := b.at(x, y)
+			switch {
+			case dark:
+				if state&1 == 1 {
+					state++
+				}
+				counts[state]++
+			case state == 0 && counts[0] == 0:
+				// Skip leading light pixels
+			case state&1 == 1:
+				counts[state]++
+			case state < 4:
+				state++
+				counts[state]++
+			default:
+				if foundPatternCross(counts) {
+					handle(counts, x, y)
+					counts = [5]int{}
+					state = 0
+					continue
+				}
+				counts = [5]int{counts[2], counts[3], counts[4], 1, 0}
+				state = 3
+			}
+		}
+		if state == 4 && foundPatternCross(counts) {
+			handle(counts, b.width, y)
+		}
+	}
+
+	// Candidates seen on a single row are usually noise
+	confirmed := patterns[:0:0]
+	for _, p := range patterns {
+		if p.count >= 2 {
+			confirmed = append(confirmed, p)
+		}
+	}
+	if len(confirmed) >= 3 {
+		patterns = confirmed
+	}
+
+	// Keep the strongest candidates to bound the number of triples
+	sort.SliceStable(patterns, func(i, j int) bool { return patterns[i].count > patterns[j].count })
+	if len(patterns) > 30 {
+		patterns = patterns[:30]
+	}
+	return patterns
+}
+
+// triple is a candidate set of finder patterns for one symbol.
+type triple struct {
+	indices [3]int
+	score   float64
+}
+
+// findTriples returns the sets of three finder patterns that form a plausible
+// symbol corner, best first.
+func findTriples(patterns []finderPattern) []triple {
+	var triples []triple
+	for i := 0; i < len(patterns); i++ {
+		for j := i + 1; j < len(patterns); j++ {
+			for k := j + 1; k < len(patterns); k++ {
+				a, b, c := patterns[i], patterns[j], patterns[k]
+
+				minModule := math.Min(a.moduleSize, math.Min(b.moduleSize, c.moduleSize))
+				maxModule := math.Max(a.moduleSize, math.Max(b.moduleSize, c.moduleSize))
+				if maxModule > 1.4*minModule {
+					continue
+				}
+
+				sides := []float64{
+					distance(a.x, a.y, b.x, b.y),
+					distance(b.x, b.y, c.x, c.y),
+					distance(a.x, a.y, c.x, c.y),
+				}
+				sort.Float64s(sides)
+				leg1, leg2, hypotenuse := sides[0], sides[1], sides[2]
+
+				// The legs of an isosceles right triangle are equal and
+				// the hypotenuse follows Pythagoras
+				legRatio := leg1 / leg2
+				right := math.Abs(1 - hypotenuse*hypotenuse/(leg1*leg1+leg2*leg2))
+				if legRatio < 0.7 || right > 0.2 {
+					continue
+				}
+
+				module := (a.moduleSize + b.moduleSize + c.moduleSize) / 3
+				dimension := leg2/module + 7
+				if dimension < 17 || dimension > float64(symbolSize(maxVersion))+8 {
+					continue
+				}
+
+				triples = append(triples, triple{
+					indices: [3]int{i, j, k},
+					score:   (1 - legRatio) + right,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(triples, func(i, j int) bool { return triples[i].score < triples[j].score })
+	return triples
+}
+
+// orient returns the top-left, top-right and bottom-left patterns of a triple.
+func orient(a, b, c finderPattern) (finderPattern, finderPattern, finderPattern) {
+	ab := distance(a.x, a.y, b.x, b.y)
+	bc := distance(b.x, b.y, c.x, c.y)
+	ac := distance(a.x, a.y, c.x, c.y)
+
+	// The top-left pattern is opposite the hypotenuse
+	var topLeft, p1, p2 finderPattern
+	switch {
+	case bc >= ab && bc >= ac:
+		topLeft, p1, p2 = a, b, c
+	case ac >= ab && ac >= bc:
+		topLeft, p1, p2 = b, a, c
+	default:
+		topLeft, p1, p2 = c, a, b
+	}
+
+	// With y pointing down, top-right to bottom-left turns clockwise around top-left
+	cross := (p1.x-topLeft.x)*(p2.y-topLeft.y) - (p1.y-topLeft.y)*(p2.x-topLeft.x)
+	if cross < 0 {
+		p1, p2 = p2, p1
+	}
+	return topLeft, p1, p2
+}
+
+// estimateDimensions returns the likely symbol sizes for three oriented patterns, most likely first.
+func estimateDimensions(topLeft, topRight, bottomLeft finderPattern) []int {
+	module := (topLeft.moduleSize + topRight.moduleSize + bottomLeft.moduleSize) / 3
+	width := math.Round(distance(topLeft.x, topLeft.y, topRight.x, topRight.y) / module)
+	height := math.Round(distance(topLeft.x, topLeft.y, bottomLeft.x, bottomLeft.y) / module)
+	dimension := int((width+height)/2) + 7
+
+	var candidates []int
+	switch dimension & 3 {
+	case 0:
+		candidates = []int{dimension + 1, dimension - 3}
+	case 1:
+		candidates = []int{dimension, dimension - 4, dimension + 4}
+	case 2:
+		candidates = []int{dimension - 1, dimension + 3}
+	default:
+		candidates = []int{dimension - 2, dimension + 2}
+	}
+
+	valid := candidates[:0]
+	for _, d := range candidates {
+		if d >= symbolSize(minVersion) && d <= symbolSize(maxVersion) {
+			valid = append(valid, d)
+		}
+	}
+	return valid
+}
+
+// transform is a projective mapping between two planes.
+type transform [3][3]float64
+
+// squareToQuad maps the unit square onto the quadrilateral with the given corners,
+// in the order (0,0), (1,0), (1,1), (0,1).
+func squareToQuad(x0, y0, x1, y1, x2, y2, x3, y3 float64) transform {
+	dx3 := x0 - x1 + x2 - x3
+	dy3 := y0 - y1 + y2 - y3
+	if dx3 == 0 && dy3 == 0 {
+		return transform{
+			{x1 - x0, x2 - x1, x0},
+			{y1 - y0, y2 - y1, y0},
+			{0, 0, 1},
+		}
+	}
+
+	dx1, dx2 := x1-x2, x3-x2
+	dy1, dy2 := y1-y2, y3-y2
+	denominator := dx1*dy2 - dx2*dy1
+	g := (dx3*dy2 - dx2*dy3) / denominator
+	h := (dx1*dy3 - dx3*dy1) / denominator
+	return transform{
+		{x1 - x0 + g*x1, x3 - x0 + h*x3, x0},
+		{y1 - y0 + g*y1, y3 - y0 + h*y3, y0},
+		{g, h, 1},
+	}
+}
+
+// adjugate returns a matrix proportional to the inverse, which is enough for projective mappings.
+func (m transform) adjugate() transform {
+	return transform{
+		{m[1][1]*m[2][2] - m[1][2]*m[2][1], m[0][2]*m[2][1] - m[0][1]*m[2][2], m[0][1]*m[1][2] - m[0][2]*m[1][1]},
+		{m[1][2]*m[2][0] - m[1][0]*m[2][2], m[0][0]*m[2][2] - m[0][2]*m[2][0], m[0][2]*m[1][0] - m[0][0]*m[1][2]},
+		{m[1][0]*m[2][1] - m[1][1]*m[2][0], m[0][1]*m[2][0] - m[0][0]*m[2][1], m[0][0]*m[1][1] - m[0][1]*m[1][0]},
+	}
+}
+
+func (m transform) times(o transform) transform {
+	var r transform
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				r[i][j] += m[i][k] * o[k][j]
+			}
+		}
+	}
+	return r
+}
+
+func (m transform) apply(x, y float64) (float64, float64) {
+	w := m[2][0]*x + m[2][1]*y + m[2][2]
+	return (m[0][0]*x + m[0][1]*y + m[0][2]) / w, (m[1][0]*x + m[1][1]*y + m[1][2]) / w
+}
+
+// quadToQuad maps the quadrilateral src onto dst, both given as four corners in the same order.
+func quadToQuad(src, dst [4][2]float64) transform {
+	toSquare := squareToQuad(src[0][0], src[0][1], src[1][0], src[1][1], src[2][0], src[2][1], src[3][0], src[3][1]).adjugate()
+	fromSquare := squareToQuad(dst[0][0], dst[0][1], dst[1][0], dst[1][1], dst[2][0], dst[2][1], dst[3][0], dst[3][1])
+	return fromSquare.times(toSquare)
+}
+
+// findAlignment looks for the bottom-right alignment pattern near the estimated
+// image position and returns its center.
+func findAlignment(b *bitmap, estX, estY, module float64) (float64, float64, bool) {
+	for _, allowance := range []float64{4, 8, 16} {
+		radius := allowance * module
+		x0 := max(int(estX-radius), 0)
+		x1 := min(int(estX+radius), b.width-1)
+		y0 := max(int(estY-radius), 0)
+		y1 := min(int(estY+radius), b.height-1)
+		if x1-x0 < int(3*module) || y1-y0 < int(3*module) {
+			continue
+		}
+
+		bestDistance := math.Inf(1)
+		var bestX, bestY float64
+		for y := y0; y <= y1; y++ {
+			for _, cx := range alignmentCentersInRow(b, x0, x1, y, module) {
+				cy, ok := alignmentCrossCheck(b, int(cx), y, module)
+				if !ok {
+					continue
+				}
+				if d := distance(cx, cy, estX, estY); d < bestDistance {
+					bestDistance, bestX, bestY = d, cx, cy
+				}
+			}
+		}
+		if !math.IsInf(bestDistance, 1) {
+			return bestX, bestY, true
+		}
+	}
+	return 0, 0, false
+}
+
+// closeToModule reports whether a run is roughly one module long.
+func closeToModule(run int, module float64) bool {
+	return math.Abs(float64(run)-module) < module/2+1
+}
+
+// alignmentCentersInRow returns the centers of dark-light-dark-light-dark runs
+// whose inner three runs are one module each. The outer dark runs may continue
+// into neighbouring modules, so only their presence is checked.
+func alignmentCentersInRow(b *bitmap, x0, x1, y int, module float64) []float64 {
+	type run struct {
+		start, length int
+		dark          bool
+	}
+	var runs []run
+	for x := x0; x <= x1; x++ {
+		dark := b.at(x, y)
+		if len(runs) > 0 && runs[len(runs)-1].dark == dark {
+			runs[len(runs)-1].length++
+		} else {
+			runs = append(runs, run{start: x, length: 1, dark: dark})
+		}
+	}
+
+	var centers []float64
+	for i := 2; i+2 < len(runs); i++ {
+		if !runs[i].dark {
+			continue
+		}
+		if closeToModule(runs[i-1].length, module) && closeToModule(runs[i].length, module) && closeToModule(runs[i+1].length, module) {
+			centers = append(centers, float64(runs[i].start)+float64(runs[i].length)/2)
+		}
+	}
+	return centers
+}
+
+// alignmentCrossCheck confirms an alignment pattern vertically and returns its center row.
+func alignmentCrossCheck(b *bitmap, x, y int, module float64) (float64, bool) {
+	maxRun := int(2 * module)
+	runLength := func(start, step int, dark bool) (int, int) {
+		n := 0
+		for yy := start; yy >= 0 && yy < b.height && b.at(x, yy) == dark && n <= maxRun; yy += step {
+			n++
+		}
+		return n, start + n*step
+	}
+
+	up, next := runLength(y, -1, true)
+	lightUp, next := runLength(next, -1, false)
+	darkUp, _ := runLength(next, -1, true)
+	down, next := runLength(y+1, 1, true)
+	lightDown, next := runLength(next, 1, false)
+	darkDown, _ := runLength(next, 1, true)
+
+	center := up + down
+	if !closeToModule(center, module) || !closeToModule(lightUp, module) || !closeToModule(lightDown, module) ||
+		darkUp == 0 || darkDown == 0 {
+		return 0, false
+	}
+	return float64(y-up+1) + float64(center)/2, true
+}
+
+// sampleGrid samples a symbol of the given size through the mapping from module to image coordinates.
+func sampleGrid(b *bitmap, m transform, size int) (grid, bool) {
+	g := make(grid, size)
+	for y := 0; y < size; y++ {
+		g[y] = make([]bool, size)
+		for x := 0; x < size; x++ {
+			px, py := m.apply(float64(x)+0.5, float64(y)+0.5)
+			if math.IsNaN(px) || math.IsNaN(py) || px < -1 || py < -1 || px > float64(b.width) || py > float64(b.height) {
+				return nil, false
+			}
+			g[y][x] = b.at(int(px), int(py))
+		}
+	}
+	return g, true
+}
+
+// decodeTriple samples and decodes the symbol whose finder patterns are given.
+func decodeTriple(b *bitmap, a, c, d finderPattern) (string, bool) {
+	topLeft, topRight, bottomLeft := orient(a, c, d)
+	module := (topLeft.moduleSize + topRight.moduleSize + bottomLeft.moduleSize) / 3
+
+	tried := map[int]bool{}
+	dimensions := estimateDimensions(topLeft, topRight, bottomLeft)
+	for i := 0; i < len(dimensions); i++ {
+		size := dimensions[i]
+		if tried[size] {
+			continue
+		}
+		tried[size] = true
+
+		// Fall back to the three finder patterns alone in case the alignment
+		// pattern search locked onto the wrong modules
+		for _, useAlignment := range []bool{true, false} {
+			g, ok := sampleSymbol(b, topLeft, topRight, bottomLeft, module, size, useAlignment)
+			if !ok {
+				continue
+			}
+
+			// Larger versions record their version, which corrects a wrong size estimate
+			if size >= symbolSize(7) {
+				if version := g.readVersion(); version != 0 && symbolSize(version) != size {
+					dimensions = append(dimensions[:i+1], append([]int{symbolSize(version)}, dimensions[i+1:]...)...)
+					break
+				}
+			}
+
+			if text, err := decodeGrid(g); err == nil {
+				return text, true
+			}
+			// Mirrored symbols read correctly once transposed
+			if text, err := decodeGrid(g.transpose()); err == nil {
+				return text, true
+			}
+		}
+	}
+	return "", false
+}
+
+// sampleSymbol maps the finder patterns, and the alignment pattern when there is one,
+// onto module coordinates and samples the symbol.
+func sampleSymbol(b *bitmap, topLeft, topRight, bottomLeft finderPattern, module float64, size int, useAlignment bool) (grid, bool) {
+	s := float64(size)
+	bottomRightX := topRight.x + bottomLeft.x - topLeft.x
+	bottomRightY := topRight.y + bottomLeft.y - topLeft.y
+	src := [4][2]float64{{3.5, 3.5}, {s - 3.5, 3.5}, {s - 3.5, s - 3.5}, {3.5, s - 3.5}}
+	dst := [4][2]float64{
+		{topLeft.x, topLeft.y},
+		{topRight.x, topRight.y},
+		{bottomRightX, bottomRightY},
+		{bottomLeft.x, bottomLeft.y},
+	}
+
+	if useAlignment && size > symbolSize(1) {
+		// The bottom-right alignment pattern sits three modules in from the finder centers
+		correction := 1 - 3/(s-7)
+		estX := topLeft.x + correction*(bottomRightX-topLeft.x)
+		estY := topLeft.y + correction*(bottomRightY-topLeft.y)
+		if ax, ay, ok := findAlignment(b, estX, estY, module); ok {
+			src[2] = [2]float64{s - 6.5, s - 6.5}
+			dst[2] = [2]float64{ax, ay}
+		}
+	}
+
+	return sampleGrid(b, quadToQuad(src, dst), size)
+}
+
+// detect finds and decodes every symbol in a binarized image.
+func detect(b *bitmap) ([]string, bool) {
+	patterns := findFinderPatterns(b)
+	used := make([]bool, len(patterns))
+	attempted := false
+
+	var results []string
+	for _, t := range findTriples(patterns) {
+		if used[t.indices[0]] || used[t.indices[1]] || used[t.indices[2]] {
+			continue
+		}
+		attempted = true
+
+		text, ok := decodeTriple(b, patterns[t.indices[0]], patterns[t.indices[1]], patterns[t.indices[2]])
+		if !ok {
+			continue
+		}
+		for _, i := range t.indices {
+			used[i] = true
+		}
+		results = append(results, text)
+	}
+	return results, attempted
+}
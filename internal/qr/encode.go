@@ -0,0 +1,353 @@
+package qr
+
+import (
+	"errors"
+)
+
+// Code is an encoded QR symbol.
+type Code struct {
+	Size    int
+	modules grid
+}
+
+// Black reports whether the module at column x, row y is dark.
+// Coordinates outside the symbol are light.
+func (c *Code) Black(x, y int) bool {
+	return x >= 0 && y >= 0 && x < c.Size && y < c.Size && c.modules[y][x]
+}
+
+// ErrTooLong is returned when text does not fit in the largest symbol.
+var ErrTooLong = errors.New("text too long for a QR code")
+
+// Encode encodes text in byte mode into the smallest symbol with the given error correction level.
+func Encode(text string, level Level) (*Code, error) {
+	version := 0
+	for v := minVersion; v <= maxVersion; v++ {
+		if 4+charCountBits(modeByte, v)+8*len(text) <= dataCodewords(v, level)*8 {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrTooLong
+	}
+
+	data := encodeData(text, version, level)
+	codewords := addErrorCorrection(data, version, level)
+
+	c := &Code{Size: symbolSize(version)}
+	c.modules = make(grid, c.Size)
+	for i := range c.modules {
+		c.modules[i] = make([]bool, c.Size)
+	}
+	c.drawFunctionPatterns(version)
+	c.drawCodewords(codewords, version)
+
+	// Pick the mask with the lowest penalty
+	bestMask, bestPenalty := 0, -1
+	function := functionMask(version)
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask, function)
+		c.drawFormat(level, mask)
+		if penalty := c.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			bestMask, bestPenalty = mask, penalty
+		}
+		c.applyMask(mask, function)
+	}
+	c.applyMask(bestMask, function)
+	c.drawFormat(level, bestMask)
+
+	return c, nil
+}
+
+// bitWriter appends big-endian bit fields.
+type bitWriter struct {
+	bits []bool
+}
+
+func (w *bitWriter) write(value, n int) {
+	for i := n - 1; i >= 0; i-- {
+		w.bits = append(w.bits, value>>i&1 == 1)
+	}
+}
+
+// encodeData builds the padded data codewords for a byte mode segment.
+func encodeData(text string, version int, level Level) []byte {
+	capacity := dataCodewords(version, level) * 8
+
+	w := &bitWriter{}
+	w.write(modeByte, 4)
+	w.write(len(text), charCountBits(modeByte, version))
+	for i := 0; i < len(text); i++ {
+		w.write(int(text[i]), 8)
+	}
+
+	// Terminator, then pad to a byte boundary
+	w.write(0, min(4, capacity-len(w.bits)))
+	w.write(0, (8-len(w.bits)%8)%8)
+
+	data := make([]byte, len(w.bits)/8, capacity/8)
+	for i, bit := range w.bits {
+		if bit {
+			data[i>>3] |= 1 << (7 - i&7)
+		}
+	}
+	for pad := byte(0xec); len(data) < capacity/8; pad ^= 0xec ^ 0x11 {
+		data = append(data, pad)
+	}
+	return data
+}
+
+// rsGenerator returns the generator polynomial of the given degree, highest degree first
+// without the leading coefficient.
+func rsGenerator(degree int) []byte {
+	gen := make([]byte, degree)
+	gen[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		// Multiply by (x - alpha^i)
+		for j := 0; j < degree; j++ {
+			gen[j] = gfMul(gen[j], root)
+			if j+1 < degree {
+				gen[j] ^= gen[j+1]
+			}
+		}
+		root = gfMul(root, 2)
+	}
+	return gen
+}
+
+// rsRemainder returns the error correction codewords of a data block.
+func rsRemainder(data, gen []byte) []byte {
+	rem := make([]byte, len(gen))
+	for _, b := range data {
+		factor := b ^ rem[0]
+		copy(rem, rem[1:])
+		rem[len(rem)-1] = 0
+		for i, g := range gen {
+			rem[i] ^= gfMul(g, factor)
+		}
+	}
+	return rem
+}
+
+// addErrorCorrection splits the data into blocks, appends their error
+// correction codewords and interleaves the result.
+func addErrorCorrection(data []byte, version int, level Level) []byte {
+	layout := layoutFor(version, level)
+	gen := rsGenerator(layout.ecLen)
+
+	blocks := make([][]byte, layout.numBlocks)
+	offset := 0
+	for b := range blocks {
+		block := data[offset : offset+layout.dataLen(b)]
+		offset += len(block)
+		blocks[b] = append(append([]byte(nil), block...), rsRemainder(block, gen)...)
+	}
+
+	result := make([]byte, 0, rawCodewords(version))
+	for i := 0; i <= layout.shortDataLen; i++ {
+		for b := range blocks {
+			if i < layout.dataLen(b) {
+				result = append(result, blocks[b][i])
+			}
+		}
+	}
+	for i := 0; i < layout.ecLen; i++ {
+		for b := range blocks {
+			result = append(result, blocks[b][layout.dataLen(b)+i])
+		}
+	}
+	return result
+}
+
+func (c *Code) set(x, y int, dark bool) {
+	if x >= 0 && y >= 0 && x < c.Size && y < c.Size {
+		c.modules[y][x] = dark
+	}
+}
+
+func (c *Code) drawFinder(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			d := max(abs(dx), abs(dy))
+			c.set(cx+dx, cy+dy, d != 2 && d != 4)
+		}
+	}
+}
+
+// drawFunctionPatterns draws everything except the data and format information.
+func (c *Code) drawFunctionPatterns(version int) {
+	for i := 0; i < c.Size; i++ {
+		c.set(6, i, i%2 == 0)
+		c.set(i, 6, i%2 == 0)
+	}
+
+	c.drawFinder(3, 3)
+	c.drawFinder(c.Size-4, 3)
+	c.drawFinder(3, c.Size-4)
+
+	positions := alignmentPositions(version)
+	last := len(positions) - 1
+	for i, y := range positions {
+		for j, x := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					c.set(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	if version >= 7 {
+		info := versionInfo(version)
+		for i := 0; i < 18; i++ {
+			dark := info>>i&1 == 1
+			a, b := c.Size-11+i%3, i/3
+			c.set(a, b, dark)
+			c.set(b, a, dark)
+		}
+	}
+}
+
+// drawFormat draws both copies of the format information and the dark module.
+func (c *Code) drawFormat(level Level, mask int) {
+	info := formatInfo(level, mask)
+	bit := func(i int) bool { return info>>i&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		c.set(8, i, bit(i))
+	}
+	c.set(8, 7, bit(6))
+	c.set(8, 8, bit(7))
+	c.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.set(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		c.set(c.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.set(8, c.Size-15+i, bit(i))
+	}
+	c.set(8, c.Size-8, true)
+}
+
+// drawCodewords places the codewords in the zigzag order used by readCodewords.
+func (c *Code) drawCodewords(codewords []byte, version int) {
+	function := functionMask(version)
+	bitIndex := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				upward := (right+1)&2 == 0
+				y := vert
+				if upward {
+					y = c.Size - 1 - vert
+				}
+				if function[y][x] {
+					continue
+				}
+				// Remainder bits after the last codeword stay light
+				if bitIndex < len(codewords)*8 {
+					c.modules[y][x] = codewords[bitIndex>>3]>>(7-bitIndex&7)&1 == 1
+				}
+				bitIndex++
+			}
+		}
+	}
+}
+
+// applyMask inverts the data modules selected by the mask; applying it twice undoes it.
+func (c *Code) applyMask(mask int, function [][]bool) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !function[y][x] && maskBit(mask, x, y) {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores the symbol with the four mask evaluation rules of ISO/IEC 18004.
+func (c *Code) penalty() int {
+	score := 0
+	size := c.Size
+	lines := make([][]bool, 0, 2*size)
+	for y := 0; y < size; y++ {
+		lines = append(lines, c.modules[y])
+	}
+	for x := 0; x < size; x++ {
+		column := make([]bool, size)
+		for y := 0; y < size; y++ {
+			column[y] = c.modules[y][x]
+		}
+		lines = append(lines, column)
+	}
+
+	finderLike := [][]bool{
+		{true, false, true, true, true, false, true, false, false, false, false},
+		{false, false, false, false, true, false, true, true, true, false, true},
+	}
+	for _, line := range lines {
+		// Runs of five or more modules of the same color
+		run := 1
+		for i := 1; i <= len(line); i++ {
+			if i < len(line) && line[i] == line[i-1] {
+				run++
+				continue
+			}
+			if run >= 5 {
+				score += 3 + run - 5
+			}
+			run = 1
+		}
+
+		// Patterns that look like finders
+		for i := 0; i+11 <= len(line); i++ {
+			for _, pattern := range finderLike {
+				match := true
+				for j, dark := range pattern {
+					if line[i+j] != dark {
+						match = false
+						break
+					}
+				}
+				if match {
+					score += 40
+				}
+			}
+		}
+	}
+
+	// 2x2 blocks of the same color
+	dark := 0
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if c.modules[y][x] {
+				dark++
+			}
+			if x+1 < size && y+1 < size {
+				v := c.modules[y][x]
+				if c.modules[y][x+1] == v && c.modules[y+1][x] == v && c.modules[y+1][x+1] == v {
+					score += 3
+				}
+			}
+		}
+	}
+
+	// Balance of dark and light modules
+	total := size * size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	score += max(k, 0) * 10
+
+	return score
+}
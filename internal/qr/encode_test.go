@@ -0,0 +1,84 @@
+package qr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	tests := []struct {
+		name        string
+		text        string
+		level       Level
+		wantVersion int
+	}{
+		{"Version 1 at capacity", strings.Repeat("a", 17), L, 1},
+		{"Version 2 past version 1 capacity", strings.Repeat("a", 18), L, 2},
+		{"Short URI", "otpauth://totp/Example:alice@google.com?secret=JBSWY3DPEHPK3PXP&issuer=Example", M, 5},
+		{"Version 7 with version information", strings.Repeat("x", 120), M, 7},
+		{"Multiple block sizes", strings.Repeat("y", 300), Q, 16},
+		{"Large symbol", strings.Repeat("z", 1200), H, 39},
+		{"Largest symbol", strings.Repeat("0", 2953), L, 40},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := Encode(tt.text, tt.level)
+			if err != nil {
+				t.Fatalf("Encode() unexpected error = %v", err)
+			}
+			if code.Size != symbolSize(tt.wantVersion) {
+				t.Errorf("Encode() size = %d, want version %d", code.Size, tt.wantVersion)
+			}
+
+			got, err := Decode(code.Image(2, QuietZone))
+			if err != nil {
+				t.Fatalf("Decode() unexpected error = %v", err)
+			}
+			if len(got) != 1 || got[0] != tt.text {
+				t.Errorf("Decode() = %q, want %q", got, tt.text)
+			}
+		})
+	}
+}
+
+func TestEncodeTooLong(t *testing.T) {
+	if _, err := Encode(strings.Repeat("0", 2954), L); err != ErrTooLong {
+		t.Errorf("Encode() error = %v, want %v", err, ErrTooLong)
+	}
+}
+
+func TestSVG(t *testing.T) {
+	code, err := Encode("HELLO", M)
+	if err != nil {
+		t.Fatalf("Encode() unexpected error = %v", err)
+	}
+
+	svg := code.SVG(QuietZone)
+	if !strings.Contains(svg, `viewBox="0 0 29 29"`) {
+		t.Errorf("SVG() missing viewBox for a 21 module symbol with border: %s", svg)
+	}
+	// The top-left finder corner is the first dark module
+	if !strings.Contains(svg, `d="M4,4h1v1h-1z`) {
+		t.Errorf("SVG() first module not at the quiet zone offset")
+	}
+}
+
+func TestTerminal(t *testing.T) {
+	code, err := Encode("HELLO", M)
+	if err != nil {
+		t.Fatalf("Encode() unexpected error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(code.Terminal(1), "\n"), "\n")
+	// 21 modules plus a border of one on each side, two rows per line
+	if len(lines) != 12 {
+		t.Fatalf("Terminal() lines = %d, want 12", len(lines))
+	}
+	// The second line holds module rows 1 and 2: border, the finder's dark edge,
+	// its light ring, then the top of its dark center
+	row := strings.TrimSuffix(strings.TrimPrefix(lines[1], terminalColors), terminalReset)
+	if !strings.HasPrefix(row, " █ ▄▄▄ █") {
+		t.Errorf("Terminal() second line = %q", row)
+	}
+}
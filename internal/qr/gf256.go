@@ -0,0 +1,158 @@
+package qr
+
+import "errors"
+
+// GF(2^8) arithmetic with the QR code primitive polynomial x^8 + x^4 + x^3 + x^2 + 1.
+var (
+	gfExp [512]byte
+	gfLog [256]int
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfLog[x] = i
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+	// Duplicate the table so products of logs never need a modulo
+	for i := 255; i < len(gfExp); i++ {
+		gfExp[i] = gfExp[i-255]
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[gfLog[a]+gfLog[b]]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[gfLog[a]+255-gfLog[b]]
+}
+
+// gfPow returns alpha^n.
+func gfPow(n int) byte {
+	n %= 255
+	if n < 0 {
+		n += 255
+	}
+	return gfExp[n]
+}
+
+// polyEval evaluates a polynomial stored lowest degree first at x.
+func polyEval(p []byte, x byte) byte {
+	var y byte
+	for i := len(p) - 1; i >= 0; i-- {
+		y = gfMul(y, x) ^ p[i]
+	}
+	return y
+}
+
+var errTooManyErrors = errors.New("too many errors to correct")
+
+// rsCorrect corrects a Reed-Solomon block in place. The block holds the
+// data codewords followed by numEC error correction codewords, highest degree first.
+func rsCorrect(block []byte, numEC int) error {
+	n := len(block)
+
+	// Syndromes S_j = r(alpha^j)
+	syndromes := make([]byte, numEC)
+	clean := true
+	for j := 0; j < numEC; j++ {
+		var s byte
+		x := gfPow(j)
+		for _, c := range block {
+			s = gfMul(s, x) ^ c
+		}
+		syndromes[j] = s
+		if s != 0 {
+			clean = false
+		}
+	}
+	if clean {
+		return nil
+	}
+
+	// Berlekamp-Massey for the error locator polynomial, lowest degree first
+	locator := []byte{1}
+	prev := []byte{1}
+	numErrors, shift := 0, 1
+	prevDiscrepancy := byte(1)
+	for i := 0; i < numEC; i++ {
+		d := syndromes[i]
+		for j := 1; j <= numErrors && j < len(locator); j++ {
+			d ^= gfMul(locator[j], syndromes[i-j])
+		}
+		if d == 0 {
+			shift++
+			continue
+		}
+
+		scale := gfDiv(d, prevDiscrepancy)
+		next := make([]byte, max(len(locator), len(prev)+shift))
+		copy(next, locator)
+		for j, c := range prev {
+			next[j+shift] ^= gfMul(scale, c)
+		}
+
+		if 2*numErrors <= i {
+			prev = locator
+			numErrors = i + 1 - numErrors
+			prevDiscrepancy = d
+			shift = 1
+		} else {
+			shift++
+		}
+		locator = next
+	}
+	if 2*numErrors > numEC {
+		return errTooManyErrors
+	}
+
+	// Error evaluator Omega(x) = S(x) * Lambda(x) mod x^numEC
+	evaluator := make([]byte, numEC)
+	for i, s := range syndromes {
+		for j, l := range locator {
+			if i+j < numEC {
+				evaluator[i+j] ^= gfMul(s, l)
+			}
+		}
+	}
+
+	// Formal derivative of the locator keeps the odd powers
+	derivative := make([]byte, len(locator))
+	for i := 1; i < len(locator); i += 2 {
+		derivative[i-1] = locator[i]
+	}
+
+	// Chien search and Forney's algorithm
+	found := 0
+	for i := 0; i < n; i++ {
+		degree := n - 1 - i
+		xInv := gfPow(-degree)
+		if polyEval(locator, xInv) != 0 {
+			continue
+		}
+
+		denominator := polyEval(derivative, xInv)
+		if denominator == 0 {
+			return errTooManyErrors
+		}
+		magnitude := gfMul(gfPow(degree), gfDiv(polyEval(evaluator, xInv), denominator))
+		block[i] ^= magnitude
+		found++
+	}
+	if found != numErrors {
+		return errTooManyErrors
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+// Package qr encodes QR codes and decodes them from images.
+package qr
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"os"
+
+	// Register the image formats accepted by DecodeFile
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+var (
+	// ErrNotFound is returned when an image contains no QR code.
+	ErrNotFound = errors.New("no QR code found in image")
+
+	// ErrUnreadable is returned when an image contains a QR code that cannot be decoded.
+	ErrUnreadable = errors.New("found a QR code but could not read it")
+)
+
+// Decode finds every QR code in the image and returns their contents.
+func Decode(img image.Image) ([]string, error) {
+	lum, w, h := luminance(img)
+	if w == 0 || h == 0 {
+		return nil, ErrNotFound
+	}
+
+	// Screenshots binarize cleanly with one threshold; photos with uneven
+	// lighting need a local one, which may also find codes the first pass missed.
+	var results []string
+	seen := map[string]bool{}
+	attempted := false
+	for _, b := range []func([]uint8, int, int) *bitmap{globalBitmap, adaptiveBitmap} {
+		texts, tried := detect(b(lum, w, h))
+		attempted = attempted || tried
+		for _, text := range texts {
+			if !seen[text] {
+				seen[text] = true
+				results = append(results, text)
+			}
+		}
+	}
+
+	if len(results) == 0 {
+		if attempted {
+			return nil, ErrUnreadable
+		}
+		return nil, ErrNotFound
+	}
+	return results, nil
+}
+
+// DecodeFile decodes every QR code in a PNG, JPEG or GIF file.
+func DecodeFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image %s: %v", path, err)
+	}
+
+	return Decode(img)
+}
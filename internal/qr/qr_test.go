@@ -0,0 +1,136 @@
+package qr
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestDecodeFile(t *testing.T) {
+	tests := []struct {
+		file string
+		want []string
+	}{
+		{
+			file: "simple.png",
+			want: []string{"otpauth://totp/Example:alice@google.com?secret=JBSWY3DPEHPK3PXP&issuer=Example"},
+		},
+		{
+			file: "transparent.png",
+			want: []string{"otpauth://totp/ACME%20Co:john.doe@email.com?secret=HXDMVJECJJWSRB3HWIZR4IFUGFTMXBOZ&issuer=ACME%20Co&algorithm=SHA1&digits=6&period=30"},
+		},
+		{
+			file: "large.png",
+			want: []string{"otpauth://totp/Very%20Long%20Issuer%20Name%20Incorporated:someone.with.a.long.address@subdomain.example.com?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&issuer=Very%20Long%20Issuer%20Name%20Incorporated&algorithm=SHA512&digits=8&period=60"},
+		},
+		{
+			file: "photo.jpg",
+			want: []string{"otpauth://totp/Photo:bob?secret=KRUGKIDROVUWG2ZAMJZG653OEBTG66BANJ2W24DTEBXXMZLSEB2GQZJANRQXU6JAMRXWO&issuer=Photo"},
+		},
+		{
+			file: "small.gif",
+			want: []string{"otpauth://totp/Gif:dave?secret=JBSWY3DPEHPK3PXP"},
+		},
+		{
+			file: "damaged.png",
+			want: []string{"otpauth://totp/Example:alice@google.com?secret=JBSWY3DPEHPK3PXP&issuer=Example"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			got, err := DecodeFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatalf("DecodeFile() unexpected error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("DecodeFile() = %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("DecodeFile()[%d] = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeFileMultiple(t *testing.T) {
+	got, err := DecodeFile(filepath.Join("testdata", "multiple.png"))
+	if err != nil {
+		t.Fatalf("DecodeFile() unexpected error = %v", err)
+	}
+
+	want := map[string]bool{
+		"otpauth://totp/GitHub:octocat?secret=JBSWY3DPEHPK3PXP&issuer=GitHub":         true,
+		"otpauth://totp/GitLab:tanuki?secret=GEZDGNBVGY3TQOJQ&issuer=GitLab&digits=8": true,
+		"otpauth://hotp/VPN:carol?secret=MFRGGZDFMZTWQ2LK&issuer=VPN&counter=5":       true,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("DecodeFile() = %q, want %d codes", got, len(want))
+	}
+	for _, text := range got {
+		if !want[text] {
+			t.Errorf("DecodeFile() unexpected code %q", text)
+		}
+	}
+}
+
+func TestDecodeFileErrors(t *testing.T) {
+	tests := []struct {
+		file    string
+		wantErr error
+	}{
+		{"noqr.png", ErrNotFound},
+		{"unreadable.png", ErrUnreadable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			_, err := DecodeFile(filepath.Join("testdata", tt.file))
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("DecodeFile() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := DecodeFile(filepath.Join("testdata", "missing.png")); err == nil {
+		t.Errorf("DecodeFile() expected error for missing file")
+	}
+	if _, err := DecodeFile("qr_test.go"); err == nil {
+		t.Errorf("DecodeFile() expected error for a file that is not an image")
+	}
+}
+
+func TestRSCorrect(t *testing.T) {
+	// Version 1-M block for "01234567" from ISO/IEC 18004 Annex I
+	block := []byte{
+		0x10, 0x20, 0x0c, 0x56, 0x61, 0x80, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11,
+		0xa5, 0x24, 0xd4, 0xc1, 0xed, 0x36, 0xc7, 0x87, 0x2c, 0x55,
+	}
+	want := append([]byte(nil), block...)
+
+	if err := rsCorrect(block, 10); err != nil {
+		t.Fatalf("rsCorrect() unexpected error on a clean block = %v", err)
+	}
+
+	// Up to five errors are correctable with ten error correction codewords
+	for _, i := range []int{0, 3, 9, 17, 25} {
+		block[i] ^= 0x5a
+	}
+	if err := rsCorrect(block, 10); err != nil {
+		t.Fatalf("rsCorrect() unexpected error = %v", err)
+	}
+	for i := range block {
+		if block[i] != want[i] {
+			t.Fatalf("rsCorrect() codeword %d = %#x, want %#x", i, block[i], want[i])
+		}
+	}
+
+	for _, i := range []int{0, 1, 2, 3, 4, 5} {
+		block[i] ^= 0xff
+	}
+	if err := rsCorrect(block, 10); err == nil {
+		t.Errorf("rsCorrect() expected error with six errors")
+	}
+}
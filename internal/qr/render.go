@@ -0,0 +1,79 @@
+package qr
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+)
+
+// QuietZone is the recommended width of the light border around a symbol, in modules.
+const QuietZone = 4
+
+// Image renders the symbol with each module drawn as scale by scale pixels,
+// surrounded by a border of the given number of modules.
+func (c *Code) Image(scale, border int) image.Image {
+	side := (c.Size + 2*border) * scale
+	img := image.NewPaletted(image.Rect(0, 0, side, side), color.Palette{color.White, color.Black})
+	for y := 0; y < side; y++ {
+		for x := 0; x < side; x++ {
+			if c.Black(x/scale-border, y/scale-border) {
+				img.SetColorIndex(x, y, 1)
+			}
+		}
+	}
+	return img
+}
+
+// SVG renders the symbol as an SVG document with one unit per module.
+func (c *Code) SVG(border int) string {
+	side := c.Size + 2*border
+
+	var path strings.Builder
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.Black(x, y) {
+				fmt.Fprintf(&path, "M%d,%dh1v1h-1z", x+border, y+border)
+			}
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" version="1.1" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+"\n", side, side)
+	b.WriteString(`<rect width="100%" height="100%" fill="#ffffff"/>` + "\n")
+	fmt.Fprintf(&b, `<path d="%s" fill="#000000"/>`+"\n", path.String())
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+// ANSI colors used by Terminal: black foreground on a bright white background,
+// so the symbol scans regardless of the terminal's color scheme.
+const (
+	terminalColors = "\033[30;107m"
+	terminalReset  = "\033[0m"
+)
+
+// Terminal renders the symbol with Unicode half-block characters, two module rows per line.
+func (c *Code) Terminal(border int) string {
+	var b strings.Builder
+	for y := -border; y < c.Size+border; y += 2 {
+		b.WriteString(terminalColors)
+		for x := -border; x < c.Size+border; x++ {
+			top, bottom := c.Black(x, y), c.Black(x, y+1)
+			switch {
+			case top && bottom:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString(terminalReset)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
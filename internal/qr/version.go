@@ -0,0 +1,211 @@
+package qr
+
+// Level is an error correction level.
+type Level int
+
+// Error correction levels, ordered by increasing redundancy.
+const (
+	L Level = iota // recovers about 7% of codewords
+	M              // recovers about 15% of codewords
+	Q              // recovers about 25% of codewords
+	H              // recovers about 30% of codewords
+)
+
+// formatBits returns the two bits that encode the level in the format information.
+func (l Level) formatBits() int {
+	return [...]int{1, 0, 3, 2}[l]
+}
+
+func levelFromFormatBits(bits int) Level {
+	return [...]Level{M, L, H, Q}[bits&3]
+}
+
+const (
+	minVersion = 1
+	maxVersion = 40
+)
+
+// Error correction codewords per block and number of blocks, indexed by level and version.
+var (
+	ecCodewordsPerBlock = [4][41]int{
+		L: {-1, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+		M: {-1, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+		Q: {-1, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+		H: {-1, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	}
+	numBlocks = [4][41]int{
+		L: {-1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+		M: {-1, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+		Q: {-1, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+		H: {-1, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+	}
+)
+
+// symbolSize returns the number of modules per side of a version.
+func symbolSize(version int) int {
+	return 17 + 4*version
+}
+
+// rawCodewords returns the number of 8-bit codewords that fit in a version
+// once all function patterns are excluded.
+func rawCodewords(version int) int {
+	modules := (16*version+128)*version + 64
+	if version >= 2 {
+		numAlign := version/7 + 2
+		modules -= (25*numAlign-10)*numAlign - 55
+		if version >= 7 {
+			modules -= 36
+		}
+	}
+	return modules / 8
+}
+
+// dataCodewords returns the number of data codewords of a version and level.
+func dataCodewords(version int, level Level) int {
+	return rawCodewords(version) - ecCodewordsPerBlock[level][version]*numBlocks[level][version]
+}
+
+// blockLayout describes how the codewords of a version and level are split into blocks.
+type blockLayout struct {
+	numBlocks      int
+	numShortBlocks int
+	shortDataLen   int // data codewords in a short block; long blocks have one more
+	ecLen          int
+}
+
+func layoutFor(version int, level Level) blockLayout {
+	blocks := numBlocks[level][version]
+	ecLen := ecCodewordsPerBlock[level][version]
+	raw := rawCodewords(version)
+	return blockLayout{
+		numBlocks:      blocks,
+		numShortBlocks: blocks - raw%blocks,
+		shortDataLen:   raw/blocks - ecLen,
+		ecLen:          ecLen,
+	}
+}
+
+func (b blockLayout) dataLen(block int) int {
+	if block < b.numShortBlocks {
+		return b.shortDataLen
+	}
+	return b.shortDataLen + 1
+}
+
+// alignmentPositions returns the centers of the alignment patterns along each axis.
+func alignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+
+	numAlign := version/7 + 2
+	step := 26
+	if version != 32 {
+		step = (version*4 + numAlign*2 + 1) / (numAlign*2 - 2) * 2
+	}
+
+	positions := make([]int, numAlign)
+	positions[0] = 6
+	for i, pos := numAlign-1, symbolSize(version)-7; i >= 1; i, pos = i-1, pos-step {
+		positions[i] = pos
+	}
+	return positions
+}
+
+// formatInfo returns the 15-bit masked format information for a level and mask.
+func formatInfo(level Level, mask int) int {
+	data := level.formatBits()<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// versionInfo returns the 18-bit version information for versions 7 and up.
+func versionInfo(version int) int {
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1f25)
+	}
+	return version<<12 | rem
+}
+
+func bitCount(x int) int {
+	n := 0
+	for ; x != 0; x &= x - 1 {
+		n++
+	}
+	return n
+}
+
+// functionMask marks the modules of a version that hold function patterns
+// rather than data: finders, separators, timing, alignment, format and version information.
+func functionMask(version int) [][]bool {
+	size := symbolSize(version)
+	mask := make([][]bool, size)
+	for i := range mask {
+		mask[i] = make([]bool, size)
+	}
+
+	fill := func(x0, y0, w, h int) {
+		for y := y0; y < y0+h; y++ {
+			for x := x0; x < x0+w; x++ {
+				if x >= 0 && x < size && y >= 0 && y < size {
+					mask[y][x] = true
+				}
+			}
+		}
+	}
+
+	// Finder patterns with separators and format information
+	fill(0, 0, 9, 9)
+	fill(size-8, 0, 8, 9)
+	fill(0, size-8, 9, 8)
+
+	// Timing patterns
+	fill(6, 0, 1, size)
+	fill(0, 6, size, 1)
+
+	// Alignment patterns, except where they would overlap the finders
+	positions := alignmentPositions(version)
+	last := len(positions) - 1
+	for i, y := range positions {
+		for j, x := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			fill(x-2, y-2, 5, 5)
+		}
+	}
+
+	// Version information
+	if version >= 7 {
+		fill(size-11, 0, 3, 6)
+		fill(0, size-11, 6, 3)
+	}
+
+	return mask
+}
+
+// maskBit reports whether the data mask inverts the module at column x, row y.
+func maskBit(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
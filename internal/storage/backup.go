@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// MaxBackups is the number of previous generations of the vault that are
+// kept next to it, as vault.1 (the most recent) to vault.5.
+const MaxBackups = 5
+
+// ErrBackupNotFound is returned when restoring a backup that does not exist.
+var ErrBackupNotFound = errors.New("backup not found")
+
+// Backup is a previous generation of the vault.
+type Backup struct {
+	Generation int
+	Path       string
+	ModTime    time.Time
+}
+
+// backupPath returns the path of a backup generation of the vault at path.
+func backupPath(path string, generation int) string {
+	return path + "." + strconv.Itoa(generation)
+}
+
+// Backups returns the backups of the vault, most recent first.
+func (s *FileStore) Backups() ([]Backup, error) {
+	var backups []Backup
+	for generation := 1; generation <= MaxBackups; generation++ {
+		path := backupPath(s.path, generation)
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, Backup{Generation: generation, Path: path, ModTime: info.ModTime()})
+	}
+	return backups, nil
+}
+
+// RestoreBackup replaces the vault with a backup generation. The vault being
+// replaced becomes the most recent backup, so a restore can itself be undone.
+// The store must not be open.
+func (s *FileStore) RestoreBackup(generation int) error {
+	if generation < 1 || generation > MaxBackups {
+		return fmt.Errorf("backup generation must be between 1 and %d", MaxBackups)
+	}
+	if s.unlock != nil {
+		return errAlreadyOpen
+	}
+
+	lock, err := lockFile(s.path)
+	if err != nil {
+		return err
+	}
+	defer lock.unlock()
+
+	data, err := os.ReadFile(backupPath(s.path, generation))
+	if os.IsNotExist(err) {
+		return ErrBackupNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	return writeVault(s.path, data, true)
+}
+
+// writeVault atomically replaces the vault at path with data, after keeping
+// the current vault as the most recent backup if backup is set.
+func writeVault(path string, data []byte, backup bool) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	if backup {
+		if err := rotateBackups(path); err != nil {
+			return fmt.Errorf("failed to back up the vault: %v", err)
+		}
+	}
+
+	return writeFileAtomic(path, data, 0600)
+}
+
+// removeBackups deletes every backup generation of the vault at path.
+func removeBackups(path string) error {
+	for generation := 1; generation <= MaxBackups; generation++ {
+		if err := os.Remove(backupPath(path, generation)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// rotateBackups shifts each backup one generation back, dropping the oldest,
+// and copies the current vault to the first generation. The vault itself is
+// left untouched, so a crash during rotation can lose a backup but never the vault.
+func rotateBackups(path string) error {
+	current, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for generation := MaxBackups - 1; generation >= 1; generation-- {
+		err = os.Rename(backupPath(path, generation), backupPath(path, generation+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return writeFileAtomic(backupPath(path, 1), current, 0600)
+}
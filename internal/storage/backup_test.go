@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupRotation(t *testing.T) {
+	s := NewFileStore(filepath.Join(t.TempDir(), "vault"))
+
+	for i := 0; i < MaxBackups+2; i++ {
+		if err := writeVault(s.Path(), []byte(fmt.Sprintf("generation %d", i)), true); err != nil {
+			t.Fatalf("writeVault() unexpected error = %v", err)
+		}
+	}
+
+	backups, err := s.Backups()
+	if err != nil {
+		t.Fatalf("Backups() unexpected error = %v", err)
+	}
+	if len(backups) != MaxBackups {
+		t.Fatalf("Backups() returned %d backups, want %d", len(backups), MaxBackups)
+	}
+
+	// The most recent backup is the vault before the last write
+	for _, backup := range backups {
+		data, err := os.ReadFile(backup.Path)
+		if err != nil {
+			t.Fatalf("Failed to read backup: %v", err)
+		}
+		want := fmt.Sprintf("generation %d", MaxBackups+1-backup.Generation)
+		if string(data) != want {
+			t.Errorf("Backup %d contains %q, want %q", backup.Generation, data, want)
+		}
+	}
+}
+
+func TestRestoreBackup(t *testing.T) {
+	masterPassword := "testpassword"
+	s := newTestFileStore(t, masterPassword)
+	if err := s.Put(Account{Name: "TestAccount", Secret: "JBSWY3DPEHPK3PXP"}); err != nil {
+		t.Fatalf("Put() unexpected error = %v", err)
+	}
+	if err := s.Delete("TestAccount"); err != nil {
+		t.Fatalf("Delete() unexpected error = %v", err)
+	}
+
+	if err := s.RestoreBackup(1); err == nil {
+		t.Fatalf("RestoreBackup() should fail while the store is open")
+	}
+	s.Close()
+
+	if err := s.RestoreBackup(1); err != nil {
+		t.Fatalf("RestoreBackup() unexpected error = %v", err)
+	}
+
+	if err := s.Open(masterPassword); err != nil {
+		t.Fatalf("Open() unexpected error = %v", err)
+	}
+	if _, err := s.Get("TestAccount"); err != nil {
+		t.Fatalf("Restored vault should contain the deleted account: %v", err)
+	}
+	s.Close()
+
+	// The replaced vault is kept, so the restore can be undone
+	if err := s.RestoreBackup(1); err != nil {
+		t.Fatalf("RestoreBackup() unexpected error = %v", err)
+	}
+	if err := s.Open(masterPassword); err != nil {
+		t.Fatalf("Open() unexpected error = %v", err)
+	}
+	defer s.Close()
+	if accounts, _ := s.List(); len(accounts) != 0 {
+		t.Fatalf("Vault after undoing the restore has %d accounts, want 0", len(accounts))
+	}
+}
+
+func TestRestoreBackupErrors(t *testing.T) {
+	s := NewFileStore(filepath.Join(t.TempDir(), "vault"))
+
+	if err := s.RestoreBackup(1); !errors.Is(err, ErrBackupNotFound) {
+		t.Errorf("RestoreBackup() error = %v, want ErrBackupNotFound", err)
+	}
+	for _, generation := range []int{0, MaxBackups + 1} {
+		if err := s.RestoreBackup(generation); err == nil {
+			t.Errorf("RestoreBackup(%d) expected error", generation)
+		}
+	}
+}
+
+func TestUsageSkipsBackup(t *testing.T) {
+	s := newTestFileStore(t, "testpassword")
+	defer s.Close()
+
+	if err := s.Put(Account{Name: "GitHub", Secret: "JBSWY3DPEHPK3PXP"}); err != nil {
+		t.Fatalf("Failed to add account: %v", err)
+	}
+	before, err := s.Backups()
+	if err != nil {
+		t.Fatalf("Backups() unexpected error = %v", err)
+	}
+
+	// Recording usage saves the vault without keeping the previous one
+	if err = MarkUsed(s, "GitHub"); err != nil {
+		t.Fatalf("Failed to mark account used: %v", err)
+	}
+	after, err := s.Backups()
+	if err != nil {
+		t.Fatalf("Backups() unexpected error = %v", err)
+	}
+	if len(after) != len(before) {
+		t.Fatalf("Expected %d backups after recording usage, got %d", len(before), len(after))
+	}
+
+	s.Close()
+	if err = s.Open("testpassword"); err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	if acc, err := s.Get("GitHub"); err != nil || acc.LastUsedAt.IsZero() {
+		t.Fatalf("Expected last use to be saved, got %v (%v)", acc.LastUsedAt, err)
+	}
+}
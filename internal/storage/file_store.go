@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"os"
+)
+
+// FileStore is a Store kept in an encrypted file. Each save replaces the file
+// atomically and keeps the previous versions as backups. An open FileStore
+// holds a lock that keeps other processes from opening the file.
+type FileStore struct {
+	vault
+	path string
+}
+
+// NewFileStore returns a store for the vault file at path. The file is only
+// read when the store is opened.
+func NewFileStore(path string) *FileStore {
+	s := &FileStore{path: path}
+	s.backend = fileBackend{path: path}
+	return s
+}
+
+// Path returns the location of the vault file.
+func (s *FileStore) Path() string {
+	return s.path
+}
+
+// Remove deletes the vault file, its backups and its lock file. The store
+// must not be open, and is not removed while another process has it open.
+func (s *FileStore) Remove() error {
+	if s.unlock != nil {
+		return errAlreadyOpen
+	}
+
+	lock, err := lockFile(s.path)
+	if err != nil {
+		return err
+	}
+
+	if err = removeBackups(s.path); err != nil {
+		lock.unlock()
+		return err
+	}
+	if err = os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		lock.unlock()
+		return err
+	}
+
+	// Open files cannot be removed on Windows, so the lock is released first.
+	// A process that takes it in between finds no vault to open.
+	if err = lock.unlock(); err != nil {
+		return err
+	}
+	if err = os.Remove(s.path + ".lock"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// fileBackend keeps the vault in a file, with its backups and lock file next to it.
+type fileBackend struct {
+	path string
+}
+
+func (b fileBackend) exists() (bool, error) {
+	_, err := os.Stat(b.path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (b fileBackend) read() ([]byte, error) {
+	data, err := os.ReadFile(b.path)
+	if os.IsNotExist(err) {
+		return nil, ErrVaultNotFound
+	}
+	return data, err
+}
+
+func (b fileBackend) write(data []byte, backup bool) error {
+	return writeVault(b.path, data, backup)
+}
+
+func (b fileBackend) removeBackups() error {
+	return removeBackups(b.path)
+}
+
+func (b fileBackend) lock() (func() error, error) {
+	lock, err := lockFile(b.path)
+	if err != nil {
+		return nil, err
+	}
+	return lock.unlock, nil
+}
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "accounts.db")
+
+	for _, data := range []string{"first", "second"} {
+		if err := writeFileAtomic(path, []byte(data), 0600); err != nil {
+			t.Fatalf("writeFileAtomic() unexpected error = %v", err)
+		}
+
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read file: %v", err)
+		}
+		if string(got) != data {
+			t.Fatalf("File contains %q, want %q", got, data)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Fatalf("File permissions = %o, want 600", perm)
+	}
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read directory: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Directory contains %d entries, want 1", len(entries))
+	}
+}
+
+func TestWriteFileAtomicMissingDirectory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "accounts.db")
+	if err := writeFileAtomic(path, []byte("data"), 0600); err == nil {
+		t.Fatalf("writeFileAtomic() expected error for a missing directory")
+	}
+}
@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bykclk/twocli/internal/totp"
+)
+
+// Problem is an issue with an account found by Check.
+type Problem struct {
+	// Account is the name of the account, which is in the trash if Trashed is set.
+	Account string
+	Trashed bool
+
+	Message string
+
+	// Repair describes how Check repairs the problem when asked to, or is
+	// empty if the problem is only reported. Repaired is set once it has been.
+	Repair   string
+	Repaired bool
+}
+
+// Report is the result of Check.
+type Report struct {
+	Accounts    int
+	Trashed     int
+	Quarantined int
+	Problems    []Problem
+}
+
+// quarantineRecord is a record that could not be used, kept aside by Check
+// so that the rest of the vault stays usable. Its secret and notes may not
+// decrypt.
+type quarantineRecord struct {
+	record
+	Reason        string    `json:"reason"`
+	QuarantinedAt time.Time `json:"quarantined_at"`
+}
+
+// repairPlan holds the changes that repair the problems found by check.
+type repairPlan struct {
+	problems   []Problem
+	quarantine map[int]string // account index to reason
+	trash      map[int]string // trash index to reason
+	renames    map[int]string // account index to new name
+}
+
+// Check decrypts and validates every account and trashed account in the
+// store, and looks for duplicate names and secrets. With repair set, accounts
+// that cannot be used are moved to the quarantine, accounts hidden by an
+// earlier account with the same name are renamed, and the store is saved.
+func Check(s Store, repair bool) (Report, error) {
+	var report Report
+	err := s.Update(func(tx *Tx) error {
+		plan := tx.check()
+		if repair {
+			tx.repair(plan)
+		}
+
+		report = Report{
+			Accounts:    len(tx.records),
+			Trashed:     len(tx.trash),
+			Quarantined: len(tx.quarantine),
+			Problems:    plan.problems,
+		}
+		return nil
+	})
+	if err != nil {
+		return Report{}, err
+	}
+	return report, nil
+}
+
+// checkRecord decrypts and validates a record, and returns its account, or
+// a description of why it cannot be used.
+func (tx *Tx) checkRecord(r record) (Account, string) {
+	if r.Name == "" {
+		return Account{}, "account has no name"
+	}
+
+	secret, err := tx.key.Decrypt(r.EncryptedSecret)
+	if err != nil {
+		return Account{}, "secret cannot be decrypted"
+	}
+	if err = totp.ValidateSecret(string(secret)); err != nil {
+		return Account{}, fmt.Sprintf("invalid secret: %v", err)
+	}
+	if r.EncryptedNotes != nil {
+		if _, err = tx.key.Decrypt(r.EncryptedNotes); err != nil {
+			return Account{}, "notes cannot be decrypted"
+		}
+	}
+
+	if r.Type != "" && r.Type != totp.TypeTOTP && r.Type != totp.TypeHOTP {
+		return Account{}, fmt.Sprintf("unknown account type: %s", r.Type)
+	}
+	if err = r.Params().Validate(); err != nil {
+		return Account{}, fmt.Sprintf("invalid code parameters: %v", err)
+	}
+
+	acc := r.Account
+	acc.Secret = string(secret)
+	return acc, ""
+}
+
+// check looks for problems with the accounts and the trash, and plans their repair.
+func (tx *Tx) check() repairPlan {
+	plan := repairPlan{quarantine: map[int]string{}, trash: map[int]string{}, renames: map[int]string{}}
+	taken := map[string]bool{}
+	secrets := map[string]string{}
+
+	for i, r := range tx.records {
+		acc, reason := tx.checkRecord(r)
+		if reason != "" {
+			plan.quarantine[i] = reason
+			plan.problems = append(plan.problems, Problem{Account: r.Name, Message: reason, Repair: "move to quarantine"})
+			continue
+		}
+
+		// Lookups find the first account with a name, hiding the others
+		if taken[strings.ToLower(acc.Name)] {
+			newName := uniqueName(acc.Name, taken, tx)
+			plan.renames[i] = newName
+			plan.problems = append(plan.problems, Problem{
+				Account: acc.Name,
+				Message: "duplicate name hides this account",
+				Repair:  fmt.Sprintf("rename to %s", newName),
+			})
+			acc.Name = newName
+		}
+		taken[strings.ToLower(acc.Name)] = true
+
+		secret := strings.TrimRight(strings.ToUpper(strings.ReplaceAll(acc.Secret, " ", "")), "=")
+		if first, ok := secrets[secret]; ok {
+			plan.problems = append(plan.problems, Problem{Account: acc.Name, Message: fmt.Sprintf("same secret as %s", first)})
+		} else {
+			secrets[secret] = acc.Name
+		}
+	}
+
+	for i, t := range tx.trash {
+		if _, reason := tx.checkRecord(t.record); reason != "" {
+			plan.trash[i] = reason
+			plan.problems = append(plan.problems, Problem{Account: t.Name, Trashed: true, Message: reason, Repair: "move to quarantine"})
+		}
+	}
+
+	return plan
+}
+
+// uniqueName returns a name for a duplicate account that no account has.
+func uniqueName(name string, taken map[string]bool, tx *Tx) string {
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s (%d)", name, n)
+		if !taken[strings.ToLower(candidate)] && tx.find(candidate) < 0 && tx.findAlias(candidate) < 0 {
+			return candidate
+		}
+	}
+}
+
+// repair applies a repair plan, marking its problems repaired.
+func (tx *Tx) repair(plan repairPlan) {
+	now := time.Now().UTC()
+
+	for i, newName := range plan.renames {
+		tx.records[i].Name = newName
+		tx.records[i].UpdatedAt = now
+	}
+
+	records := tx.records[:0:0]
+	for i, r := range tx.records {
+		if reason, ok := plan.quarantine[i]; ok {
+			tx.quarantine = append(tx.quarantine, quarantineRecord{record: r, Reason: reason, QuarantinedAt: now})
+			continue
+		}
+		records = append(records, r)
+	}
+	trash := tx.trash[:0:0]
+	for i, t := range tx.trash {
+		if reason, ok := plan.trash[i]; ok {
+			tx.quarantine = append(tx.quarantine, quarantineRecord{record: t.record, Reason: "trashed, " + reason, QuarantinedAt: now})
+			continue
+		}
+		trash = append(trash, t)
+	}
+
+	tx.records, tx.trash = records, trash
+
+	for i := range plan.problems {
+		if plan.problems[i].Repair != "" {
+			plan.problems[i].Repaired = true
+			tx.changed = true
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bykclk/twocli/internal/totp"
+)
+
+func TestCheck(t *testing.T) {
+	s := newTestFileStore(t, "testpassword")
+	defer s.Close()
+
+	for _, name := range []string{"GitHub", "GitLab", "Trashed"} {
+		if err := AddAccount(s, Account{Name: name, Secret: "JBSWY3DPEHPK3PXP"}); err != nil {
+			t.Fatalf("Failed to add account: %v", err)
+		}
+	}
+	if err := s.Delete("Trashed"); err != nil {
+		t.Fatalf("Failed to delete account: %v", err)
+	}
+
+	// Damage the vault in ways the store itself never would
+	invalidSecret, err := s.key.Encrypt([]byte("not base32!"))
+	if err != nil {
+		t.Fatalf("Failed to encrypt secret: %v", err)
+	}
+	duplicate := s.records[0]
+	duplicate.Name = "github"
+	s.records = append(s.records,
+		record{Account: Account{Name: "Corrupted"}, EncryptedSecret: []byte("corrupted")},
+		record{Account: Account{Name: "Invalid"}, EncryptedSecret: invalidSecret},
+		record{Account: Account{Name: "BadType", Type: "motp"}, EncryptedSecret: s.records[0].EncryptedSecret},
+		duplicate,
+	)
+	s.trash[0].EncryptedSecret = []byte("corrupted")
+
+	// Listing does not decrypt, so it works, while getting the damaged account fails
+	if accounts, err := s.List(); err != nil || len(accounts) != 6 {
+		t.Fatalf("Expected to list 6 accounts of a damaged vault, got %d (%v)", len(accounts), err)
+	}
+	if _, err = s.Get("Corrupted"); err == nil {
+		t.Fatalf("Expected getting a corrupted account to fail")
+	}
+
+	want := []Problem{
+		{Account: "GitLab", Message: "same secret as GitHub"},
+		{Account: "Corrupted", Message: "secret cannot be decrypted", Repair: "move to quarantine"},
+		{Account: "Invalid", Message: "invalid secret: invalid base32 encoding: illegal base32 data at input byte 9", Repair: "move to quarantine"},
+		{Account: "BadType", Message: "unknown account type: motp", Repair: "move to quarantine"},
+		{Account: "github", Message: "duplicate name hides this account", Repair: "rename to github (2)"},
+		{Account: "github (2)", Message: "same secret as GitHub"},
+		{Account: "Trashed", Trashed: true, Message: "secret cannot be decrypted", Repair: "move to quarantine"},
+	}
+	checkProblems := func(report Report, repaired bool) {
+		t.Helper()
+		if len(report.Problems) != len(want) {
+			t.Fatalf("Expected %d problems, got %d: %+v", len(want), len(report.Problems), report.Problems)
+		}
+		for i, w := range want {
+			w.Repaired = repaired && w.Repair != ""
+			if report.Problems[i] != w {
+				t.Errorf("Problem %d = %+v, want %+v", i, report.Problems[i], w)
+			}
+		}
+	}
+
+	// Checking reports the problems without changing anything
+	report, err := Check(s, false)
+	if err != nil {
+		t.Fatalf("Check() unexpected error = %v", err)
+	}
+	checkProblems(report, false)
+	if report.Accounts != 6 || report.Trashed != 1 || report.Quarantined != 0 {
+		t.Fatalf("Unexpected counts %+v", report)
+	}
+
+	// Repairing quarantines unusable accounts and leaves the rest usable
+	if report, err = Check(s, true); err != nil {
+		t.Fatalf("Check() unexpected error = %v", err)
+	}
+	checkProblems(report, true)
+	if report.Accounts != 3 || report.Trashed != 0 || report.Quarantined != 4 {
+		t.Fatalf("Unexpected counts %+v", report)
+	}
+
+	s.Close()
+	if err = s.Open("testpassword"); err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	accounts, err := s.List()
+	if err != nil {
+		t.Fatalf("Failed to list repaired accounts: %v", err)
+	}
+	var names []string
+	for _, acc := range accounts {
+		names = append(names, acc.Name)
+	}
+	if len(names) != 3 || names[0] != "GitHub" || names[1] != "GitLab" || names[2] != "github (2)" {
+		t.Fatalf("Expected accounts [GitHub GitLab github (2)], got %v", names)
+	}
+	if len(s.quarantine) != 4 || s.quarantine[0].Reason != "secret cannot be decrypted" {
+		t.Fatalf("Unexpected quarantine %+v", s.quarantine)
+	}
+
+	// Only duplicate secrets, which are not repaired, remain
+	if report, err = Check(s, true); err != nil {
+		t.Fatalf("Check() unexpected error = %v", err)
+	}
+	for _, p := range report.Problems {
+		if p.Repair != "" {
+			t.Errorf("Unexpected problem after repair: %+v", p)
+		}
+	}
+
+	// The quarantine survives a password change, even with records that do not decrypt
+	if err = s.Rekey("newpassword", s.KDF()); err != nil {
+		t.Fatalf("Failed to change password: %v", err)
+	}
+	if len(s.quarantine) != 4 {
+		t.Fatalf("Expected 4 quarantined records after changing password, got %d", len(s.quarantine))
+	}
+}
+
+func TestCheckRecord(t *testing.T) {
+	s := newTestStore(t, "testpassword")
+	secret, err := s.key.Encrypt([]byte("JBSWY3DPEHPK3PXP"))
+	if err != nil {
+		t.Fatalf("Failed to encrypt secret: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		record record
+		want   string
+	}{
+		{"valid", record{Account: Account{Name: "GitHub"}, EncryptedSecret: secret}, ""},
+		{"valid HOTP", record{Account: Account{Name: "VPN", Type: totp.TypeHOTP}, EncryptedSecret: secret}, ""},
+		{"no name", record{EncryptedSecret: secret}, "account has no name"},
+		{"corrupted notes", record{Account: Account{Name: "GitHub"}, EncryptedSecret: secret, EncryptedNotes: []byte("x")}, "notes cannot be decrypted"},
+		{"invalid params", record{Account: Account{Name: "GitHub", Digits: 4}, EncryptedSecret: secret}, "invalid code parameters"},
+	}
+
+	tx, err := s.tx()
+	if err != nil {
+		t.Fatalf("Failed to start transaction: %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, reason := tx.checkRecord(tt.record)
+			if (tt.want == "" && reason != "") || !strings.HasPrefix(reason, tt.want) {
+				t.Errorf("checkRecord() = %q, want %q", reason, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bykclk/twocli/internal/totp"
+)
+
+// ConflictPolicy decides what Import does with an account whose name is
+// already taken.
+type ConflictPolicy string
+
+const (
+	// ConflictSkip leaves the existing account and drops the imported one.
+	ConflictSkip ConflictPolicy = "skip"
+
+	// ConflictRename stores the imported account under a free name, such as "GitHub (2)".
+	ConflictRename ConflictPolicy = "rename"
+
+	// ConflictOverwrite replaces the existing account, moving it to the trash
+	// if its secret differs.
+	ConflictOverwrite ConflictPolicy = "overwrite"
+)
+
+// ParseConflictPolicy parses the name of a conflict policy.
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch p := ConflictPolicy(strings.ToLower(s)); p {
+	case ConflictSkip, ConflictRename, ConflictOverwrite:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid conflict policy %q: use skip, rename or overwrite", s)
+}
+
+// Import results
+const (
+	ImportAdded       = "added"
+	ImportRenamed     = "renamed"
+	ImportOverwritten = "overwritten"
+	ImportSkipped     = "skipped"
+)
+
+// ImportResult is what Import did with an account.
+type ImportResult struct {
+	// Name is the name of the account in the import, and StoredAs the name it
+	// was stored under, which is empty if it was skipped.
+	Name     string
+	StoredAs string
+	Result   string
+}
+
+// Import adds the accounts to the store in a single transaction, resolving
+// name conflicts with the policy. A name that is the alias of an account
+// conflicts too, and is renamed rather than overwritten. Accounts are checked before anything is
+// stored, so an invalid account fails the whole import. Aliases that are the
+// name of an account are dropped, as they could never be used.
+func Import(s Store, accounts []Account, policy ConflictPolicy) ([]ImportResult, error) {
+	for _, acc := range accounts {
+		if err := checkImport(acc); err != nil {
+			return nil, fmt.Errorf("account '%s': %v", acc.Name, err)
+		}
+	}
+
+	var results []ImportResult
+	err := s.Update(func(tx *Tx) error {
+		results = make([]ImportResult, 0, len(accounts))
+		for _, acc := range accounts {
+			result := ImportResult{Name: acc.Name, Result: ImportAdded}
+			taken := tx.find(acc.Name) >= 0
+			switch {
+			case !taken && tx.findAlias(acc.Name) < 0:
+			case policy == ConflictSkip:
+				results = append(results, ImportResult{Name: acc.Name, Result: ImportSkipped})
+				continue
+			case policy == ConflictRename, policy == ConflictOverwrite && !taken:
+				// Only an account can be overwritten, so a name that is an alias is renamed
+				acc.Name = uniqueName(acc.Name, nil, tx)
+				result.Result = ImportRenamed
+			case policy == ConflictOverwrite:
+				result.Result = ImportOverwritten
+			default:
+				return fmt.Errorf("invalid conflict policy %q", policy)
+			}
+
+			var aliases []string
+			for _, alias := range acc.Aliases {
+				if !strings.EqualFold(alias, acc.Name) && tx.find(alias) < 0 {
+					aliases = append(aliases, alias)
+				}
+			}
+			acc.Aliases = aliases
+
+			if err := tx.Put(acc); err != nil {
+				return fmt.Errorf("failed to import account '%s': %v", result.Name, err)
+			}
+			result.StoredAs = acc.Name
+			results = append(results, result)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// checkImport checks that an account read from an import can be stored and used.
+func checkImport(acc Account) error {
+	if acc.Name == "" {
+		return errors.New("account has no name")
+	}
+	if err := totp.ValidateSecret(acc.Secret); err != nil {
+		return fmt.Errorf("invalid secret: %v", err)
+	}
+	if acc.Type != "" && acc.Type != totp.TypeTOTP && acc.Type != totp.TypeHOTP {
+		return fmt.Errorf("unknown account type: %s", acc.Type)
+	}
+	if err := acc.Params().Validate(); err != nil {
+		return fmt.Errorf("invalid code parameters: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"testing"
+)
+
+func TestImport(t *testing.T) {
+	tests := []struct {
+		policy ConflictPolicy
+		want   []ImportResult
+		secret string
+		names  []string
+	}{
+		{
+			policy: ConflictSkip,
+			want: []ImportResult{
+				{Name: "github", Result: ImportSkipped},
+				{Name: "GitLab", StoredAs: "GitLab", Result: ImportAdded},
+			},
+			secret: "JBSWY3DPEHPK3PXP",
+			names:  []string{"GitHub", "GitLab"},
+		},
+		{
+			policy: ConflictRename,
+			want: []ImportResult{
+				{Name: "github", StoredAs: "github (2)", Result: ImportRenamed},
+				{Name: "GitLab", StoredAs: "GitLab", Result: ImportAdded},
+			},
+			secret: "JBSWY3DPEHPK3PXP",
+			names:  []string{"GitHub", "github (2)", "GitLab"},
+		},
+		{
+			policy: ConflictOverwrite,
+			want: []ImportResult{
+				{Name: "github", StoredAs: "github", Result: ImportOverwritten},
+				{Name: "GitLab", StoredAs: "GitLab", Result: ImportAdded},
+			},
+			secret: "GEZDGNBVGY3TQOJQ",
+			names:  []string{"github", "GitLab"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			s := newTestStore(t, "testpassword")
+			if err := AddAccount(s, Account{Name: "GitHub", Secret: "JBSWY3DPEHPK3PXP", Aliases: []string{"gh"}}); err != nil {
+				t.Fatalf("Failed to add account: %v", err)
+			}
+
+			imported := []Account{
+				{Name: "github", Secret: "GEZDGNBVGY3TQOJQ", Notes: "imported"},
+				{Name: "GitLab", Secret: "GEZDGNBVGY3TQOJQ", Aliases: []string{"gl", "github"}},
+			}
+			results, err := Import(s, imported, tt.policy)
+			if err != nil {
+				t.Fatalf("Failed to import: %v", err)
+			}
+			if len(results) != len(tt.want) {
+				t.Fatalf("Expected %d results, got %+v", len(tt.want), results)
+			}
+			for i, w := range tt.want {
+				if results[i] != w {
+					t.Errorf("Result %d = %+v, want %+v", i, results[i], w)
+				}
+			}
+
+			accounts, err := s.List()
+			if err != nil {
+				t.Fatalf("Failed to list accounts: %v", err)
+			}
+			var names []string
+			for _, acc := range accounts {
+				names = append(names, acc.Name)
+			}
+			if len(names) != len(tt.names) {
+				t.Fatalf("Accounts = %v, want %v", names, tt.names)
+			}
+			for i := range names {
+				if names[i] != tt.names[i] {
+					t.Fatalf("Accounts = %v, want %v", names, tt.names)
+				}
+			}
+
+			if acc, _ := s.Get("GitHub"); acc.Secret != tt.secret {
+				t.Errorf("Expected GitHub to have secret %s, got %s", tt.secret, acc.Secret)
+			}
+
+			// An alias that is the name of an account is dropped
+			if acc, _ := s.Get("GitLab"); len(acc.Aliases) != 1 || acc.Aliases[0] != "gl" {
+				t.Errorf("Expected GitLab to have alias gl only, got %v", acc.Aliases)
+			}
+		})
+	}
+}
+
+func TestImportInvalid(t *testing.T) {
+	s := newTestStore(t, "testpassword")
+
+	imported := []Account{
+		{Name: "GitHub", Secret: "JBSWY3DPEHPK3PXP"},
+		{Name: "Broken", Secret: "not base32!"},
+	}
+	if _, err := Import(s, imported, ConflictSkip); err == nil {
+		t.Fatalf("Expected importing an invalid secret to fail")
+	}
+
+	// Nothing is imported when any account is invalid
+	if accounts, _ := s.List(); len(accounts) != 0 {
+		t.Errorf("Expected no accounts, got %d", len(accounts))
+	}
+}
+
+func TestParseConflictPolicy(t *testing.T) {
+	if p, err := ParseConflictPolicy("Rename"); err != nil || p != ConflictRename {
+		t.Errorf("ParseConflictPolicy(Rename) = %q, %v", p, err)
+	}
+	if _, err := ParseConflictPolicy("merge"); err == nil {
+		t.Errorf("Expected an unknown policy to fail")
+	}
+}
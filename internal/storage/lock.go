@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LockTimeout is how long opening the vault waits for another process to release it.
+var LockTimeout = 10 * time.Second
+
+// lockRetryInterval is how often a held lock is tried again.
+const lockRetryInterval = 50 * time.Millisecond
+
+// ErrVaultLocked is returned when the vault stays locked by another process for LockTimeout.
+var ErrVaultLocked = errors.New("vault is locked")
+
+// fileLock is an exclusive advisory lock on the vault's lock file. The vault
+// itself cannot be locked, as saving replaces it with a new file.
+type fileLock struct {
+	f *os.File
+}
+
+// lockFile takes the lock of the vault at path, waiting up to LockTimeout for
+// another process to release it. The lock file records the PID of the holder.
+func lockFile(path string) (*fileLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(LockTimeout)
+	for {
+		locked, err := tryLockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if locked {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			pid := lockHolder(f)
+			f.Close()
+			if pid == 0 {
+				return nil, fmt.Errorf("%w by another process", ErrVaultLocked)
+			}
+			return nil, fmt.Errorf("%w by PID %d", ErrVaultLocked, pid)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+
+	// The PID is informational, so failing to record it does not fail the lock
+	if err = f.Truncate(0); err == nil {
+		f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+
+	return &fileLock{f: f}, nil
+}
+
+// lockHolder returns the PID recorded in the lock file, or 0 if there is none.
+func lockHolder(f *os.File) int {
+	buf := make([]byte, 32)
+	n, _ := f.ReadAt(buf, 0)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(buf[:n])))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
+
+// unlock releases the lock. It does nothing when the lock is already released.
+func (l *fileLock) unlock() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+
+	l.f.Truncate(0)
+	err := unlockFile(l.f)
+	if closeErr := l.f.Close(); err == nil {
+		err = closeErr
+	}
+	l.f = nil
+	return err
+}
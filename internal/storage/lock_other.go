@@ -0,0 +1,15 @@
+//go:build !unix && !windows
+
+package storage
+
+import "os"
+
+// tryLockFile always succeeds: this platform has no file locking, so
+// concurrent twocli processes are not kept apart.
+func tryLockFile(f *os.File) (bool, error) {
+	return true, nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
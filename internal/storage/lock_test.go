@@ -0,0 +1,119 @@
+//go:build unix || windows
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Environment variables that turn the test binary into a concurrent writer
+const (
+	envWriter      = "TWOCLI_TEST_WRITER"
+	envWriterVault = "TWOCLI_TEST_WRITER_VAULT"
+)
+
+const (
+	concurrentWriters  = 4
+	writesPerWriter    = 5
+	concurrentPassword = "testpassword"
+)
+
+// TestConcurrentWriters runs several processes that each add accounts to the
+// same vault, one transaction at a time, and checks no account is lost.
+func TestConcurrentWriters(t *testing.T) {
+	s := newTestFileStore(t, concurrentPassword)
+	s.Close()
+
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatalf("Failed to find test binary: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, concurrentWriters)
+	for writer := 0; writer < concurrentWriters; writer++ {
+		wg.Add(1)
+		go func(writer int) {
+			defer wg.Done()
+			cmd := exec.Command(executable, "-test.run=^TestConcurrentWriterProcess$")
+			cmd.Env = append(os.Environ(), envWriter+"="+strconv.Itoa(writer), envWriterVault+"="+s.Path())
+			if out, err := cmd.CombinedOutput(); err != nil {
+				errs <- fmt.Errorf("writer %d failed: %v\n%s", writer, err, out)
+			}
+		}(writer)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if err = s.Open(concurrentPassword); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer s.Close()
+
+	accounts, err := s.List()
+	if err != nil {
+		t.Fatalf("Failed to load accounts: %v", err)
+	}
+	if len(accounts) != concurrentWriters*writesPerWriter {
+		t.Fatalf("Vault has %d accounts, want %d", len(accounts), concurrentWriters*writesPerWriter)
+	}
+}
+
+// TestConcurrentWriterProcess is a writer started by TestConcurrentWriters.
+func TestConcurrentWriterProcess(t *testing.T) {
+	writer := os.Getenv(envWriter)
+	if writer == "" {
+		t.Skip("only run by TestConcurrentWriters")
+	}
+	s := NewFileStore(os.Getenv(envWriterVault))
+	LockTimeout = time.Minute
+
+	// Each account is added in its own transaction, from opening the store to closing it
+	for i := 0; i < writesPerWriter; i++ {
+		name := fmt.Sprintf("Writer%s-%d", writer, i)
+		if err := s.Open(concurrentPassword); err != nil {
+			t.Fatalf("Failed to open store: %v", err)
+		}
+		if err := AddAccount(s, Account{Name: name, Secret: "JBSWY3DPEHPK3PXP"}); err != nil {
+			t.Fatalf("Failed to add account %s: %v", name, err)
+		}
+		s.Close()
+	}
+}
+
+func TestLockTimeout(t *testing.T) {
+	s := newTestFileStore(t, concurrentPassword)
+	defer s.Close()
+
+	defer func(timeout time.Duration) { LockTimeout = timeout }(LockTimeout)
+	LockTimeout = 100 * time.Millisecond
+
+	other := NewFileStore(s.Path())
+	err := other.Open(concurrentPassword)
+	if !errors.Is(err, ErrVaultLocked) {
+		t.Fatalf("Open() error = %v, want ErrVaultLocked", err)
+	}
+	if want := fmt.Sprintf("PID %d", os.Getpid()); !strings.Contains(err.Error(), want) {
+		t.Errorf("Open() error = %q, want it to name %s", err, want)
+	}
+
+	// The lock is released on Close
+	if err = s.Close(); err != nil {
+		t.Fatalf("Close() unexpected error = %v", err)
+	}
+	if err = other.Open(concurrentPassword); err != nil {
+		t.Fatalf("Open() after Close() unexpected error = %v", err)
+	}
+	other.Close()
+}
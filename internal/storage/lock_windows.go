@@ -0,0 +1,30 @@
+//go:build windows
+
+package storage
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockOffsetHigh places the locked byte at 4 GiB. Windows locks are mandatory,
+// so the locked range lies past the recorded PID to keep it readable.
+const lockOffsetHigh = 1
+
+// tryLockFile takes an exclusive lock on f without blocking. It reports
+// false when another open file holds the lock.
+func tryLockFile(f *os.File) (bool, error) {
+	ol := &windows.Overlapped{OffsetHigh: lockOffsetHigh}
+	err := windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, ol)
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func unlockFile(f *os.File) error {
+	ol := &windows.Overlapped{OffsetHigh: lockOffsetHigh}
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, ol)
+}
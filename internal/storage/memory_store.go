@@ -0,0 +1,45 @@
+package storage
+
+// MemoryStore is a Store kept in memory, for tests and for programs embedding
+// twocli that must not write to disk. Its contents are encrypted just like a
+// FileStore's, and are lost when it is garbage collected.
+type MemoryStore struct {
+	vault
+}
+
+// NewMemoryStore returns an empty store that has not been created yet.
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{}
+	s.backend = &memoryBackend{}
+	return s
+}
+
+// memoryBackend keeps the vault in a byte slice. It needs no lock, as a
+// MemoryStore cannot be opened twice.
+type memoryBackend struct {
+	data []byte
+}
+
+func (b *memoryBackend) exists() (bool, error) {
+	return b.data != nil, nil
+}
+
+func (b *memoryBackend) read() ([]byte, error) {
+	if b.data == nil {
+		return nil, ErrVaultNotFound
+	}
+	return b.data, nil
+}
+
+func (b *memoryBackend) write(data []byte, _ bool) error {
+	b.data = data
+	return nil
+}
+
+func (b *memoryBackend) removeBackups() error {
+	return nil
+}
+
+func (b *memoryBackend) lock() (func() error, error) {
+	return func() error { return nil }, nil
+}
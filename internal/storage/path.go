@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// EnvVault is the environment variable that overrides the vault location.
+const EnvVault = "TWOCLI_VAULT"
+
+// legacyDataFile is where vaults were kept before they moved to the user's
+// data directory, relative to the directory twocli was run from.
+const legacyDataFile = "data/accounts.db"
+
+// dataFile is the vault used by the package.
+var dataFile = defaultDataFile()
+
+// Path returns the location of the vault.
+func Path() string {
+	return dataFile
+}
+
+// SetPath sets the location of the vault.
+func SetPath(path string) {
+	dataFile = path
+}
+
+// DefaultPath returns the default location of the vault,
+// $XDG_DATA_HOME/twocli/vault or ~/.local/share/twocli/vault.
+func DefaultPath() (string, error) {
+	// Relative paths in XDG variables are invalid and must be ignored
+	if dataHome := os.Getenv("XDG_DATA_HOME"); filepath.IsAbs(dataHome) {
+		return filepath.Join(dataHome, "twocli", "vault"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "twocli", "vault"), nil
+}
+
+func defaultDataFile() string {
+	path, err := DefaultPath()
+	if err != nil {
+		return legacyDataFile
+	}
+	return path
+}
+
+// MigrateLegacyVault moves a vault from data/accounts.db in the current
+// directory, where earlier versions kept it, to the vault location. Nothing
+// is moved if a vault already exists there. It reports whether a vault was moved.
+func MigrateLegacyVault() (bool, error) {
+	// Checked first so that nothing is created at the vault location otherwise
+	if _, err := os.Stat(legacyDataFile); os.IsNotExist(err) {
+		return false, nil
+	}
+
+	lock, err := lockFile(dataFile)
+	if err != nil {
+		return false, err
+	}
+	defer lock.unlock()
+
+	exists, err := fileBackend{path: dataFile}.exists()
+	if err != nil || exists {
+		return false, err
+	}
+
+	data, err := os.ReadFile(legacyDataFile)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// Same file, for example when running from the data directory's parent with SetPath(legacyDataFile)
+	if abs, err := filepath.Abs(legacyDataFile); err == nil && abs == dataFile {
+		return false, nil
+	}
+
+	if err = os.MkdirAll(filepath.Dir(dataFile), 0700); err != nil {
+		return false, err
+	}
+	if err = writeFileAtomic(dataFile, data, 0600); err != nil {
+		return false, err
+	}
+
+	// The vault now lives at its new location; leaving a copy behind would
+	// keep the secrets readable with the old password after a password change
+	if err = os.Remove(legacyDataFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return true, err
+	}
+	// Only removed when empty
+	os.Remove(filepath.Dir(legacyDataFile))
+	return true, nil
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDefaultPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tests := []struct {
+		name     string
+		dataHome string
+		want     string
+	}{
+		{"XDG_DATA_HOME set", "/xdg/data", "/xdg/data/twocli/vault"},
+		{"XDG_DATA_HOME unset", "", filepath.Join(home, ".local", "share", "twocli", "vault")},
+		{"Relative XDG_DATA_HOME ignored", "data", filepath.Join(home, ".local", "share", "twocli", "vault")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_DATA_HOME", tt.dataHome)
+			got, err := DefaultPath()
+			if err != nil {
+				t.Fatalf("DefaultPath() unexpected error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("DefaultPath() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMigrateLegacyVault(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd() unexpected error = %v", err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		t.Fatalf("Chdir() unexpected error = %v", err)
+	}
+	defer os.Chdir(wd)
+
+	oldPath := Path()
+	defer SetPath(oldPath)
+	SetPath(filepath.Join(dir, "share", "twocli", "vault"))
+
+	// Nothing to move
+	if moved, err := MigrateLegacyVault(); moved || err != nil {
+		t.Fatalf("MigrateLegacyVault() = %v, %v, want nothing moved", moved, err)
+	}
+
+	if err = os.MkdirAll(filepath.Dir(legacyDataFile), 0700); err != nil {
+		t.Fatalf("Failed to create data directory: %v", err)
+	}
+	if err = os.WriteFile(legacyDataFile, []byte("legacy vault"), 0600); err != nil {
+		t.Fatalf("Failed to write legacy vault: %v", err)
+	}
+
+	moved, err := MigrateLegacyVault()
+	if err != nil || !moved {
+		t.Fatalf("MigrateLegacyVault() = %v, %v, want moved", moved, err)
+	}
+
+	data, err := os.ReadFile(Path())
+	if err != nil || string(data) != "legacy vault" {
+		t.Fatalf("Vault after migration = %q, %v", data, err)
+	}
+	if _, err = os.Stat(legacyDataFile); !os.IsNotExist(err) {
+		t.Errorf("Legacy vault should have been removed, Stat() error = %v", err)
+	}
+
+	// An existing vault is never replaced
+	if err = os.MkdirAll(filepath.Dir(legacyDataFile), 0700); err != nil {
+		t.Fatalf("Failed to create data directory: %v", err)
+	}
+	if err = os.WriteFile(legacyDataFile, []byte("other vault"), 0600); err != nil {
+		t.Fatalf("Failed to write legacy vault: %v", err)
+	}
+	if moved, err = MigrateLegacyVault(); moved || err != nil {
+		t.Fatalf("MigrateLegacyVault() = %v, %v, want nothing moved", moved, err)
+	}
+	if data, _ = os.ReadFile(Path()); string(data) != "legacy vault" {
+		t.Errorf("Vault was replaced with %q", data)
+	}
+}
@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+// DefaultProfile is the profile used when no other is selected. Its vault
+// is at DefaultPath, where vaults were kept before profiles existed.
+const DefaultProfile = "default"
+
+// ErrProfileNotFound is returned when a profile has no vault.
+var ErrProfileNotFound = errors.New("profile not found")
+
+// profileName matches valid profile names, which are also directory names.
+var profileName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+
+// ValidateProfileName checks that a profile name is usable.
+func ValidateProfileName(name string) error {
+	if !profileName.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q: use up to 64 letters, digits, '-' or '_'", name)
+	}
+	return nil
+}
+
+// profilesDir returns the directory holding the vaults of named profiles.
+func profilesDir() (string, error) {
+	path, err := DefaultPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), "profiles"), nil
+}
+
+// ProfilePath returns the location of a profile's vault: DefaultPath for the
+// default profile, and profiles/<name>/vault next to it for the others.
+func ProfilePath(name string) (string, error) {
+	if name == DefaultProfile {
+		return DefaultPath()
+	}
+	if err := ValidateProfileName(name); err != nil {
+		return "", err
+	}
+
+	dir, err := profilesDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name, "vault"), nil
+}
+
+// Profiles returns the names of the profiles with a vault, sorted, with the
+// default profile first whether or not its vault has been created.
+func Profiles() ([]string, error) {
+	dir, err := profilesDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() || ValidateProfileName(entry.Name()) != nil || entry.Name() == DefaultProfile {
+			continue
+		}
+		exists, err := fileBackend{path: filepath.Join(dir, entry.Name(), "vault")}.exists()
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	return append([]string{DefaultProfile}, names...), nil
+}
+
+// RemoveProfile deletes a named profile's vault and its backups.
+func RemoveProfile(name string) error {
+	if name == DefaultProfile {
+		return errors.New("the default profile cannot be removed")
+	}
+
+	path, err := ProfilePath(name)
+	if err != nil {
+		return err
+	}
+
+	s := NewFileStore(path)
+	exists, err := s.Exists()
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrProfileNotFound
+	}
+
+	if err = s.Remove(); err != nil {
+		return err
+	}
+	// Only removed when empty, so files the user put there are kept
+	os.Remove(filepath.Dir(path))
+	return nil
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestProfilePath(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", "/xdg/data")
+
+	tests := []struct {
+		name    string
+		profile string
+		want    string
+		wantErr bool
+	}{
+		{"Default", DefaultProfile, "/xdg/data/twocli/vault", false},
+		{"Named", "work", "/xdg/data/twocli/profiles/work/vault", false},
+		{"Path separator", "../work", "", true},
+		{"Hidden", ".work", "", true},
+		{"Empty", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ProfilePath(tt.profile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ProfilePath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ProfilePath() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	profiles, err := Profiles()
+	if err != nil {
+		t.Fatalf("Profiles() unexpected error = %v", err)
+	}
+	if !reflect.DeepEqual(profiles, []string{DefaultProfile}) {
+		t.Fatalf("Profiles() = %v, want only the default profile", profiles)
+	}
+
+	for _, name := range []string{"work", "personal"} {
+		path, err := ProfilePath(name)
+		if err != nil {
+			t.Fatalf("ProfilePath() unexpected error = %v", err)
+		}
+		s := NewFileStore(path)
+		if err = s.Create("testpassword", testKDF); err != nil {
+			t.Fatalf("Create() unexpected error = %v", err)
+		}
+		if err = s.Put(Account{Name: "GitHub", Secret: "JBSWY3DPEHPK3PXP"}); err != nil {
+			t.Fatalf("Put() unexpected error = %v", err)
+		}
+		s.Close()
+	}
+
+	profiles, err = Profiles()
+	if err != nil {
+		t.Fatalf("Profiles() unexpected error = %v", err)
+	}
+	if want := []string{DefaultProfile, "personal", "work"}; !reflect.DeepEqual(profiles, want) {
+		t.Fatalf("Profiles() = %v, want %v", profiles, want)
+	}
+
+	// Removing a profile deletes its vault, backups and directory
+	if err = RemoveProfile("work"); err != nil {
+		t.Fatalf("RemoveProfile() unexpected error = %v", err)
+	}
+	path, _ := ProfilePath("work")
+	if _, err = os.Stat(filepath.Dir(path)); !os.IsNotExist(err) {
+		t.Fatalf("Profile directory should have been removed, Stat() error = %v", err)
+	}
+	if profiles, _ = Profiles(); !reflect.DeepEqual(profiles, []string{DefaultProfile, "personal"}) {
+		t.Fatalf("Profiles() after removal = %v", profiles)
+	}
+
+	if err = RemoveProfile("work"); !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("RemoveProfile() error = %v, want ErrProfileNotFound", err)
+	}
+	if err = RemoveProfile(DefaultProfile); err == nil {
+		t.Errorf("RemoveProfile() should refuse to remove the default profile")
+	}
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The decrypted vault is a JSON document recording the version of its schema
+// along with the accounts:
+//
+//	{"version": 5, "accounts": [...], "trash": [...], "quarantine": [...]}
+//
+// Vaults written before the document existed hold a bare array of accounts,
+// which is version 1. Vaults are upgraded on load by applying, in order, the
+// migrations to versions after theirs, and are always saved in the current
+// version. A vault from a newer version is refused, so that fields this
+// version does not know about are never dropped.
+const schemaVersion = 5
+
+// ErrNewerVault is returned when opening a vault written by a newer version of twocli.
+var ErrNewerVault = errors.New("vault was written by a newer version of twocli, upgrade twocli to open it")
+
+// document is the decrypted vault.
+type document struct {
+	Version    int                `json:"version"`
+	Accounts   []record           `json:"accounts"`
+	Trash      []trashRecord      `json:"trash,omitempty"`
+	Quarantine []quarantineRecord `json:"quarantine,omitempty"`
+}
+
+// migration upgrades a document to a schema version from the one before it.
+type migration struct {
+	version int
+	migrate func(doc *document) error
+}
+
+// migrations lists every schema upgrade, ordered by version. A change to the
+// schema adds an entry here and bumps schemaVersion, even if it only adds
+// fields, so that older versions refuse the vault instead of dropping them.
+// Entries are never removed, so vaults of any age can be opened.
+var migrations = []migration{
+	{version: 2, migrate: migrateMetadata},
+	{version: 3, migrate: addFields}, // the trash
+	{version: 4, migrate: addFields}, // account aliases
+	{version: 5, migrate: addFields}, // the quarantine
+}
+
+// decodeVault decodes the decrypted vault and migrates it to the current
+// schema version. It reports whether any migration was applied.
+func decodeVault(data []byte) (document, bool, error) {
+	doc := document{Version: 1}
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(data, &doc.Accounts); err != nil {
+			return document{}, false, fmt.Errorf("invalid vault contents: %v", err)
+		}
+	} else {
+		// Check the version first, as a newer schema may not decode as this one
+		var header struct {
+			Version int `json:"version"`
+		}
+		if err := json.Unmarshal(data, &header); err != nil {
+			return document{}, false, fmt.Errorf("invalid vault contents: %v", err)
+		}
+		if header.Version > schemaVersion {
+			return document{}, false, ErrNewerVault
+		}
+		if header.Version < 2 {
+			return document{}, false, fmt.Errorf("invalid vault schema version %d", header.Version)
+		}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return document{}, false, fmt.Errorf("invalid vault contents: %v", err)
+		}
+	}
+
+	migrated := false
+	for _, m := range migrations {
+		if m.version <= doc.Version {
+			continue
+		}
+		if err := m.migrate(&doc); err != nil {
+			return document{}, false, fmt.Errorf("failed to upgrade the vault to version %d: %v", m.version, err)
+		}
+		doc.Version = m.version
+		migrated = true
+	}
+	return doc, migrated, nil
+}
+
+// encodeVault encodes the document in the current schema version.
+func encodeVault(doc document) ([]byte, error) {
+	doc.Version = schemaVersion
+	return json.Marshal(doc)
+}
+
+// migrateMetadata fills in the issuer and label of accounts stored before
+// they were recorded, from names in the "Issuer:label" form given to accounts
+// imported from QR codes.
+func migrateMetadata(doc *document) error {
+	for i, r := range doc.Accounts {
+		if r.Issuer != "" || r.Label != "" {
+			continue
+		}
+		issuer, label, ok := strings.Cut(r.Name, ":")
+		if !ok || issuer == "" || label == "" {
+			continue
+		}
+		doc.Accounts[i].Issuer, doc.Accounts[i].Label = issuer, label
+	}
+	return nil
+}
+
+// addFields is the migration to versions that only add optional fields,
+// which documents from earlier versions do without.
+func addFields(*document) error {
+	return nil
+}
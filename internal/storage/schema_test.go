@@ -0,0 +1,225 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/bykclk/twocli/internal/crypto"
+	"github.com/bykclk/twocli/internal/totp"
+)
+
+// fixturePassword protects the vaults in testdata, each written by the
+// version of twocli that introduced its format.
+const fixturePassword = "fixturepassword"
+
+func TestOpenFixtures(t *testing.T) {
+	github := Account{Name: "GitHub", Secret: "JBSWY3DPEHPK3PXP"}
+	acmePlain := Account{Name: "ACME Co:john.doe@email.com", Secret: "HXDMVJECJJWSRB3HWIZR4IFUGFTMXBOZ"}
+	acme := acmePlain
+	acme.Issuer, acme.Label = "ACME Co", "john.doe@email.com"
+	corporate := Account{Name: "Corporate", Secret: "GEZDGNBVGY3TQOJQ"}
+	corporate.SetParams(totp.Params{Algorithm: totp.AlgorithmSHA256, Digits: 8, Period: 60})
+	vpn := Account{Name: "VPN", Secret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", Type: totp.TypeHOTP, Counter: 5}
+	vpn.SetParams(totp.Params{})
+	githubMetadata := github
+	githubMetadata.Issuer, githubMetadata.Label = "GitHub", "john.doe"
+	githubMetadata.Tags = []string{"work", "dev"}
+	githubMetadata.Notes = "Recovery codes in the safe"
+	githubAliases := githubMetadata
+	githubAliases.Aliases = []string{"gh"}
+
+	tests := []struct {
+		fixture  string
+		want     []Account
+		migrated bool
+		trash    int
+	}{
+		// Encrypted with PBKDF2 and without a header, before code parameters existed
+		{"pbkdf2.vault", []Account{github, acme}, true, 0},
+		// Encrypted with PBKDF2 and without a header, with code parameters and HOTP
+		{"params.vault", []Account{github, acme, corporate, vpn}, true, 0},
+		// Version 1 header, encrypted under the key derived from the password
+		{"header.vault", []Account{github, acme, corporate, vpn}, true, 0},
+		// Version 2 header with a data key, holding a bare array of accounts
+		{"datakey.vault", []Account{github, acme, corporate, vpn}, true, 0},
+		// As above, with account metadata
+		{"metadata.vault", []Account{githubMetadata, acme, corporate, vpn}, true, 0},
+		// Schema version 2 document, which migrations to version 2 leave alone
+		{"schema2.vault", []Account{githubMetadata, acmePlain, corporate, vpn}, true, 0},
+		// Schema version 3 document, with an account in the trash
+		{"schema3.vault", []Account{githubMetadata, acmePlain, corporate, vpn}, true, 1},
+		// Schema version 4 document, with account aliases
+		{"schema4.vault", []Account{githubAliases, acmePlain, corporate, vpn}, true, 1},
+		// Schema version 5 document, which adds the quarantine
+		{"schema5.vault", []Account{githubAliases, acmePlain, corporate, vpn}, false, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", tt.fixture))
+			if err != nil {
+				t.Fatalf("Failed to read fixture: %v", err)
+			}
+			path := filepath.Join(t.TempDir(), "vault")
+			if err = os.WriteFile(path, data, 0600); err != nil {
+				t.Fatalf("Failed to copy fixture: %v", err)
+			}
+
+			s := NewFileStore(path)
+			if err = s.Open(fixturePassword); err != nil {
+				t.Fatalf("Failed to open fixture: %v", err)
+			}
+			accounts, err := s.List()
+			if err != nil {
+				t.Fatalf("Failed to list accounts: %v", err)
+			}
+			for i, acc := range accounts {
+				if accounts[i], err = s.Get(acc.Name); err != nil {
+					t.Fatalf("Failed to get account: %v", err)
+				}
+			}
+			trash, err := s.Trash()
+			s.Close()
+			if err != nil {
+				t.Fatalf("Failed to list the trash: %v", err)
+			}
+			if len(trash) != tt.trash {
+				t.Fatalf("Expected %d accounts in the trash, got %d", tt.trash, len(trash))
+			}
+
+			if len(accounts) != len(tt.want) {
+				t.Fatalf("Expected %d accounts, got %d", len(tt.want), len(accounts))
+			}
+			for i, want := range tt.want {
+				got := accounts[i]
+				got.CreatedAt, got.UpdatedAt, got.LastUsedAt = want.CreatedAt, want.UpdatedAt, want.LastUsedAt
+				if got.Name != want.Name || got.Secret != want.Secret || got.Params() != want.Params() ||
+					got.Type != want.Type || got.Counter != want.Counter || got.Issuer != want.Issuer ||
+					got.Label != want.Label || got.Notes != want.Notes || !slices.Equal(got.Tags, want.Tags) || !slices.Equal(got.Aliases, want.Aliases) {
+					t.Errorf("Account %d = %+v, want %+v", i, got, want)
+				}
+			}
+
+			// Upgraded vaults are saved in the current version, keeping the original as a backup
+			backups, err := s.Backups()
+			if err != nil {
+				t.Fatalf("Backups() unexpected error = %v", err)
+			}
+			if migrated := len(backups) > 0; migrated != tt.migrated {
+				t.Fatalf("Expected vault to be rewritten: %v, got %v", tt.migrated, migrated)
+			}
+			if version := readSchemaVersion(t, path); version != schemaVersion {
+				t.Fatalf("Expected schema version %d after opening, got %d", schemaVersion, version)
+			}
+		})
+	}
+}
+
+// readSchemaVersion returns the schema version of the vault file.
+func readSchemaVersion(t *testing.T, path string) int {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read vault: %v", err)
+	}
+	jsonData, _, err := crypto.OpenVault(data, fixturePassword)
+	if err != nil {
+		t.Fatalf("Failed to decrypt vault: %v", err)
+	}
+	var doc document
+	if err = json.Unmarshal(jsonData, &doc); err != nil {
+		t.Fatalf("Expected a versioned document, got %s", jsonData)
+	}
+	return doc.Version
+}
+
+func TestOpenNewerVault(t *testing.T) {
+	s := newTestStore(t, "testpassword")
+	data, err := s.key.Seal([]byte(`{"version":99,"accounts":{"GitHub":{}},"folders":[]}`))
+	if err != nil {
+		t.Fatalf("Failed to seal vault: %v", err)
+	}
+	s.Close()
+	backend := s.backend.(*memoryBackend)
+	backend.data = data
+
+	if err = s.Open("testpassword"); !errors.Is(err, ErrNewerVault) {
+		t.Fatalf("Expected newer vault error, got %v", err)
+	}
+	if !bytes.Equal(backend.data, data) {
+		t.Fatalf("Expected a newer vault to be left untouched")
+	}
+}
+
+func TestDecodeVault(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         string
+		wantAccounts int
+		wantMigrated bool
+		wantErr      error
+	}{
+		{"bare array", `[{"name":"GitHub"}]`, 1, true, nil},
+		{"empty bare array", `[]`, 0, true, nil},
+		{"version 2", `{"version":2,"accounts":[{"name":"GitHub"}]}`, 1, true, nil},
+		{"version 3", `{"version":3,"accounts":[{"name":"GitHub"}],"trash":[]}`, 1, true, nil},
+		{"version 4", `{"version":4,"accounts":[{"name":"GitHub","aliases":["gh"]}]}`, 1, true, nil},
+		{"current version", `{"version":5,"accounts":[{"name":"GitHub"}],"quarantine":[]}`, 1, false, nil},
+		{"newer version", `{"version":6,"accounts":"unknown"}`, 0, false, ErrNewerVault},
+		{"missing version", `{"accounts":[]}`, 0, false, errors.New("invalid vault schema version 0")},
+		{"invalid JSON", `{"version":`, 0, false, errors.New("invalid vault contents")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, migrated, err := decodeVault([]byte(tt.data))
+			if tt.wantErr != nil {
+				if err == nil || (!errors.Is(err, tt.wantErr) && !strings.HasPrefix(err.Error(), tt.wantErr.Error())) {
+					t.Fatalf("decodeVault() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeVault() unexpected error = %v", err)
+			}
+			if len(doc.Accounts) != tt.wantAccounts || migrated != tt.wantMigrated || doc.Version != schemaVersion {
+				t.Fatalf("decodeVault() = %d accounts in version %d, migrated %v, want %d, %v",
+					len(doc.Accounts), doc.Version, migrated, tt.wantAccounts, tt.wantMigrated)
+			}
+		})
+	}
+}
+
+func TestMigrateMetadata(t *testing.T) {
+	tests := []struct {
+		name       string
+		account    Account
+		wantIssuer string
+		wantLabel  string
+	}{
+		{"issuer and label", Account{Name: "GitHub:alice"}, "GitHub", "alice"},
+		{"plain name", Account{Name: "GitHub"}, "", ""},
+		{"empty label", Account{Name: "GitHub:"}, "", ""},
+		{"already set", Account{Name: "GitHub:alice", Issuer: "Git"}, "Git", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := document{Version: 1, Accounts: []record{{Account: tt.account}}}
+			if err := migrateMetadata(&doc); err != nil {
+				t.Fatalf("migrateMetadata() unexpected error = %v", err)
+			}
+			if got := doc.Accounts[0]; got.Issuer != tt.wantIssuer || got.Label != tt.wantLabel {
+				t.Errorf("Got issuer %q and label %q, want %q and %q",
+					got.Issuer, got.Label, tt.wantIssuer, tt.wantLabel)
+			}
+		})
+	}
+}
@@ -1,197 +1,192 @@
 package storage
 
 import (
-	"encoding/json"
 	"errors"
-	"os"
-	"path/filepath"
+	"fmt"
 	"strings"
+	"time"
 
-	"github.com/bykclk/twocli/internal/crypto"
+	"github.com/bykclk/twocli/internal/totp"
 )
 
-const dataFile = "data/accounts.db"
-
-// Account represents an account with a name and encrypted secret.
+// Account represents an account with a name, secret, code parameters and
+// descriptive metadata. The secret and notes are encrypted under the vault's
+// data key when the account is stored.
+// Zero-valued parameters mean the TOTP defaults, so vaults written before they existed still load.
+// For HOTP accounts, Counter is the counter value used to generate the next code.
+// For TOTP accounts, LastStep is the last time step accepted by VerifyCode with the replay guard.
+// Issuer and Label are the service and the user's account with it, as in an otpauth URI.
+// Aliases are other names the account can be looked up by.
+// Zero timestamps are unknown, as for accounts stored before they were recorded.
 type Account struct {
-	Name            string `json:"name"`
-	EncryptedSecret []byte `json:"encrypted_secret"`
+	Name       string    `json:"name"`
+	Secret     string    `json:"-"`
+	Type       string    `json:"type,omitempty"`
+	Algorithm  string    `json:"algorithm,omitempty"`
+	Digits     int       `json:"digits,omitempty"`
+	Period     int       `json:"period,omitempty"`
+	Counter    uint64    `json:"counter,omitempty"`
+	LastStep   uint64    `json:"last_step,omitempty"`
+	Issuer     string    `json:"issuer,omitempty"`
+	Label      string    `json:"label,omitempty"`
+	Tags       []string  `json:"tags,omitempty"`
+	Aliases    []string  `json:"aliases,omitempty"`
+	Notes      string    `json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
 }
 
-// LoadAccounts loads and decrypts the accounts from the data file.
-func LoadAccounts(masterPassword string) ([]Account, error) {
-	// Check if data file exists
-	if _, err := os.Stat(dataFile); os.IsNotExist(err) {
-		return []Account{}, nil // No accounts yet
-	}
-
-	// Read the encrypted data file
-	encryptedData, err := os.ReadFile(dataFile)
-	if err != nil {
-		return nil, err
-	}
-
-	// Decrypt the data
-	jsonData, err := crypto.DecryptData(encryptedData, masterPassword)
-	if err != nil {
-		return nil, errors.New("incorrect master password")
-	}
-
-	// Unmarshal JSON data
-	var accounts []Account
-	if err = json.Unmarshal(jsonData, &accounts); err != nil {
-		return nil, err
-	}
-
-	return accounts, nil
+// IsHOTP reports whether the account uses counter-based codes.
+func (a Account) IsHOTP() bool {
+	return a.Type == totp.TypeHOTP
 }
 
-// saveAccounts encrypts and saves the accounts to the data file.
-func saveAccounts(accounts []Account, masterPassword string) error {
-	// Marshal accounts to JSON
-	jsonData, err := json.Marshal(accounts)
-	if err != nil {
-		return err
-	}
-
-	// Encrypt the data
-	encryptedData, err := crypto.EncryptData(jsonData, masterPassword)
-	if err != nil {
-		return err
-	}
-
-	// Ensure data directory exists
-	if err = os.MkdirAll(filepath.Dir(dataFile), 0700); err != nil {
-		return err
-	}
-
-	// Write encrypted data to file
-	err = os.WriteFile(dataFile, encryptedData, 0600)
-	if err != nil {
-		return err
-	}
-
-	return nil
+// Params returns the code parameters of the account with defaults filled in.
+func (a Account) Params() totp.Params {
+	return totp.Params{
+		Algorithm: a.Algorithm,
+		Digits:    a.Digits,
+		Period:    a.Period,
+	}.WithDefaults()
 }
 
-// AddAccount adds a new account to the storage.
-func AddAccount(name, secret, masterPassword string) error {
-	accounts, err := LoadAccounts(masterPassword)
-	if err != nil {
-		return err
-	}
-
-	// Check for duplicate account name
-	for _, acc := range accounts {
-		if strings.EqualFold(acc.Name, name) {
-			return errors.New("account with this name already exists")
+// HasTag reports whether the account has the tag, matched case-insensitively.
+func (a Account) HasTag(tag string) bool {
+	for _, t := range a.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
 		}
 	}
+	return false
+}
 
-	// Encrypt the secret
-	encryptedSecret, err := crypto.EncryptData([]byte(secret), masterPassword)
-	if err != nil {
-		return err
+// HasAlias reports whether the account has the alias, matched case-insensitively.
+func (a Account) HasAlias(alias string) bool {
+	for _, al := range a.Aliases {
+		if strings.EqualFold(al, alias) {
+			return true
+		}
 	}
+	return false
+}
 
-	// Add the new account
-	accounts = append(accounts, Account{
-		Name:            name,
-		EncryptedSecret: encryptedSecret,
-	})
-
-	// Save accounts
-	if err = saveAccounts(accounts, masterPassword); err != nil {
-		return err
-	}
+// SetParams sets the code parameters of the account, filling in defaults.
+func (a *Account) SetParams(params totp.Params) {
+	params = params.WithDefaults()
+	a.Algorithm = params.Algorithm
+	a.Digits = params.Digits
+	a.Period = params.Period
+}
 
-	return nil
+// record is an account as stored in the vault, with its secret and notes
+// encrypted under the vault's data key.
+type record struct {
+	Account
+	EncryptedSecret []byte `json:"encrypted_secret"`
+	EncryptedNotes  []byte `json:"encrypted_notes,omitempty"`
 }
 
-// GetAccountSecret retrieves and decrypts the secret for a given account name.
-func GetAccountSecret(name, masterPassword string) (string, error) {
-	accounts, err := LoadAccounts(masterPassword)
-	if err != nil {
-		return "", err
-	}
+// AddAccount stores a new account, failing if an account with the same name exists.
+func AddAccount(s Store, account Account) error {
+	return s.Update(func(tx *Tx) error {
+		return addAccount(tx, account)
+	})
+}
 
-	// Find the account
-	for _, acc := range accounts {
-		if strings.EqualFold(acc.Name, name) {
-			// Decrypt the secret
-			secretData, err := crypto.DecryptData(acc.EncryptedSecret, masterPassword)
-			if err != nil {
-				return "", err
+// AddAccounts stores new accounts in a single transaction, so if any of them
+// cannot be added, such as because its name is taken, none of them is.
+func AddAccounts(s Store, accounts []Account) error {
+	return s.Update(func(tx *Tx) error {
+		for _, account := range accounts {
+			if err := addAccount(tx, account); err != nil {
+				return fmt.Errorf("account '%s': %w", account.Name, err)
 			}
-			return string(secretData), nil
 		}
-	}
-
-	return "", errors.New("account not found")
+		return nil
+	})
 }
 
-func DeleteAccount(name, masterPassword string) error {
-	accounts, err := LoadAccounts(masterPassword)
-	if err != nil {
-		return err
+func addAccount(tx *Tx, account Account) error {
+	if tx.find(account.Name) >= 0 {
+		return ErrAccountExists
 	}
+	return tx.Put(account)
+}
 
-	// Find and delete the account
-	index := -1
-	for i, acc := range accounts {
-		if strings.EqualFold(acc.Name, name) {
-			index = i
-			break
+// NextHOTPCode generates the code for the current counter of an HOTP account
+// and persists the incremented counter, recording the account as used. It
+// returns the code and the counter used.
+func NextHOTPCode(s Store, name string) (uint32, uint64, error) {
+	var code uint32
+	var counter uint64
+	err := s.Update(func(tx *Tx) error {
+		acc, err := tx.Get(name)
+		if err != nil {
+			return err
+		}
+		if !acc.IsHOTP() {
+			return errors.New("account is not an HOTP account")
 		}
-	}
-
-	if index == -1 {
-		return errors.New("account not found")
-	}
-
-	// Remove the account from the slice
-	accounts = append(accounts[:index], accounts[index+1:]...)
-
-	// Save the updated accounts
-	if err = saveAccounts(accounts, masterPassword); err != nil {
-		return err
-	}
 
-	return nil
-}
+		if code, err = totp.GenerateHOTP(acc.Secret, acc.Counter, acc.Params()); err != nil {
+			return err
+		}
 
-// UpdateAccount updates the secret of an existing account.
-func UpdateAccount(name, newSecret, masterPassword string) error {
-	accounts, err := LoadAccounts(masterPassword)
+		// Persist the counter before the code is shown so it is never reused
+		counter = acc.Counter
+		acc.Counter++
+		return tx.use(acc)
+	})
 	if err != nil {
-		return err
+		return 0, 0, err
 	}
+	return code, counter, nil
+}
 
-	// Find the account
-	found := false
-	for i, acc := range accounts {
-		if strings.EqualFold(acc.Name, name) {
-			// Encrypt the new secret
-			encryptedSecret, err := crypto.EncryptData([]byte(newSecret), masterPassword)
-			if err != nil {
-				return err
-			}
+// MarkUsed records that a code of the named account was used now.
+func MarkUsed(s Store, name string) error {
+	return s.Update(func(tx *Tx) error {
+		acc, err := tx.Get(name)
+		if err != nil {
+			return err
+		}
+		return tx.use(acc)
+	})
+}
 
-			// Update the secret
-			accounts[i].EncryptedSecret = encryptedSecret
-			found = true
-			break
+// VerifyCode checks a code for a TOTP account within the given skew window
+// and records the account as used when it matches. With replayGuard set, codes
+// for steps at or before the last accepted one are rejected, and the matched
+// step is persisted.
+func VerifyCode(s Store, name, code string, skew int, replayGuard bool) (totp.Match, error) {
+	var match totp.Match
+	err := s.Update(func(tx *Tx) error {
+		acc, err := tx.Get(name)
+		if err != nil {
+			return err
+		}
+		if acc.IsHOTP() {
+			return errors.New("account is not a TOTP account")
 		}
-	}
 
-	if !found {
-		return errors.New("account not found")
-	}
+		match, err = totp.Verify(acc.Secret, code, totp.VerifyOptions{
+			Params:      acc.Params(),
+			Skew:        skew,
+			LastStep:    acc.LastStep,
+			ReplayGuard: replayGuard,
+		})
+		if err != nil {
+			return err
+		}
 
-	// Save the updated accounts
-	if err = saveAccounts(accounts, masterPassword); err != nil {
-		return err
+		if replayGuard {
+			acc.LastStep = match.Step
+		}
+		return tx.use(acc)
+	})
+	if err != nil {
+		return totp.Match{}, err
 	}
-
-	return nil
+	return match, nil
 }
@@ -1,69 +1,603 @@
 package storage
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/bykclk/twocli/internal/crypto"
+	"github.com/bykclk/twocli/internal/totp"
 )
 
-func cleanup() {
-	if err := os.Remove(dataFile); err != nil && !os.IsNotExist(err) {
-		println("Warning: Failed to clean up data file:", err)
+// testKDF is the cheapest key derivation cost accepted, to keep tests fast.
+var testKDF = crypto.KDFParams{Algorithm: crypto.KDFArgon2id, Time: 1, Memory: 8 * 1024, Threads: 1}
+
+// newTestStore creates an open in-memory store protected by the master password.
+func newTestStore(t testing.TB, masterPassword string) *MemoryStore {
+	t.Helper()
+
+	s := NewMemoryStore()
+	if err := s.Create(masterPassword, testKDF); err != nil {
+		t.Fatalf("Failed to create store: %v", err)
 	}
+	return s
 }
 
-func TestAddDeleteAccount(t *testing.T) {
-	defer cleanup()
+// newTestFileStore creates an open store in a temporary directory, protected
+// by the master password.
+func newTestFileStore(t testing.TB, masterPassword string) *FileStore {
+	t.Helper()
 
-	masterPassword := "testpassword"
+	s := NewFileStore(filepath.Join(t.TempDir(), "vault"))
+	if err := s.Create(masterPassword, testKDF); err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	return s
+}
+
+func TestAddDeleteAccount(t *testing.T) {
+	s := newTestStore(t, "testpassword")
 	accountName := "TestAccount"
 	secret := "JBSWY3DPEHPK3PXP"
 
 	// Add account
-	err := AddAccount(accountName, secret, masterPassword)
+	err := AddAccount(s, Account{Name: accountName, Secret: secret})
 	if err != nil {
 		t.Fatalf("Failed to add account: %v", err)
 	}
 
+	// Names are unique, regardless of case
+	if err = AddAccount(s, Account{Name: "testaccount", Secret: secret}); !errors.Is(err, ErrAccountExists) {
+		t.Fatalf("Expected account exists error, got %v", err)
+	}
+
 	// Delete account
-	err = DeleteAccount(accountName, masterPassword)
+	err = s.Delete(accountName)
 	if err != nil {
 		t.Fatalf("Failed to delete account: %v", err)
 	}
 
 	// Try to get the deleted account
-	_, err = GetAccountSecret(accountName, masterPassword)
-	if err == nil {
-		t.Fatalf("Expected error when getting deleted account")
+	_, err = s.Get(accountName)
+	if !errors.Is(err, ErrAccountNotFound) {
+		t.Fatalf("Expected account not found error, got %v", err)
+	}
+	if err = s.Delete(accountName); !errors.Is(err, ErrAccountNotFound) {
+		t.Fatalf("Expected account not found error, got %v", err)
 	}
 }
 
-func TestUpdateAccount(t *testing.T) {
-	defer cleanup()
+func TestAddAccounts(t *testing.T) {
+	s := newTestStore(t, "testpassword")
+	if err := AddAccount(s, Account{Name: "GitLab", Secret: "JBSWY3DPEHPK3PXP"}); err != nil {
+		t.Fatalf("Failed to add account: %v", err)
+	}
 
-	masterPassword := "testpassword"
+	// A taken name fails the whole batch, even the accounts before it
+	accounts := []Account{
+		{Name: "GitHub", Secret: "JBSWY3DPEHPK3PXP"},
+		{Name: "gitlab", Secret: "GEZDGNBVGY3TQOJQ"},
+	}
+	if err := AddAccounts(s, accounts); !errors.Is(err, ErrAccountExists) {
+		t.Fatalf("Expected account exists error, got %v", err)
+	}
+	if _, err := s.Get("GitHub"); !errors.Is(err, ErrAccountNotFound) {
+		t.Fatalf("Expected GitHub not to be added, got %v", err)
+	}
+
+	accounts[1].Name = "Bitbucket"
+	if err := AddAccounts(s, accounts); err != nil {
+		t.Fatalf("Failed to add accounts: %v", err)
+	}
+	if list, _ := s.List(); len(list) != 3 {
+		t.Fatalf("Expected 3 accounts, got %d", len(list))
+	}
+}
+
+func TestUpdateAccount(t *testing.T) {
+	s := newTestStore(t, "testpassword")
 	accountName := "TestAccount"
 	secret := "OLDSECRET"
 	newSecret := "NEWSECRET"
 
 	// Add account
-	err := AddAccount(accountName, secret, masterPassword)
+	err := s.Put(Account{Name: accountName, Secret: secret})
 	if err != nil {
 		t.Fatalf("Failed to add account: %v", err)
 	}
 
-	// Update account
-	err = UpdateAccount(accountName, newSecret, masterPassword)
+	// Putting an account with the same name replaces it
+	err = s.Put(Account{Name: accountName, Secret: newSecret})
 	if err != nil {
 		t.Fatalf("Failed to update account: %v", err)
 	}
 
-	// Get the updated secret
-	retrievedSecret, err := GetAccountSecret(accountName, masterPassword)
+	accounts, err := s.List()
+	if err != nil {
+		t.Fatalf("Failed to list accounts: %v", err)
+	}
+	if len(accounts) != 1 {
+		t.Fatalf("Expected 1 account, got %d", len(accounts))
+	}
+	if accounts[0].Secret != "" {
+		t.Fatalf("Expected listing not to decrypt the secret")
+	}
+
+	acc, err := s.Get(accountName)
+	if err != nil || acc.Secret != newSecret {
+		t.Fatalf("Expected secret '%s', got '%s' (%v)", newSecret, acc.Secret, err)
+	}
+}
+
+func TestUpdateTransaction(t *testing.T) {
+	s := newTestStore(t, "testpassword")
+	for _, name := range []string{"GitHub", "GitLab"} {
+		if err := s.Put(Account{Name: name, Secret: "JBSWY3DPEHPK3PXP"}); err != nil {
+			t.Fatalf("Failed to add account: %v", err)
+		}
+	}
+
+	// A failed transaction changes nothing, even changes made before the failure
+	errAbort := errors.New("abort")
+	err := s.Update(func(tx *Tx) error {
+		if err := tx.Delete("GitHub"); err != nil {
+			return err
+		}
+		if _, err := tx.Get("GitHub"); !errors.Is(err, ErrAccountNotFound) {
+			t.Errorf("Transaction should see its own delete, got %v", err)
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("Update() error = %v, want %v", err, errAbort)
+	}
+	if _, err = s.Get("GitHub"); err != nil {
+		t.Fatalf("Aborted delete should not be applied: %v", err)
+	}
+
+	// A successful one applies all of its changes
+	err = s.Update(func(tx *Tx) error {
+		if err := tx.Delete("GitHub"); err != nil {
+			return err
+		}
+		return tx.Put(Account{Name: "Bitbucket", Secret: "GEZDGNBVGY3TQOJQ"})
+	})
+	if err != nil {
+		t.Fatalf("Update() unexpected error = %v", err)
+	}
+
+	accounts, err := s.List()
+	if err != nil {
+		t.Fatalf("Failed to list accounts: %v", err)
+	}
+	var names []string
+	for _, acc := range accounts {
+		names = append(names, acc.Name)
+	}
+	if len(names) != 2 || names[0] != "GitLab" || names[1] != "Bitbucket" {
+		t.Fatalf("Expected accounts [GitLab Bitbucket], got %v", names)
+	}
+}
+
+func TestAccountMetadata(t *testing.T) {
+	s := newTestFileStore(t, "testpassword")
+	defer s.Close()
+
+	acc := Account{
+		Name:   "GitHub",
+		Secret: "JBSWY3DPEHPK3PXP",
+		Issuer: "GitHub",
+		Label:  "alice@example.com",
+		Tags:   []string{"Work", "dev"},
+		Notes:  "recovery codes in the safe",
+	}
+	if err := AddAccount(s, acc); err != nil {
+		t.Fatalf("Failed to add account: %v", err)
+	}
+
+	// Notes are encrypted like the secret
+	data, err := os.ReadFile(s.Path())
+	if err != nil {
+		t.Fatalf("Failed to read vault: %v", err)
+	}
+	if bytes.Contains(data, []byte(acc.Notes)) {
+		t.Fatalf("Expected notes to be encrypted in the vault")
+	}
+
+	s.Close()
+	if err = s.Open("testpassword"); err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	got, err := s.Get("github")
+	if err != nil {
+		t.Fatalf("Failed to get account: %v", err)
+	}
+	if got.Issuer != acc.Issuer || got.Label != acc.Label || got.Notes != acc.Notes {
+		t.Fatalf("Expected metadata %+v, got %+v", acc, got)
+	}
+	if !got.HasTag("work") || got.HasTag("personal") {
+		t.Fatalf("Unexpected tags %v", got.Tags)
+	}
+	if got.CreatedAt.IsZero() || !got.UpdatedAt.Equal(got.CreatedAt) || !got.LastUsedAt.IsZero() {
+		t.Fatalf("Unexpected timestamps for a new account: %+v", got)
+	}
+
+	// Editing keeps the creation time, and clearing the notes removes them
+	got.Notes = ""
+	if err = s.Put(got); err != nil {
+		t.Fatalf("Failed to update account: %v", err)
+	}
+	edited, err := s.Get("GitHub")
+	if err != nil {
+		t.Fatalf("Failed to get account: %v", err)
+	}
+	if !edited.CreatedAt.Equal(got.CreatedAt) || edited.UpdatedAt.Before(got.UpdatedAt) {
+		t.Fatalf("Unexpected timestamps after an edit: %+v", edited)
+	}
+	if edited.Notes != "" {
+		t.Fatalf("Expected notes to be cleared, got %q", edited.Notes)
+	}
+
+	// Replacing it with an account without a creation time keeps the stored one
+	if err = s.Put(Account{Name: "GitHub", Secret: "JBSWY3DPEHPK3PXP"}); err != nil {
+		t.Fatalf("Failed to overwrite account: %v", err)
+	}
+	overwritten, err := s.Get("GitHub")
+	if err != nil {
+		t.Fatalf("Failed to get account: %v", err)
+	}
+	if !overwritten.CreatedAt.Equal(got.CreatedAt) {
+		t.Fatalf("Expected creation time %v after an overwrite, got %v", got.CreatedAt, overwritten.CreatedAt)
+	}
+	edited = overwritten
+
+	// Using the account only changes its last use time
+	if err = MarkUsed(s, "GitHub"); err != nil {
+		t.Fatalf("Failed to mark account used: %v", err)
+	}
+	used, err := s.Get("GitHub")
+	if err != nil {
+		t.Fatalf("Failed to get account: %v", err)
+	}
+	if used.LastUsedAt.IsZero() || !used.UpdatedAt.Equal(edited.UpdatedAt) {
+		t.Fatalf("Unexpected timestamps after use: %+v", used)
+	}
+}
+
+func TestRenameAccount(t *testing.T) {
+	s := newTestStore(t, "testpassword")
+	for _, name := range []string{"github", "GitLab"} {
+		if err := AddAccount(s, Account{Name: name, Secret: "JBSWY3DPEHPK3PXP", Aliases: []string{name + "-alias"}}); err != nil {
+			t.Fatalf("Failed to add account: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		from    string
+		to      string
+		wantErr error
+	}{
+		{"case only", "github", "GitHub", nil},
+		{"taken name", "GitHub", "gitlab", ErrAccountExists},
+		{"alias of another account", "GitHub", "GitLab-Alias", ErrAccountExists},
+		{"missing account", "Bitbucket", "Codeberg", ErrAccountNotFound},
+		{"by alias", "gitlab-alias", "Codeberg", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.Rename(tt.from, tt.to)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Rename() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			// The new name is shown as given, and the secret is kept
+			acc, err := s.Get(strings.ToLower(tt.to))
+			if err != nil {
+				t.Fatalf("Failed to get renamed account: %v", err)
+			}
+			if acc.Name != tt.to || acc.Secret != "JBSWY3DPEHPK3PXP" {
+				t.Fatalf("Expected account %s with its secret, got %s, %s", tt.to, acc.Name, acc.Secret)
+			}
+		})
+	}
+
+	if err := s.Rename("GitHub", ""); err == nil {
+		t.Fatalf("Expected error when renaming to an empty name")
+	}
+
+	// An account cannot be added under the alias of another either
+	if err := AddAccount(s, Account{Name: "GITHUB-ALIAS", Secret: "JBSWY3DPEHPK3PXP"}); !errors.Is(err, ErrAccountExists) {
+		t.Fatalf("Expected adding an account named after an alias to fail, got %v", err)
+	}
+}
+
+func TestAccountAliases(t *testing.T) {
+	s := newTestStore(t, "testpassword")
+	accounts := []Account{
+		{Name: "GitHub", Secret: "JBSWY3DPEHPK3PXP", Aliases: []string{"gh", "git"}},
+		{Name: "GitLab", Secret: "GEZDGNBVGY3TQOJQ", Aliases: []string{"gl", "git"}},
+		{Name: "gl-old", Secret: "GEZDGNBVGY3TQOJQ"},
+	}
+	for _, acc := range accounts {
+		if err := AddAccount(s, acc); err != nil {
+			t.Fatalf("Failed to add account: %v", err)
+		}
+	}
+
+	tests := []struct {
+		lookup  string
+		want    string
+		wantErr error
+	}{
+		{"GH", "GitHub", nil},
+		{"gl", "GitLab", nil},
+		{"git", "", ErrAmbiguousName},
+		{"gitlab", "GitLab", nil},
+		{"bb", "", ErrAccountNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.lookup, func(t *testing.T) {
+			acc, err := s.Get(tt.lookup)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Get() error = %v, want %v", err, tt.wantErr)
+			}
+			if acc.Name != tt.want {
+				t.Fatalf("Get() = %s, want %s", acc.Name, tt.want)
+			}
+		})
+	}
+
+	// Aliases may not hide an account name
+	for _, alias := range []string{"gl-old", "github", ""} {
+		acc := Account{Name: "Bitbucket", Secret: "JBSWY3DPEHPK3PXP", Aliases: []string{alias}}
+		if err := AddAccount(s, acc); err == nil {
+			t.Errorf("Expected error for alias %q", alias)
+		}
+	}
+
+	// Nor may an account name hide an alias
+	if err := AddAccount(s, Account{Name: "gh", Secret: "GEZDGNBVGY3TQOJQ"}); !errors.Is(err, ErrAccountExists) {
+		t.Fatalf("Expected adding an account named after an alias to fail, got %v", err)
+	}
+	if acc, err := s.Get("gh"); err != nil || acc.Name != "GitHub" {
+		t.Fatalf("Expected alias gh to find GitHub, got %s (%v)", acc.Name, err)
+	}
+	acc, err := s.Get("GitHub")
+	if err != nil {
+		t.Fatalf("Failed to get account: %v", err)
+	}
+	acc.Tags = []string{"work"}
+	if err = s.Put(acc); err != nil {
+		t.Fatalf("Existing aliases should not block updates: %v", err)
+	}
+}
+
+func TestAccountParams(t *testing.T) {
+	s := newTestStore(t, "testpassword")
+	secret := "JBSWY3DPEHPK3PXP"
+
+	// Accounts without stored parameters use the defaults
+	if err := s.Put(Account{Name: "Default", Secret: secret}); err != nil {
+		t.Fatalf("Failed to add account: %v", err)
+	}
+	acc, err := s.Get("Default")
+	if err != nil {
+		t.Fatalf("Failed to get account: %v", err)
+	}
+	if acc.Params() != totp.DefaultParams() {
+		t.Fatalf("Expected default params, got %+v", acc.Params())
+	}
+
+	params := totp.Params{Algorithm: totp.AlgorithmSHA256, Digits: 8, Period: 60}
+	acc = Account{Name: "Corporate", Secret: secret}
+	acc.SetParams(params)
+	if err = s.Put(acc); err != nil {
+		t.Fatalf("Failed to add account with params: %v", err)
+	}
+	acc, err = s.Get("corporate")
+	if err != nil {
+		t.Fatalf("Failed to get account: %v", err)
+	}
+	if acc.Params() != params {
+		t.Fatalf("Expected params %+v, got %+v", params, acc.Params())
+	}
+
+	// Invalid parameters are rejected
+	acc = Account{Name: "Invalid", Secret: secret}
+	acc.SetParams(totp.Params{Digits: 4})
+	if err = s.Put(acc); err == nil {
+		t.Fatalf("Expected error for invalid params")
+	}
+	if err = s.Put(Account{Secret: secret}); err == nil {
+		t.Fatalf("Expected error for an account without a name")
+	}
+}
+
+func TestHOTPCounter(t *testing.T) {
+	s := newTestStore(t, "testpassword")
+	accountName := "VPN"
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" // RFC 4226 test secret
+
+	if err := AddAccount(s, Account{Name: accountName, Secret: secret, Type: totp.TypeHOTP}); err != nil {
+		t.Fatalf("Failed to add HOTP account: %v", err)
+	}
+
+	// Each code advances the stored counter
+	wantCodes := []uint32{755224, 287082, 359152}
+	for i, want := range wantCodes {
+		code, counter, err := NextHOTPCode(s, accountName)
+		if err != nil {
+			t.Fatalf("Failed to generate HOTP code: %v", err)
+		}
+		if code != want || counter != uint64(i) {
+			t.Fatalf("Expected code %d at counter %d, got %d at counter %d", want, i, code, counter)
+		}
+	}
+
+	acc, err := s.Get(accountName)
 	if err != nil {
-		t.Fatalf("Failed to get account secret: %v", err)
+		t.Fatalf("Failed to get account: %v", err)
+	}
+	if acc.Counter != 3 {
+		t.Fatalf("Expected counter 3, got %d", acc.Counter)
+	}
+
+	acc.Counter = 9
+	if err = s.Put(acc); err != nil {
+		t.Fatalf("Failed to set counter: %v", err)
+	}
+	code, _, err := NextHOTPCode(s, accountName)
+	if err != nil {
+		t.Fatalf("Failed to generate HOTP code: %v", err)
+	}
+	if code != 520489 {
+		t.Fatalf("Expected code 520489 after resync, got %d", code)
 	}
 
-	if retrievedSecret != newSecret {
-		t.Fatalf("Expected secret '%s', got '%s'", newSecret, retrievedSecret)
+	// TOTP accounts have no counter
+	if err = AddAccount(s, Account{Name: "TOTP", Secret: secret}); err != nil {
+		t.Fatalf("Failed to add account: %v", err)
+	}
+	if _, _, err = NextHOTPCode(s, "TOTP"); err == nil {
+		t.Fatalf("Expected error when generating HOTP code for a TOTP account")
+	}
+}
+
+func TestVerifyCodeReplayGuard(t *testing.T) {
+	s := newTestStore(t, "testpassword")
+	secret := "JBSWY3DPEHPK3PXP"
+
+	if err := AddAccount(s, Account{Name: "GitHub", Secret: secret}); err != nil {
+		t.Fatalf("Failed to add account: %v", err)
+	}
+
+	info, err := totp.GenerateCode(secret)
+	if err != nil {
+		t.Fatalf("Failed to generate code: %v", err)
+	}
+
+	// Without the guard a code can be verified any number of times
+	for i := 0; i < 2; i++ {
+		if _, err = VerifyCode(s, "GitHub", info.String(), 1, false); err != nil {
+			t.Fatalf("Failed to verify code: %v", err)
+		}
+	}
+
+	match, err := VerifyCode(s, "GitHub", info.String(), 1, true)
+	if err != nil {
+		t.Fatalf("Failed to verify code: %v", err)
+	}
+
+	acc, err := s.Get("GitHub")
+	if err != nil {
+		t.Fatalf("Failed to get account: %v", err)
+	}
+	if acc.LastStep != match.Step {
+		t.Fatalf("Expected last step %d, got %d", match.Step, acc.LastStep)
+	}
+
+	if _, err = VerifyCode(s, "GitHub", info.String(), 1, true); !errors.Is(err, totp.ErrReplayedCode) {
+		t.Fatalf("Expected replayed code error, got %v", err)
+	}
+}
+
+func TestVerifyCodeAfterPeriodChange(t *testing.T) {
+	s := newTestStore(t, "testpassword")
+	secret := "JBSWY3DPEHPK3PXP"
+
+	if err := AddAccount(s, Account{Name: "GitHub", Secret: secret}); err != nil {
+		t.Fatalf("Failed to add account: %v", err)
+	}
+	info, err := totp.GenerateCode(secret)
+	if err != nil {
+		t.Fatalf("Failed to generate code: %v", err)
+	}
+	if _, err = VerifyCode(s, "GitHub", info.String(), 1, true); err != nil {
+		t.Fatalf("Failed to verify code: %v", err)
+	}
+
+	params := totp.Params{Period: 60}
+	err = s.Update(func(tx *Tx) error {
+		acc, err := tx.Get("GitHub")
+		if err != nil {
+			return err
+		}
+		acc.SetParams(params)
+		return tx.Put(acc)
+	})
+	if err != nil {
+		t.Fatalf("Failed to change period: %v", err)
+	}
+
+	// Steps of 60 seconds are about half the stored 30-second step
+	info, err = totp.GenerateCodeWithParams(secret, params.WithDefaults())
+	if err != nil {
+		t.Fatalf("Failed to generate code: %v", err)
+	}
+	if _, err = VerifyCode(s, "GitHub", info.String(), 1, true); err != nil {
+		t.Fatalf("Failed to verify code after the period change: %v", err)
+	}
+}
+
+func TestLegacyVaultUpgrade(t *testing.T) {
+	masterPassword := "testpassword"
+	secret := "JBSWY3DPEHPK3PXP"
+
+	// Write a vault in the format used before the header existed
+	encryptedSecret, err := crypto.EncryptData([]byte(secret), masterPassword)
+	if err != nil {
+		t.Fatalf("Failed to encrypt secret: %v", err)
+	}
+	jsonData, err := json.Marshal([]record{{Account: Account{Name: "GitHub"}, EncryptedSecret: encryptedSecret}})
+	if err != nil {
+		t.Fatalf("Failed to marshal accounts: %v", err)
+	}
+	legacy, err := crypto.EncryptData(jsonData, masterPassword)
+	if err != nil {
+		t.Fatalf("Failed to encrypt vault: %v", err)
+	}
+	backend := &memoryBackend{data: legacy}
+	s := &MemoryStore{vault{backend: backend}}
+
+	// Opening the vault upgrades it to a data key protected by the default key derivation
+	if err = s.Open(masterPassword); err != nil {
+		t.Fatalf("Failed to open legacy vault: %v", err)
+	}
+	acc, err := s.Get("GitHub")
+	if err != nil || acc.Secret != secret {
+		t.Fatalf("Expected secret %s from legacy vault, got %s (%v)", secret, acc.Secret, err)
+	}
+	if _, ok, _ := crypto.ReadVaultKDF(backend.data); !ok {
+		t.Fatalf("Expected vault to be upgraded when opened")
+	}
+	if s.KDF() != crypto.DefaultKDFParams() {
+		t.Fatalf("Expected default KDF after upgrade, got %+v", s.KDF())
+	}
+
+	// Chosen parameters are kept across later saves
+	custom := crypto.KDFParams{Algorithm: crypto.KDFArgon2id, Time: 2, Memory: 8 * 1024, Threads: 1}
+	if err = s.Rekey(masterPassword, custom); err != nil {
+		t.Fatalf("Failed to set KDF: %v", err)
+	}
+	if err = s.Put(Account{Name: "GitLab", Secret: secret}); err != nil {
+		t.Fatalf("Failed to add account: %v", err)
+	}
+	s.Close()
+
+	if err = s.Open(masterPassword); err != nil {
+		t.Fatalf("Failed to reopen vault: %v", err)
+	}
+	if kdf := s.KDF(); kdf != custom {
+		t.Fatalf("Expected KDF %+v, got %+v", custom, kdf)
 	}
 }
@@ -0,0 +1,348 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bykclk/twocli/internal/crypto"
+)
+
+// Store is a collection of accounts protected by a master password. The
+// accounts can only be used between Open, or Create, and Close. Put, Delete,
+// Restore, PurgeTrash, Update and Rekey save the store before returning, and
+// Update saves all of its changes or none of them. Deleted accounts, and
+// accounts whose secret was replaced, are kept in the store's trash until it
+// is purged.
+type Store interface {
+	// Exists reports whether the store has been created.
+	Exists() (bool, error)
+
+	// Create creates an empty store protected by the master password, with its
+	// key derived using the given parameters, and leaves it open.
+	Create(masterPassword string, kdf crypto.KDFParams) error
+
+	// Open unlocks the store with the master password.
+	Open(masterPassword string) error
+
+	// Close locks the store again.
+	Close() error
+
+	// Get returns the named account, matched case-insensitively against the
+	// names of the accounts and then against their aliases.
+	Get(name string) (Account, error)
+
+	// List returns every account in the store, without its secret and notes.
+	List() ([]Account, error)
+
+	// Put stores the account, replacing the account with the same name if there is one.
+	Put(account Account) error
+
+	// Delete moves the named account to the trash.
+	Delete(name string) error
+
+	// Rename changes the name of an account, failing if the new name is taken.
+	Rename(name, newName string) error
+
+	// Trash returns every account version in the trash, most recently
+	// trashed first, without their secret and notes.
+	Trash() ([]TrashedAccount, error)
+
+	// Restore puts a version of the named account back from the trash, 1
+	// being the most recently trashed. A version that cannot be decrypted is
+	// moved to the quarantine, and ErrTrashUnreadable returned.
+	Restore(name string, version int) error
+
+	// PurgeTrash permanently removes the account versions trashed before the
+	// given time, and returns how many were removed. The backups of the store
+	// are removed with them.
+	PurgeTrash(before time.Time) (int, error)
+
+	// Update calls fn with a transaction over the accounts, and saves the
+	// changes made through it if fn returns nil.
+	Update(fn func(tx *Tx) error) error
+
+	// KDF returns the key derivation parameters protecting the store.
+	KDF() crypto.KDFParams
+
+	// Rekey re-encrypts the store and every secret in it under a new data key,
+	// protected by a key derived from the master password with the given parameters.
+	Rekey(masterPassword string, kdf crypto.KDFParams) error
+}
+
+// Tx is a transaction over the accounts of an open store and its trash. It
+// sees the changes made through it, which are only saved once the function
+// passed to Store.Update returns.
+type Tx struct {
+	records    []record
+	trash      []trashRecord
+	quarantine []quarantineRecord
+	key        *crypto.DataKey
+
+	// changed is set when accounts are added, edited or deleted, and used when
+	// only their usage is recorded, which does not rotate the backups. purged
+	// is set when the trash is purged, which removes the backups instead, as
+	// they still hold the purged accounts
+	changed bool
+	used    bool
+	purged  bool
+}
+
+// find returns the index of the named account, matched case-insensitively, or -1.
+func (tx *Tx) find(name string) int {
+	for i, r := range tx.records {
+		if strings.EqualFold(r.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// findAlias returns the index of an account with the alias, matched
+// case-insensitively, or -1.
+func (tx *Tx) findAlias(alias string) int {
+	for i, r := range tx.records {
+		if r.HasAlias(alias) {
+			return i
+		}
+	}
+	return -1
+}
+
+// checkName checks that a name for the account at index i, or for a new
+// account if i is -1, is not the alias of another account, which the name
+// would hide.
+func (tx *Tx) checkName(name string, i int) error {
+	if j := tx.findAlias(name); j >= 0 && j != i {
+		return fmt.Errorf("%w: %s is an alias of %s", ErrAccountExists, name, tx.records[j].Name)
+	}
+	return nil
+}
+
+// resolve returns the index of the account with the name or, failing that,
+// the alias, matched case-insensitively. An alias of several accounts is
+// reported as ambiguous.
+func (tx *Tx) resolve(name string) (int, error) {
+	if i := tx.find(name); i >= 0 {
+		return i, nil
+	}
+
+	found := -1
+	var names []string
+	for i, r := range tx.records {
+		if r.HasAlias(name) {
+			found = i
+			names = append(names, r.Name)
+		}
+	}
+	switch len(names) {
+	case 0:
+		return -1, ErrAccountNotFound
+	case 1:
+		return found, nil
+	default:
+		return -1, fmt.Errorf("%w: %s is an alias of %s", ErrAmbiguousName, name, strings.Join(names, ", "))
+	}
+}
+
+// account decrypts the secret of a stored record into its account.
+func (tx *Tx) account(r record) (Account, error) {
+	secret, err := tx.key.Decrypt(r.EncryptedSecret)
+	if err != nil {
+		return Account{}, err
+	}
+	acc := r.Account
+	acc.Secret = string(secret)
+
+	if r.EncryptedNotes != nil {
+		notes, err := tx.key.Decrypt(r.EncryptedNotes)
+		if err != nil {
+			return Account{}, err
+		}
+		acc.Notes = string(notes)
+	}
+	return acc, nil
+}
+
+// Get returns the named account, matched case-insensitively against the
+// names of the accounts and then against their aliases.
+func (tx *Tx) Get(name string) (Account, error) {
+	i, err := tx.resolve(name)
+	if err != nil {
+		return Account{}, err
+	}
+	return tx.account(tx.records[i])
+}
+
+// List returns every account without its secret and notes, which are only
+// decrypted by Get, so a record that does not decrypt cannot fail the listing.
+func (tx *Tx) List() ([]Account, error) {
+	accounts := make([]Account, len(tx.records))
+	for i, r := range tx.records {
+		accounts[i] = r.Account
+	}
+	return accounts, nil
+}
+
+// Put stores the account, replacing the account with the same name,
+// matched case-insensitively, if there is one. The name cannot be the alias
+// of another account. A replaced account whose
+// secret differs is moved to the trash. Put sets the account's update time,
+// and its creation time if it is new and has none.
+func (tx *Tx) Put(account Account) error {
+	now := time.Now().UTC()
+	i := tx.find(account.Name)
+	if account.CreatedAt.IsZero() {
+		// A replacement without a creation time, such as an imported
+		// entry, keeps the one of the account it replaces
+		account.CreatedAt = now
+		if i >= 0 {
+			account.CreatedAt = tx.records[i].CreatedAt
+		}
+	}
+	account.UpdatedAt = now
+
+	if err := tx.checkName(account.Name, i); err != nil {
+		return err
+	}
+	var current []string
+	if i >= 0 {
+		stored := tx.records[i].Account
+		current = stored.Aliases
+		// The replay guard's step counts periods, so one kept from before a
+		// period change no longer matches the codes' steps
+		if account.Params().Period != stored.Params().Period && account.LastStep == stored.LastStep {
+			account.LastStep = 0
+		}
+	}
+	if err := tx.checkAliases(account, current); err != nil {
+		return err
+	}
+
+	// Keep a copy, as put overwrites the record in place
+	var replaced *record
+	if i >= 0 {
+		current, err := tx.account(tx.records[i])
+		if err != nil {
+			return err
+		}
+		if current.Secret != account.Secret {
+			r := tx.records[i]
+			replaced = &r
+		}
+	}
+
+	if err := tx.put(account); err != nil {
+		return err
+	}
+	if replaced != nil {
+		tx.moveToTrash(*replaced, TrashReplaced, now)
+	}
+	tx.changed = true
+	return nil
+}
+
+// use stores the account with its usage recorded: its last use time, and
+// state such as an HOTP counter that changes when codes are used.
+func (tx *Tx) use(account Account) error {
+	account.LastUsedAt = time.Now().UTC()
+
+	if err := tx.put(account); err != nil {
+		return err
+	}
+	tx.used = true
+	return nil
+}
+
+// put encrypts the account into a record and stores it.
+func (tx *Tx) put(account Account) error {
+	if account.Name == "" {
+		return errors.New("account name is required")
+	}
+	if err := account.Params().Validate(); err != nil {
+		return err
+	}
+
+	encryptedSecret, err := tx.key.Encrypt([]byte(account.Secret))
+	if err != nil {
+		return err
+	}
+	r := record{Account: account, EncryptedSecret: encryptedSecret}
+
+	if account.Notes != "" {
+		if r.EncryptedNotes, err = tx.key.Encrypt([]byte(account.Notes)); err != nil {
+			return err
+		}
+	}
+	r.Secret, r.Notes = "", ""
+
+	if i := tx.find(account.Name); i >= 0 {
+		tx.records[i] = r
+	} else {
+		tx.records = append(tx.records, r)
+	}
+	return nil
+}
+
+// checkAliases checks that no alias being added to the account is empty or
+// the name of an account, which would make it unreachable. Aliases the account
+// already has are not checked again.
+func (tx *Tx) checkAliases(account Account, current []string) error {
+	for _, alias := range account.Aliases {
+		if slices.Contains(current, alias) {
+			continue
+		}
+		if alias == "" {
+			return errors.New("account aliases cannot be empty")
+		}
+		if strings.EqualFold(alias, account.Name) {
+			return fmt.Errorf("alias %s is the name of the account", alias)
+		}
+		if tx.find(alias) >= 0 {
+			return fmt.Errorf("alias %s is the name of another account", alias)
+		}
+	}
+	return nil
+}
+
+// Rename changes the name of an account, which may be given by an alias,
+// failing if another account has the new name, or has it as an alias. The case of a name can be
+// changed by renaming an account to the same name.
+func (tx *Tx) Rename(name, newName string) error {
+	if newName == "" {
+		return errors.New("account name is required")
+	}
+	i, err := tx.resolve(name)
+	if err != nil {
+		return err
+	}
+	if j := tx.find(newName); j >= 0 && j != i {
+		return ErrAccountExists
+	}
+	if err = tx.checkName(newName, i); err != nil {
+		return err
+	}
+
+	r := &tx.records[i]
+	r.Name = newName
+	r.Aliases = slices.DeleteFunc(slices.Clone(r.Aliases), func(alias string) bool {
+		return strings.EqualFold(alias, newName)
+	})
+	r.UpdatedAt = time.Now().UTC()
+	tx.changed = true
+	return nil
+}
+
+// Delete moves the named account to the trash.
+func (tx *Tx) Delete(name string) error {
+	i, err := tx.resolve(name)
+	if err != nil {
+		return err
+	}
+	tx.moveToTrash(tx.records[i], TrashDeleted, time.Now().UTC())
+	tx.records = append(tx.records[:i:i], tx.records[i+1:]...)
+	tx.changed = true
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package storage
+
+import "os"
+
+// syncDir flushes a directory so that a rename in it survives a crash.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Reasons an account version was moved to the trash.
+const (
+	TrashDeleted  = "deleted"
+	TrashReplaced = "replaced"
+)
+
+// ErrTrashNotFound is returned when restoring an account version that is not in the trash.
+var ErrTrashNotFound = errors.New("account version not found in the trash")
+
+// ErrTrashUnreadable is returned when restoring an account version that cannot
+// be decrypted. The version is moved to the quarantine, as Check would.
+var ErrTrashUnreadable = errors.New("account version in the trash cannot be decrypted")
+
+// TrashedAccount is a deleted account, or a version of an account whose secret
+// was replaced, kept in the trash so it can be restored. Listed, it has no
+// secret or notes.
+// Version numbers the trashed versions of each account name from the most
+// recently trashed, which is version 1.
+type TrashedAccount struct {
+	Account
+	Version   int
+	Reason    string
+	TrashedAt time.Time
+}
+
+// trashRecord is a trashed account as stored in the vault, with its secret
+// and notes still encrypted.
+type trashRecord struct {
+	record
+	Reason    string    `json:"reason"`
+	TrashedAt time.Time `json:"trashed_at"`
+}
+
+// moveToTrash adds a record removed from the accounts to the trash.
+func (tx *Tx) moveToTrash(r record, reason string, now time.Time) {
+	tx.trash = append(tx.trash, trashRecord{record: r, Reason: reason, TrashedAt: now})
+}
+
+// findTrash returns the index in the trash of the given version of the named
+// account, counting from the most recently trashed, or -1.
+func (tx *Tx) findTrash(name string, version int) int {
+	n := 0
+	for i := len(tx.trash) - 1; i >= 0; i-- {
+		if strings.EqualFold(tx.trash[i].Name, name) {
+			if n++; n == version {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Trash returns every account version in the trash, most recently trashed
+// first, without their secret and notes.
+func (tx *Tx) Trash() ([]TrashedAccount, error) {
+	versions := map[string]int{}
+	trashed := make([]TrashedAccount, 0, len(tx.trash))
+	for _, t := range slices.Backward(tx.trash) {
+		key := strings.ToLower(t.Name)
+		versions[key]++
+		trashed = append(trashed, TrashedAccount{
+			Account:   t.Account,
+			Version:   versions[key],
+			Reason:    t.Reason,
+			TrashedAt: t.TrashedAt,
+		})
+	}
+	return trashed, nil
+}
+
+// Restore puts a version of the named account back from the trash, 1 being
+// the most recently trashed. An account with the same name is replaced, and
+// moved to the trash in turn if its secret differs. A version that cannot be
+// decrypted is moved to the quarantine instead, and ErrTrashUnreadable is
+// returned.
+func (tx *Tx) Restore(name string, version int) error {
+	i := tx.findTrash(name, version)
+	if i < 0 {
+		return ErrTrashNotFound
+	}
+
+	t := tx.trash[i]
+	tx.trash = append(tx.trash[:i:i], tx.trash[i+1:]...)
+	acc, err := tx.account(t.record)
+	if err != nil {
+		tx.quarantine = append(tx.quarantine, quarantineRecord{
+			record:        t.record,
+			Reason:        "trashed, cannot be decrypted",
+			QuarantinedAt: time.Now().UTC(),
+		})
+		tx.changed = true
+		return fmt.Errorf("%w, and was moved to the quarantine", ErrTrashUnreadable)
+	}
+	return tx.Put(acc)
+}
+
+// PurgeTrash permanently removes the account versions trashed before the
+// given time, and returns how many were removed. Once the transaction is
+// saved, the backups of the vault are removed too.
+func (tx *Tx) PurgeTrash(before time.Time) int {
+	kept := tx.trash[:0:0]
+	for _, t := range tx.trash {
+		if !t.TrashedAt.Before(before) {
+			kept = append(kept, t)
+		}
+	}
+
+	purged := len(tx.trash) - len(kept)
+	if purged > 0 {
+		tx.trash = kept
+		tx.changed = true
+		tx.purged = true
+	}
+	return purged
+}
@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTrash(t *testing.T) {
+	s := newTestStore(t, "testpassword")
+
+	if err := AddAccount(s, Account{Name: "GitHub", Secret: "OLDSECRET", Notes: "old notes"}); err != nil {
+		t.Fatalf("Failed to add account: %v", err)
+	}
+
+	// Editing the account without changing its secret keeps nothing
+	acc, err := s.Get("GitHub")
+	if err != nil {
+		t.Fatalf("Failed to get account: %v", err)
+	}
+	acc.Tags = []string{"work"}
+	if err = s.Put(acc); err != nil {
+		t.Fatalf("Failed to update account: %v", err)
+	}
+	if trash, _ := s.Trash(); len(trash) != 0 {
+		t.Fatalf("Expected an empty trash, got %d accounts", len(trash))
+	}
+
+	// Replacing the secret, then deleting the account, keeps both versions
+	acc.Secret = "NEWSECRET"
+	if err = s.Put(acc); err != nil {
+		t.Fatalf("Failed to update account: %v", err)
+	}
+	if err = s.Delete("GitHub"); err != nil {
+		t.Fatalf("Failed to delete account: %v", err)
+	}
+
+	trash, err := s.Trash()
+	if err != nil {
+		t.Fatalf("Failed to list the trash: %v", err)
+	}
+	want := []struct {
+		reason  string
+		version int
+	}{
+		{TrashDeleted, 1},
+		{TrashReplaced, 2},
+	}
+	if len(trash) != len(want) {
+		t.Fatalf("Expected %d accounts in the trash, got %d", len(want), len(trash))
+	}
+	for i, w := range want {
+		if trash[i].Reason != w.reason || trash[i].Version != w.version {
+			t.Errorf("Trash entry %d = %s, version %d, want %s, version %d",
+				i, trash[i].Reason, trash[i].Version, w.reason, w.version)
+		}
+		if trash[i].Secret != "" || trash[i].Notes != "" || trash[i].TrashedAt.IsZero() {
+			t.Errorf("Trash entry %d has a secret or notes, or lost its time: %+v", i, trash[i])
+		}
+	}
+
+	// Restoring the older version brings back its secret and leaves the other one
+	if err = s.Restore("github", 2); err != nil {
+		t.Fatalf("Failed to restore account: %v", err)
+	}
+	if acc, err = s.Get("GitHub"); err != nil || acc.Secret != "OLDSECRET" || acc.Notes != "old notes" {
+		t.Fatalf("Expected restored secret OLDSECRET and its notes, got %+v (%v)", acc, err)
+	}
+	if err = s.Restore("GitHub", 2); !errors.Is(err, ErrTrashNotFound) {
+		t.Fatalf("Expected trash not found error, got %v", err)
+	}
+
+	// Restoring over the account moves the current version to the trash
+	if err = s.Restore("GitHub", 1); err != nil {
+		t.Fatalf("Failed to restore account: %v", err)
+	}
+	if acc, err = s.Get("GitHub"); err != nil || acc.Secret != "NEWSECRET" {
+		t.Fatalf("Expected restored secret NEWSECRET, got %s (%v)", acc.Secret, err)
+	}
+	trash, err = s.Trash()
+	if err != nil {
+		t.Fatalf("Failed to list the trash: %v", err)
+	}
+	if len(trash) != 1 || trash[0].Reason != TrashReplaced {
+		t.Fatalf("Expected the replaced version in the trash, got %+v", trash)
+	}
+}
+
+func TestRestoreUnreadable(t *testing.T) {
+	s := newTestStore(t, "testpassword")
+
+	for _, name := range []string{"GitHub", "GitLab"} {
+		if err := AddAccount(s, Account{Name: name, Secret: "JBSWY3DPEHPK3PXP"}); err != nil {
+			t.Fatalf("Failed to add account: %v", err)
+		}
+		if err := s.Delete(name); err != nil {
+			t.Fatalf("Failed to delete account: %v", err)
+		}
+	}
+	s.trash[0].EncryptedSecret[len(s.trash[0].EncryptedSecret)-1] ^= 1
+
+	// The damaged version is still listed with the others
+	trash, err := s.Trash()
+	if err != nil || len(trash) != 2 {
+		t.Fatalf("Expected 2 accounts in the trash, got %d (%v)", len(trash), err)
+	}
+
+	// Restoring it moves it to the quarantine, out of the way of the others
+	if err = s.Restore("GitHub", 1); !errors.Is(err, ErrTrashUnreadable) {
+		t.Fatalf("Expected unreadable trash error, got %v", err)
+	}
+	if len(s.quarantine) != 1 || s.quarantine[0].Name != "GitHub" {
+		t.Fatalf("Expected GitHub in the quarantine, got %+v", s.quarantine)
+	}
+	if err = s.Restore("GitLab", 1); err != nil {
+		t.Fatalf("Failed to restore account: %v", err)
+	}
+	if trash, _ = s.Trash(); len(trash) != 0 {
+		t.Fatalf("Expected an empty trash, got %d accounts", len(trash))
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	s := newTestFileStore(t, "testpassword")
+	defer s.Close()
+
+	for _, name := range []string{"GitHub", "GitLab"} {
+		if err := AddAccount(s, Account{Name: name, Secret: "JBSWY3DPEHPK3PXP"}); err != nil {
+			t.Fatalf("Failed to add account: %v", err)
+		}
+		if err := s.Delete(name); err != nil {
+			t.Fatalf("Failed to delete account: %v", err)
+		}
+	}
+
+	// Backdate the first deletion
+	s.trash[0].TrashedAt = time.Now().Add(-48 * time.Hour)
+
+	if purged, err := s.PurgeTrash(time.Now().Add(-24 * time.Hour)); err != nil || purged != 1 {
+		t.Fatalf("PurgeTrash() = %d, %v, want 1", purged, err)
+	}
+
+	// The backups, which still hold the purged account, are removed
+	if backups, err := s.Backups(); err != nil || len(backups) != 0 {
+		t.Fatalf("Expected no backups after purging, got %d (%v)", len(backups), err)
+	}
+
+	// The trash is saved, and survives a password change
+	if err := s.Rekey("newpassword", s.KDF()); err != nil {
+		t.Fatalf("Failed to change password: %v", err)
+	}
+	s.Close()
+	if err := s.Open("newpassword"); err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	trash, err := s.Trash()
+	if err != nil {
+		t.Fatalf("Failed to list the trash: %v", err)
+	}
+	if len(trash) != 1 || trash[0].Name != "GitLab" {
+		t.Fatalf("Expected only GitLab in the trash, got %+v", trash)
+	}
+
+	if purged, err := s.PurgeTrash(time.Now()); err != nil || purged != 1 {
+		t.Fatalf("PurgeTrash() = %d, %v, want 1", purged, err)
+	}
+	if trash, _ = s.Trash(); len(trash) != 0 {
+		t.Fatalf("Expected an empty trash, got %d accounts", len(trash))
+	}
+}
@@ -0,0 +1,404 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/bykclk/twocli/internal/crypto"
+)
+
+var (
+	// ErrIncorrectPassword is returned when the master password does not unlock the vault.
+	ErrIncorrectPassword = errors.New("incorrect master password")
+
+	// ErrVaultNotFound is returned when opening a vault that has not been created.
+	ErrVaultNotFound = errors.New("no vault found, run twocli init to create one")
+
+	// ErrVaultExists is returned when creating a vault that already exists.
+	ErrVaultExists = errors.New("a vault already exists")
+
+	// ErrNotOpen is returned when using a store that has not been opened.
+	ErrNotOpen = errors.New("vault is not open")
+
+	// ErrAccountNotFound is returned when an account does not exist.
+	ErrAccountNotFound = errors.New("account not found")
+
+	// ErrAccountExists is returned when adding an account whose name is taken.
+	ErrAccountExists = errors.New("account with this name already exists")
+
+	// ErrAmbiguousName is returned when a name is not an account's name but
+	// the alias of several accounts.
+	ErrAmbiguousName = errors.New("ambiguous account name")
+
+	errAlreadyOpen = errors.New("vault is already open")
+)
+
+// backend holds the encrypted vault for a store.
+type backend interface {
+	// exists reports whether the vault has been written.
+	exists() (bool, error)
+
+	// read returns the vault, or ErrVaultNotFound if it has not been written.
+	read() ([]byte, error)
+
+	// write replaces the vault, keeping the replaced one as a backup if asked to.
+	write(data []byte, backup bool) error
+
+	// removeBackups deletes every backup of the vault.
+	removeBackups() error
+
+	// lock keeps other users of the vault out until the returned function is called.
+	lock() (func() error, error)
+}
+
+// vault implements Store over the encrypted vault format, kept by a backend.
+// The master password is only needed to open it: the accounts and their
+// secrets are encrypted with the vault's data key. An open vault holds the
+// backend's lock until it is closed.
+type vault struct {
+	backend    backend
+	key        *crypto.VaultKey
+	records    []record
+	trash      []trashRecord
+	quarantine []quarantineRecord
+	unlock     func() error
+}
+
+// Exists reports whether the vault has been created.
+func (v *vault) Exists() (bool, error) {
+	return v.backend.exists()
+}
+
+// Create creates an empty vault protected by the master password, with its
+// key derived using the given parameters, and leaves it open.
+func (v *vault) Create(masterPassword string, kdf crypto.KDFParams) error {
+	return v.lockAnd(func() error {
+		return v.create(masterPassword, kdf)
+	})
+}
+
+// Open locks, reads and unlocks the vault. Vaults written by earlier versions
+// are upgraded to the current format and schema and saved right away.
+func (v *vault) Open(masterPassword string) error {
+	return v.lockAnd(func() error {
+		return v.open(masterPassword)
+	})
+}
+
+// lockAnd takes the backend's lock and calls fn, keeping the lock only if fn succeeds.
+func (v *vault) lockAnd(fn func() error) error {
+	if v.unlock != nil {
+		return errAlreadyOpen
+	}
+
+	unlock, err := v.backend.lock()
+	if err != nil {
+		return err
+	}
+	if err = fn(); err != nil {
+		unlock()
+		return err
+	}
+
+	v.unlock = unlock
+	return nil
+}
+
+func (v *vault) create(masterPassword string, kdf crypto.KDFParams) error {
+	exists, err := v.backend.exists()
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrVaultExists
+	}
+
+	key, err := crypto.NewVaultKey(masterPassword, kdf)
+	if err != nil {
+		return err
+	}
+
+	records := []record{}
+	if err = v.save(key, document{Accounts: records}, true); err != nil {
+		return err
+	}
+	v.key, v.records, v.trash, v.quarantine = key, records, nil, nil
+	return nil
+}
+
+func (v *vault) open(masterPassword string) error {
+	encryptedData, err := v.backend.read()
+	if err != nil {
+		return err
+	}
+
+	jsonData, key, err := crypto.OpenVault(encryptedData, masterPassword)
+	if errors.Is(err, crypto.ErrUnsupportedVault) {
+		return err
+	}
+	if err != nil {
+		return ErrIncorrectPassword
+	}
+
+	doc, migrated, err := decodeVault(jsonData)
+	if err != nil {
+		return err
+	}
+
+	if key.Legacy() {
+		err = v.upgrade(key, doc, masterPassword)
+	} else if migrated {
+		err = v.save(key, doc, true)
+	}
+	if err != nil {
+		return err
+	}
+
+	v.key, v.records, v.trash, v.quarantine = key, doc.Accounts, doc.Trash, doc.Quarantine
+	return nil
+}
+
+// upgrade re-encrypts secrets encrypted under the master password by earlier
+// versions with the data key, and saves the vault in the current format.
+func (v *vault) upgrade(key *crypto.VaultKey, doc document, masterPassword string) error {
+	for i, r := range doc.Accounts {
+		secretData, err := crypto.DecryptData(r.EncryptedSecret, masterPassword)
+		if err != nil {
+			return err
+		}
+		if doc.Accounts[i].EncryptedSecret, err = key.Encrypt(secretData); err != nil {
+			return err
+		}
+	}
+	return v.save(key, doc, true)
+}
+
+// Close releases the backend's lock. The accounts cannot be used until the vault is opened again.
+func (v *vault) Close() error {
+	if v.unlock == nil {
+		return nil
+	}
+
+	err := v.unlock()
+	v.key, v.records, v.trash, v.quarantine, v.unlock = nil, nil, nil, nil, nil
+	return err
+}
+
+// save encrypts the document under the key and writes it to the backend,
+// keeping the replaced vault as a backup if asked to.
+func (v *vault) save(key *crypto.VaultKey, doc document, backup bool) error {
+	// Encode the accounts in the current schema version
+	jsonData, err := encodeVault(doc)
+	if err != nil {
+		return err
+	}
+
+	// Encrypt the data
+	encryptedData, err := key.Seal(jsonData)
+	if err != nil {
+		return err
+	}
+
+	return v.backend.write(encryptedData, backup)
+}
+
+// tx returns a transaction over a copy of the records, the trash and the quarantine.
+func (v *vault) tx() (*Tx, error) {
+	if v.unlock == nil {
+		return nil, ErrNotOpen
+	}
+	return &Tx{
+		records:    slices.Clone(v.records),
+		trash:      slices.Clone(v.trash),
+		quarantine: slices.Clone(v.quarantine),
+		key:        v.key.DataKey,
+	}, nil
+}
+
+// Get returns the named account, matched case-insensitively.
+func (v *vault) Get(name string) (Account, error) {
+	tx, err := v.tx()
+	if err != nil {
+		return Account{}, err
+	}
+	return tx.Get(name)
+}
+
+// List returns every account in the vault, without its secret and notes.
+func (v *vault) List() ([]Account, error) {
+	tx, err := v.tx()
+	if err != nil {
+		return nil, err
+	}
+	return tx.List()
+}
+
+// Put stores the account, replacing the account with the same name if there is one.
+func (v *vault) Put(account Account) error {
+	return v.Update(func(tx *Tx) error {
+		return tx.Put(account)
+	})
+}
+
+// Delete moves the named account to the trash.
+func (v *vault) Delete(name string) error {
+	return v.Update(func(tx *Tx) error {
+		return tx.Delete(name)
+	})
+}
+
+// Rename changes the name of an account, which may be given by an alias.
+func (v *vault) Rename(name, newName string) error {
+	return v.Update(func(tx *Tx) error {
+		return tx.Rename(name, newName)
+	})
+}
+
+// Trash returns every account version in the trash, most recently trashed first.
+func (v *vault) Trash() ([]TrashedAccount, error) {
+	tx, err := v.tx()
+	if err != nil {
+		return nil, err
+	}
+	return tx.Trash()
+}
+
+// Restore puts a version of the named account back from the trash. A version
+// that cannot be decrypted is saved to the quarantine, and the error returned.
+func (v *vault) Restore(name string, version int) error {
+	var unreadable error
+	err := v.Update(func(tx *Tx) error {
+		err := tx.Restore(name, version)
+		if errors.Is(err, ErrTrashUnreadable) {
+			unreadable = err
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return unreadable
+}
+
+// PurgeTrash permanently removes the account versions trashed before the given
+// time, and the backups that still hold them.
+func (v *vault) PurgeTrash(before time.Time) (int, error) {
+	var purged int
+	err := v.Update(func(tx *Tx) error {
+		purged = tx.PurgeTrash(before)
+		return nil
+	})
+	return purged, err
+}
+
+// Update calls fn with a transaction over the accounts and saves the changes
+// made through it if fn returns nil. Nothing is saved if nothing changed, and
+// no backup is kept if only the usage of accounts was recorded. If the trash
+// was purged, the backups are removed instead, so the purged accounts cannot
+// be restored from them.
+func (v *vault) Update(fn func(tx *Tx) error) error {
+	tx, err := v.tx()
+	if err != nil {
+		return err
+	}
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+	if !tx.changed && !tx.used {
+		return nil
+	}
+
+	doc := document{Accounts: tx.records, Trash: tx.trash, Quarantine: tx.quarantine}
+	if err = v.save(v.key, doc, tx.changed && !tx.purged); err != nil {
+		return err
+	}
+	v.records, v.trash, v.quarantine = tx.records, tx.trash, tx.quarantine
+
+	if tx.purged {
+		if err = v.backend.removeBackups(); err != nil {
+			return fmt.Errorf("trash purged, but failed to remove the backups that still hold it: %v", err)
+		}
+	}
+	return nil
+}
+
+// KDF returns the key derivation parameters protecting the vault, or the zero
+// value if it is not open.
+func (v *vault) KDF() crypto.KDFParams {
+	if v.key == nil {
+		return crypto.KDFParams{}
+	}
+	return v.key.KDF()
+}
+
+// Rekey generates a new data key protected by the master password and key
+// derivation parameters, re-encrypts every secret and note under it, including
+// those in the trash and the quarantine, and saves the vault.
+// The vault keeps its old key if it cannot be saved under the new one. The
+// backups are removed once it is saved, as the old password still opens them.
+func (v *vault) Rekey(masterPassword string, kdf crypto.KDFParams) error {
+	if v.unlock == nil {
+		return ErrNotOpen
+	}
+
+	key, err := crypto.NewVaultKey(masterPassword, kdf)
+	if err != nil {
+		return err
+	}
+
+	records := slices.Clone(v.records)
+	for i := range records {
+		if err = reencrypt(&records[i], v.key.DataKey, key.DataKey); err != nil {
+			return fmt.Errorf("account '%s': %w", records[i].Name, err)
+		}
+	}
+	trash := slices.Clone(v.trash)
+	for i := range trash {
+		if err = reencrypt(&trash[i].record, v.key.DataKey, key.DataKey); err != nil {
+			return fmt.Errorf("trashed account '%s': %w", trash[i].Name, err)
+		}
+	}
+	// Quarantined records whose secret or notes do not decrypt are kept as they are
+	quarantine := slices.Clone(v.quarantine)
+	for i := range quarantine {
+		r := quarantine[i].record
+		if reencrypt(&r, v.key.DataKey, key.DataKey) == nil {
+			quarantine[i].record = r
+		}
+	}
+
+	if err = v.save(key, document{Accounts: records, Trash: trash, Quarantine: quarantine}, false); err != nil {
+		return err
+	}
+	v.key, v.records, v.trash, v.quarantine = key, records, trash, quarantine
+
+	if err = v.backend.removeBackups(); err != nil {
+		return fmt.Errorf("master password changed, but failed to remove the backups the old one opens: %v", err)
+	}
+	return nil
+}
+
+// reencrypt re-encrypts the secret and notes of a record from one data key to another.
+func reencrypt(r *record, from, to *crypto.DataKey) error {
+	secretData, err := from.Decrypt(r.EncryptedSecret)
+	if err != nil {
+		return err
+	}
+	if r.EncryptedSecret, err = to.Encrypt(secretData); err != nil {
+		return err
+	}
+
+	if r.EncryptedNotes == nil {
+		return nil
+	}
+	notes, err := from.Decrypt(r.EncryptedNotes)
+	if err != nil {
+		return err
+	}
+	r.EncryptedNotes, err = to.Encrypt(notes)
+	return err
+}
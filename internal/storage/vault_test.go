@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bykclk/twocli/internal/crypto"
+)
+
+func TestStore(t *testing.T) {
+	stores := map[string]func(t *testing.T) Store{
+		"file": func(t *testing.T) Store {
+			return NewFileStore(filepath.Join(t.TempDir(), "vault"))
+		},
+		"memory": func(t *testing.T) Store {
+			return NewMemoryStore()
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			masterPassword := "testpassword"
+			s := newStore(t)
+
+			if err := s.Open(masterPassword); !errors.Is(err, ErrVaultNotFound) {
+				t.Fatalf("Expected vault not found error, got %v", err)
+			}
+			if _, err := s.List(); !errors.Is(err, ErrNotOpen) {
+				t.Fatalf("Expected not open error, got %v", err)
+			}
+
+			if err := s.Create(masterPassword, testKDF); err != nil {
+				t.Fatalf("Failed to create store: %v", err)
+			}
+			for i := 0; i < 3; i++ {
+				err := s.Put(Account{Name: fmt.Sprintf("Account%d", i), Secret: fmt.Sprintf("SECRET%d", i)})
+				if err != nil {
+					t.Fatalf("Failed to add account: %v", err)
+				}
+			}
+			if err := s.Delete("Account1"); err != nil {
+				t.Fatalf("Failed to delete account: %v", err)
+			}
+			if err := s.Close(); err != nil {
+				t.Fatalf("Failed to close store: %v", err)
+			}
+
+			if exists, err := s.Exists(); !exists || err != nil {
+				t.Fatalf("Exists() = %v, %v, want true", exists, err)
+			}
+			if err := s.Create(masterPassword, testKDF); !errors.Is(err, ErrVaultExists) {
+				t.Fatalf("Expected vault exists error, got %v", err)
+			}
+			if err := s.Open("wrongpassword"); !errors.Is(err, ErrIncorrectPassword) {
+				t.Fatalf("Expected incorrect password error, got %v", err)
+			}
+
+			// Every change is saved, and secrets decrypt after reopening
+			if err := s.Open(masterPassword); err != nil {
+				t.Fatalf("Failed to reopen store: %v", err)
+			}
+			defer s.Close()
+
+			accounts, err := s.List()
+			if err != nil {
+				t.Fatalf("Failed to list accounts: %v", err)
+			}
+			if len(accounts) != 2 {
+				t.Fatalf("Expected 2 accounts, got %d", len(accounts))
+			}
+			for _, acc := range accounts {
+				if acc, err = s.Get(acc.Name); err != nil {
+					t.Fatalf("Failed to get account: %v", err)
+				}
+				if want := "SECRET" + acc.Name[len(acc.Name)-1:]; acc.Secret != want {
+					t.Fatalf("Expected secret %s, got %s", want, acc.Secret)
+				}
+			}
+			if _, err = s.Get("Account1"); !errors.Is(err, ErrAccountNotFound) {
+				t.Fatalf("Expected error when getting deleted account")
+			}
+		})
+	}
+}
+
+// benchmarkStore creates a file store with the given number of accounts,
+// protected by the default key derivation cost.
+func benchmarkStore(b *testing.B, accounts int) *FileStore {
+	b.Helper()
+
+	s := newTestFileStore(b, "benchmarkpassword")
+	defer s.Close()
+
+	err := s.Update(func(tx *Tx) error {
+		for i := 0; i < accounts; i++ {
+			if err := tx.Put(Account{Name: fmt.Sprintf("Account%d", i), Secret: "JBSWY3DPEHPK3PXP"}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Fatalf("Failed to add accounts: %v", err)
+	}
+	if err = s.Rekey("benchmarkpassword", crypto.DefaultKDFParams()); err != nil {
+		b.Fatalf("Failed to save store: %v", err)
+	}
+	return s
+}
+
+var benchmarkSizes = []int{10, 100, 1000}
+
+// BenchmarkOpenVault unlocks the vault and decrypts every secret, as exporting
+// all accounts does.
+func BenchmarkOpenVault(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("accounts=%d", size), func(b *testing.B) {
+			s := benchmarkStore(b, size)
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if err := s.Open("benchmarkpassword"); err != nil {
+					b.Fatalf("Failed to open store: %v", err)
+				}
+				accounts, err := s.List()
+				if err != nil {
+					b.Fatalf("Failed to list accounts: %v", err)
+				}
+				for _, acc := range accounts {
+					if _, err = s.Get(acc.Name); err != nil {
+						b.Fatalf("Failed to get account: %v", err)
+					}
+				}
+				s.Close()
+			}
+		})
+	}
+}
+
+// BenchmarkAddAccount adds an account to an open vault, which encrypts
+// one secret and saves the whole vault.
+func BenchmarkAddAccount(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("accounts=%d", size), func(b *testing.B) {
+			s := benchmarkStore(b, size)
+			if err := s.Open("benchmarkpassword"); err != nil {
+				b.Fatalf("Failed to open store: %v", err)
+			}
+			defer s.Close()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if err := s.Put(Account{Name: "Benchmark", Secret: "JBSWY3DPEHPK3PXP"}); err != nil {
+					b.Fatalf("Failed to add account: %v", err)
+				}
+				b.StopTimer()
+				s.records = s.records[:size]
+				b.StartTimer()
+			}
+		})
+	}
+}
+
+func TestRekey(t *testing.T) {
+	s := newTestFileStore(t, "oldpassword")
+	defer s.Close()
+
+	if err := s.Put(Account{Name: "GitHub", Secret: "JBSWY3DPEHPK3PXP", Notes: "Recovery codes in the safe"}); err != nil {
+		t.Fatalf("Failed to add account: %v", err)
+	}
+	oldData, err := os.ReadFile(s.Path())
+	if err != nil {
+		t.Fatalf("Failed to read vault: %v", err)
+	}
+
+	if err = s.Rekey("newpassword", s.KDF()); err != nil {
+		t.Fatalf("Failed to change password: %v", err)
+	}
+
+	// The open store keeps working with the new key
+	if err = s.Put(Account{Name: "GitLab", Secret: "GEZDGNBVGY3TQOJQ"}); err != nil {
+		t.Fatalf("Failed to add account after changing password: %v", err)
+	}
+	s.Close()
+
+	if err = s.Open("oldpassword"); !errors.Is(err, ErrIncorrectPassword) {
+		t.Fatalf("Expected old password to be rejected, got %v", err)
+	}
+	if err = s.Open("newpassword"); err != nil {
+		t.Fatalf("Failed to open store with new password: %v", err)
+	}
+	acc, err := s.Get("GitHub")
+	if err != nil || acc.Secret != "JBSWY3DPEHPK3PXP" || acc.Notes != "Recovery codes in the safe" {
+		t.Fatalf("Expected secret and notes to survive password change, got %s, %q (%v)", acc.Secret, acc.Notes, err)
+	}
+	if s.KDF() != testKDF {
+		t.Fatalf("Expected KDF %+v to be kept, got %+v", testKDF, s.KDF())
+	}
+
+	// A copy of the vault taken before the change still needs the old password
+	if _, _, err = crypto.OpenVault(oldData, "newpassword"); err == nil {
+		t.Fatalf("Expected old vault contents not to open with the new password")
+	}
+
+	// No backup is left that the old password opens
+	backups, err := s.Backups()
+	if err != nil {
+		t.Fatalf("Failed to list backups: %v", err)
+	}
+	for _, backup := range backups {
+		if err = NewFileStore(backup.Path).Open("oldpassword"); err == nil {
+			t.Fatalf("Expected %s not to open with the old password", filepath.Base(backup.Path))
+		}
+	}
+}
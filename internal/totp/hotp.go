@@ -0,0 +1,82 @@
+package totp
+
+import (
+	"encoding/base32"
+	"errors"
+	"fmt"
+)
+
+// Account types
+const (
+	TypeTOTP = "totp"
+	TypeHOTP = "hotp"
+)
+
+// DefaultResyncWindow is the number of counter values searched by Resync.
+const DefaultResyncWindow = 100
+
+// GenerateHOTP generates an RFC 4226 HOTP code for the given counter.
+// The period in params is ignored.
+func GenerateHOTP(secret string, counter uint64, params Params) (uint32, error) {
+	key, params, err := prepare(secret, params)
+	if err != nil {
+		return 0, err
+	}
+
+	return computeCode(key, counter, params)
+}
+
+// Resync searches the look-ahead window starting at counter for two
+// consecutive codes produced by the real token and returns the counter
+// value to use for the next code. A zero window means DefaultResyncWindow.
+func Resync(secret, code1, code2 string, counter uint64, window int, params Params) (uint64, error) {
+	if window < 0 {
+		return 0, errors.New("resync window cannot be negative")
+	}
+	if window == 0 {
+		window = DefaultResyncWindow
+	}
+
+	key, params, err := prepare(secret, params)
+	if err != nil {
+		return 0, err
+	}
+
+	previous, err := computeCode(key, counter, params)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := uint64(0); i < uint64(window); i++ {
+		next, err := computeCode(key, counter+i+1, params)
+		if err != nil {
+			return 0, err
+		}
+
+		if FormatCode(previous, params.Digits) == code1 && FormatCode(next, params.Digits) == code2 {
+			return counter + i + 2, nil
+		}
+		previous = next
+	}
+
+	return 0, errors.New("codes not found within the resync window")
+}
+
+// prepare validates the secret and parameters and decodes the secret key.
+func prepare(secret string, params Params) ([]byte, Params, error) {
+	params = params.WithDefaults()
+	if err := params.Validate(); err != nil {
+		return nil, Params{}, err
+	}
+
+	if err := ValidateSecret(secret); err != nil {
+		return nil, Params{}, fmt.Errorf("invalid secret key: %v", err)
+	}
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(normalizeSecret(secret))
+	if err != nil {
+		return nil, Params{}, fmt.Errorf("failed to decode secret key: %v", err)
+	}
+
+	return key, params, nil
+}
@@ -0,0 +1,59 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+)
+
+// RFC 4226 Appendix D test values
+var (
+	rfc4226Secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	rfc4226Codes  = []string{
+		"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489",
+	}
+)
+
+func TestGenerateHOTP(t *testing.T) {
+	for counter, want := range rfc4226Codes {
+		code, err := GenerateHOTP(rfc4226Secret, uint64(counter), DefaultParams())
+		if err != nil {
+			t.Fatalf("GenerateHOTP() unexpected error = %v", err)
+		}
+		if got := FormatCode(code, DefaultDigits); got != want {
+			t.Errorf("GenerateHOTP() counter %d = %s, want %s", counter, got, want)
+		}
+	}
+}
+
+func TestResync(t *testing.T) {
+	tests := []struct {
+		name    string
+		code1   string
+		code2   string
+		counter uint64
+		window  int
+		want    uint64
+		wantErr bool
+	}{
+		{"At counter", rfc4226Codes[0], rfc4226Codes[1], 0, 10, 2, false},
+		{"Ahead of counter", rfc4226Codes[5], rfc4226Codes[6], 1, 10, 7, false},
+		{"Outside window", rfc4226Codes[8], rfc4226Codes[9], 0, 3, 0, true},
+		{"Not consecutive", rfc4226Codes[2], rfc4226Codes[4], 0, 10, 0, true},
+		{"Behind counter", rfc4226Codes[0], rfc4226Codes[1], 4, 10, 0, true},
+		{"Default window", rfc4226Codes[5], rfc4226Codes[6], 0, 0, 7, false},
+		{"Negative window", rfc4226Codes[0], rfc4226Codes[1], 0, -1, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Resync(rfc4226Secret, tt.code1, tt.code2, tt.counter, tt.window, DefaultParams())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Resync() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("Resync() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package totp
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Key is an account in the Key Uri Format used by otpauth:// URIs.
+// See https://github.com/google/google-authenticator/wiki/Key-Uri-Format.
+type Key struct {
+	Type        string
+	Issuer      string
+	AccountName string
+	Secret      string
+	Params      Params
+	Counter     uint64
+}
+
+// ParseURI parses an otpauth://totp/ or otpauth://hotp/ URI.
+// The issuer parameter takes precedence over an issuer prefix in the label.
+func ParseURI(uri string) (*Key, error) {
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil {
+		return nil, fmt.Errorf("invalid otpauth URI: %v", err)
+	}
+
+	if !strings.EqualFold(u.Scheme, "otpauth") {
+		return nil, errors.New("invalid otpauth URI: scheme must be otpauth")
+	}
+
+	key := &Key{Type: strings.ToLower(u.Host)}
+	if key.Type != TypeTOTP && key.Type != TypeHOTP {
+		return nil, fmt.Errorf("invalid otpauth URI: unsupported type %q", u.Host)
+	}
+
+	// Decode the label ourselves so an escaped colon is treated like a literal one
+	label, err := url.PathUnescape(strings.TrimPrefix(u.EscapedPath(), "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid otpauth URI label: %v", err)
+	}
+
+	query := u.Query()
+	issuer := strings.TrimSpace(query.Get("issuer"))
+	switch {
+	case issuer != "" && strings.HasPrefix(label, issuer+":"):
+		// The issuer itself may contain a colon
+		key.AccountName = strings.TrimSpace(strings.TrimPrefix(label, issuer+":"))
+	case strings.Contains(label, ":"):
+		prefix, account, _ := strings.Cut(label, ":")
+		key.Issuer = strings.TrimSpace(prefix)
+		key.AccountName = strings.TrimSpace(account)
+	default:
+		key.AccountName = strings.TrimSpace(label)
+	}
+	if issuer != "" {
+		key.Issuer = issuer
+	}
+
+	key.Secret = strings.TrimRight(normalizeSecret(query.Get("secret")), "=")
+	if err = ValidateSecret(key.Secret); err != nil {
+		return nil, fmt.Errorf("invalid otpauth URI secret: %v", err)
+	}
+
+	if algorithm := query.Get("algorithm"); algorithm != "" {
+		key.Params.Algorithm = NormalizeAlgorithm(algorithm)
+	}
+	if key.Params.Digits, err = parseIntParam(query, "digits"); err != nil {
+		return nil, err
+	}
+	if key.Params.Period, err = parseIntParam(query, "period"); err != nil {
+		return nil, err
+	}
+	key.Params = key.Params.WithDefaults()
+	if err = key.Params.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid otpauth URI: %v", err)
+	}
+
+	if key.Type == TypeHOTP {
+		counter := query.Get("counter")
+		if counter == "" {
+			return nil, errors.New("invalid otpauth URI: hotp requires a counter")
+		}
+		if key.Counter, err = strconv.ParseUint(counter, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid otpauth URI counter: %v", err)
+		}
+	}
+
+	return key, nil
+}
+
+func parseIntParam(query url.Values, name string) (int, error) {
+	value := query.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid otpauth URI %s: %v", name, err)
+	}
+	return n, nil
+}
+
+// Label returns the URI label: the account name prefixed by the issuer, if any.
+func (k *Key) Label() string {
+	if k.Issuer == "" {
+		return k.AccountName
+	}
+	return k.Issuer + ":" + k.AccountName
+}
+
+// URI serializes the key as an otpauth:// URI.
+func (k *Key) URI() string {
+	otpType := k.Type
+	if otpType == "" {
+		otpType = TypeTOTP
+	}
+	params := k.Params.WithDefaults()
+
+	var b strings.Builder
+	b.WriteString("otpauth://")
+	b.WriteString(otpType)
+	b.WriteString("/")
+	if k.Issuer != "" {
+		b.WriteString(escapeLabel(k.Issuer))
+		b.WriteString(":")
+	}
+	b.WriteString(escapeLabel(k.AccountName))
+
+	b.WriteString("?secret=")
+	b.WriteString(escapeQuery(strings.TrimRight(normalizeSecret(k.Secret), "=")))
+	if k.Issuer != "" {
+		b.WriteString("&issuer=")
+		b.WriteString(escapeQuery(k.Issuer))
+	}
+	b.WriteString("&algorithm=")
+	b.WriteString(escapeQuery(params.Algorithm))
+	b.WriteString("&digits=")
+	b.WriteString(strconv.Itoa(params.Digits))
+	if otpType == TypeHOTP {
+		b.WriteString("&counter=")
+		b.WriteString(strconv.FormatUint(k.Counter, 10))
+	} else {
+		b.WriteString("&period=")
+		b.WriteString(strconv.Itoa(params.Period))
+	}
+
+	return b.String()
+}
+
+// escapeLabel escapes a label part, including the colon that separates issuer and account.
+func escapeLabel(s string) string {
+	return strings.ReplaceAll(url.PathEscape(s), ":", "%3A")
+}
+
+// escapeQuery escapes a query value with %20 for spaces, as the Key Uri Format recommends.
+func escapeQuery(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
@@ -0,0 +1,170 @@
+package totp
+
+import (
+	"testing"
+)
+
+func TestParseURI(t *testing.T) {
+	tests := []struct {
+		name    string
+		uri     string
+		want    Key
+		wantErr bool
+	}{
+		{
+			name: "Key Uri Format basic example",
+			uri:  "otpauth://totp/Example:alice@google.com?secret=JBSWY3DPEHPK3PXP&issuer=Example",
+			want: Key{
+				Type:        TypeTOTP,
+				Issuer:      "Example",
+				AccountName: "alice@google.com",
+				Secret:      "JBSWY3DPEHPK3PXP",
+				Params:      DefaultParams(),
+			},
+		},
+		{
+			name: "Key Uri Format full example",
+			uri:  "otpauth://totp/ACME%20Co:john.doe@email.com?secret=HXDMVJECJJWSRB3HWIZR4IFUGFTMXBOZ&issuer=ACME%20Co&algorithm=SHA1&digits=6&period=30",
+			want: Key{
+				Type:        TypeTOTP,
+				Issuer:      "ACME Co",
+				AccountName: "john.doe@email.com",
+				Secret:      "HXDMVJECJJWSRB3HWIZR4IFUGFTMXBOZ",
+				Params:      DefaultParams(),
+			},
+		},
+		{
+			name: "Encoded colon and space after it",
+			uri:  "otpauth://totp/Big%20Corporation%3A%20alice@bigco.com?secret=JBSWY3DPEHPK3PXP",
+			want: Key{
+				Type:        TypeTOTP,
+				Issuer:      "Big Corporation",
+				AccountName: "alice@bigco.com",
+				Secret:      "JBSWY3DPEHPK3PXP",
+				Params:      DefaultParams(),
+			},
+		},
+		{
+			name: "Issuer parameter takes precedence over label prefix",
+			uri:  "otpauth://totp/Old:alice?secret=JBSWY3DPEHPK3PXP&issuer=New",
+			want: Key{
+				Type:        TypeTOTP,
+				Issuer:      "New",
+				AccountName: "alice",
+				Secret:      "JBSWY3DPEHPK3PXP",
+				Params:      DefaultParams(),
+			},
+		},
+		{
+			name: "Label without issuer",
+			uri:  "otpauth://totp/alice@google.com?secret=jbswy3dpehpk3pxp",
+			want: Key{
+				Type:        TypeTOTP,
+				AccountName: "alice@google.com",
+				Secret:      "JBSWY3DPEHPK3PXP",
+				Params:      DefaultParams(),
+			},
+		},
+		{
+			name: "Non-default parameters",
+			uri:  "otpauth://totp/Corp:bob?secret=JBSWY3DPEHPK3PXP&algorithm=sha256&digits=8&period=60",
+			want: Key{
+				Type:        TypeTOTP,
+				Issuer:      "Corp",
+				AccountName: "bob",
+				Secret:      "JBSWY3DPEHPK3PXP",
+				Params:      Params{Algorithm: AlgorithmSHA256, Digits: 8, Period: 60},
+			},
+		},
+		{
+			name: "HOTP with counter",
+			uri:  "otpauth://hotp/VPN:carol?secret=JBSWY3DPEHPK3PXP&counter=42",
+			want: Key{
+				Type:        TypeHOTP,
+				Issuer:      "VPN",
+				AccountName: "carol",
+				Secret:      "JBSWY3DPEHPK3PXP",
+				Params:      DefaultParams(),
+				Counter:     42,
+			},
+		},
+		{name: "HOTP without counter", uri: "otpauth://hotp/VPN:carol?secret=JBSWY3DPEHPK3PXP", wantErr: true},
+		{name: "Wrong scheme", uri: "https://totp/Example:alice?secret=JBSWY3DPEHPK3PXP", wantErr: true},
+		{name: "Unknown type", uri: "otpauth://motp/Example:alice?secret=JBSWY3DPEHPK3PXP", wantErr: true},
+		{name: "Missing secret", uri: "otpauth://totp/Example:alice?issuer=Example", wantErr: true},
+		{name: "Invalid digits", uri: "otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&digits=4", wantErr: true},
+		{name: "Unsupported algorithm", uri: "otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&algorithm=MD5", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseURI(tt.uri)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseURI() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && *got != tt.want {
+				t.Errorf("ParseURI() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyURI(t *testing.T) {
+	tests := []struct {
+		name string
+		key  Key
+		want string
+	}{
+		{
+			name: "Key Uri Format full example",
+			key: Key{
+				Type:        TypeTOTP,
+				Issuer:      "ACME Co",
+				AccountName: "john.doe@email.com",
+				Secret:      "HXDMVJECJJWSRB3HWIZR4IFUGFTMXBOZ",
+			},
+			want: "otpauth://totp/ACME%20Co:john.doe@email.com?secret=HXDMVJECJJWSRB3HWIZR4IFUGFTMXBOZ&issuer=ACME%20Co&algorithm=SHA1&digits=6&period=30",
+		},
+		{
+			name: "Without issuer",
+			key: Key{
+				Type:        TypeTOTP,
+				AccountName: "alice@google.com",
+				Secret:      "JBSWY3DPEHPK3PXP",
+				Params:      Params{Algorithm: AlgorithmSHA512, Digits: 8, Period: 60},
+			},
+			want: "otpauth://totp/alice@google.com?secret=JBSWY3DPEHPK3PXP&algorithm=SHA512&digits=8&period=60",
+		},
+		{
+			name: "Colons and ampersands are escaped",
+			key: Key{
+				Type:        TypeHOTP,
+				Issuer:      "A&B: Co",
+				AccountName: "x:y",
+				Secret:      "JBSWY3DPEHPK3PXP",
+				Counter:     7,
+			},
+			want: "otpauth://hotp/A&B%3A%20Co:x%3Ay?secret=JBSWY3DPEHPK3PXP&issuer=A%26B%3A%20Co&algorithm=SHA1&digits=6&counter=7",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.key.URI()
+			if got != tt.want {
+				t.Fatalf("URI() = %s, want %s", got, tt.want)
+			}
+
+			// The serialized URI must parse back to the same key
+			parsed, err := ParseURI(got)
+			if err != nil {
+				t.Fatalf("ParseURI() unexpected error = %v", err)
+			}
+			want := tt.key
+			want.Params = want.Params.WithDefaults()
+			if *parsed != want {
+				t.Errorf("ParseURI(URI()) = %+v, want %+v", *parsed, want)
+			}
+		})
+	}
+}
@@ -3,10 +3,13 @@ package totp
 import (
 	"crypto/hmac"
 	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/base32"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash"
 	"strings"
 	"time"
 )
@@ -14,9 +17,90 @@ import (
 // timeNow is a variable to allow overriding in tests.
 var timeNow = time.Now
 
+// Supported HMAC algorithms
+const (
+	AlgorithmSHA1   = "SHA1"
+	AlgorithmSHA256 = "SHA256"
+	AlgorithmSHA512 = "SHA512"
+)
+
+// Default and allowed code parameters
+const (
+	DefaultDigits = 6
+	DefaultPeriod = 30
+	MinDigits     = 6
+	MaxDigits     = 10
+)
+
+// Params holds the parameters used to generate a code.
+type Params struct {
+	Algorithm string
+	Digits    int
+	Period    int
+}
+
+// DefaultParams returns the RFC 6238 defaults: HMAC-SHA1, 6 digits and a 30-second step.
+func DefaultParams() Params {
+	return Params{
+		Algorithm: AlgorithmSHA1,
+		Digits:    DefaultDigits,
+		Period:    DefaultPeriod,
+	}
+}
+
+// WithDefaults returns a copy of p with zero fields replaced by their defaults.
+func (p Params) WithDefaults() Params {
+	if p.Algorithm == "" {
+		p.Algorithm = AlgorithmSHA1
+	}
+	if p.Digits == 0 {
+		p.Digits = DefaultDigits
+	}
+	if p.Period == 0 {
+		p.Period = DefaultPeriod
+	}
+	return p
+}
+
+// Validate checks that the parameters are supported.
+func (p Params) Validate() error {
+	if _, err := hashFunc(p.Algorithm); err != nil {
+		return err
+	}
+	if p.Digits < MinDigits || p.Digits > MaxDigits {
+		return fmt.Errorf("digits must be between %d and %d", MinDigits, MaxDigits)
+	}
+	if p.Period <= 0 {
+		return errors.New("period must be a positive number of seconds")
+	}
+	return nil
+}
+
+// NormalizeAlgorithm converts user input such as "sha256" or "SHA-256" to the canonical algorithm name.
+func NormalizeAlgorithm(algorithm string) string {
+	return strings.ReplaceAll(strings.ToUpper(strings.TrimSpace(algorithm)), "-", "")
+}
+
+func hashFunc(algorithm string) (func() hash.Hash, error) {
+	switch algorithm {
+	case AlgorithmSHA1:
+		return sha1.New, nil
+	case AlgorithmSHA256:
+		return sha256.New, nil
+	case AlgorithmSHA512:
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("unsupported algorithm: %s", algorithm)
+	}
+}
+
+func normalizeSecret(secret string) string {
+	return strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+}
+
 func ValidateSecret(secret string) error {
 	// Remove any whitespace and convert to uppercase
-	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	secret = normalizeSecret(secret)
 
 	// Check for empty secret
 	if secret == "" {
@@ -34,45 +118,78 @@ func ValidateSecret(secret string) error {
 // TOTPInfo contains the generated code and its validity information
 type TOTPInfo struct {
 	Code             uint32
+	Digits           int
+	Period           int64
 	RemainingSeconds int64
 }
 
-// GenerateCode generates a TOTP code and returns it along with remaining validity time
+// String returns the code zero-padded to its number of digits.
+func (i TOTPInfo) String() string {
+	return FormatCode(i.Code, i.Digits)
+}
+
+// FormatCode zero-pads a code to the given number of digits.
+func FormatCode(code uint32, digits int) string {
+	return fmt.Sprintf("%0*d", digits, code)
+}
+
+// GenerateCode generates a TOTP code with the default parameters and returns it along with remaining validity time
 func GenerateCode(secret string) (TOTPInfo, error) {
-	// Validate secret first
-	if err := ValidateSecret(secret); err != nil {
-		return TOTPInfo{}, fmt.Errorf("invalid secret key: %v", err)
-	}
+	return GenerateCodeWithParams(secret, DefaultParams())
+}
 
-	// Decode the base32 encoded secret key
-	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(secret))
+// GenerateCodeWithParams generates a TOTP code using the given algorithm, digits and period.
+func GenerateCodeWithParams(secret string, params Params) (TOTPInfo, error) {
+	// Validate the secret and decode the base32 encoded secret key
+	key, params, err := prepare(secret, params)
 	if err != nil {
-		return TOTPInfo{}, fmt.Errorf("failed to decode secret key: %v", err)
+		return TOTPInfo{}, err
 	}
 
 	// Calculate the time step and remaining seconds
+	period := int64(params.Period)
 	epochSeconds := timeNow().Unix()
-	timeStep := uint64(epochSeconds / 30)
-	remainingSeconds := 30 - (epochSeconds % 30)
+	timeStep := uint64(epochSeconds / period)
+	remainingSeconds := period - (epochSeconds % period)
+
+	code, err := computeCode(key, timeStep, params)
+	if err != nil {
+		return TOTPInfo{}, err
+	}
 
-	// Convert time step to byte array
+	return TOTPInfo{
+		Code:             code,
+		Digits:           params.Digits,
+		Period:           period,
+		RemainingSeconds: remainingSeconds,
+	}, nil
+}
+
+// computeCode performs the RFC 4226 HMAC and dynamic truncation for the given moving factor.
+func computeCode(key []byte, movingFactor uint64, params Params) (uint32, error) {
+	newHash, err := hashFunc(params.Algorithm)
+	if err != nil {
+		return 0, err
+	}
+
+	// Convert moving factor to byte array
 	msg := make([]byte, 8)
-	binary.BigEndian.PutUint64(msg, timeStep)
+	binary.BigEndian.PutUint64(msg, movingFactor)
 
-	// Calculate HMAC-SHA1
-	h := hmac.New(sha1.New, key)
+	// Calculate HMAC
+	h := hmac.New(newHash, key)
 	h.Write(msg)
 	hash := h.Sum(nil)
 
 	// Get offset
 	offset := hash[len(hash)-1] & 0xf
 
-	// Generate 4-byte code
-	binary := binary.BigEndian.Uint32(hash[offset:]) & 0x7fffffff
-	code := binary % 1000000
+	// Generate code with the requested number of digits
+	binary := uint64(binary.BigEndian.Uint32(hash[offset:]) & 0x7fffffff)
+	modulus := uint64(1)
+	for i := 0; i < params.Digits; i++ {
+		modulus *= 10
+	}
 
-	return TOTPInfo{
-		Code:             code,
-		RemainingSeconds: remainingSeconds,
-	}, nil
+	return uint32(binary % modulus), nil
 }
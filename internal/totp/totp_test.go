@@ -1,6 +1,8 @@
 package totp
 
 import (
+	"encoding/base32"
+	"fmt"
 	"testing"
 	"time"
 )
@@ -158,3 +160,95 @@ func TestRemainingTimeCalculation(t *testing.T) {
 		})
 	}
 }
+
+func TestGenerateCodeWithParamsRFC6238(t *testing.T) {
+	originalTimeNow := timeNow
+	defer func() { timeNow = originalTimeNow }()
+
+	enc := base32.StdEncoding.WithPadding(base32.NoPadding)
+	secrets := map[string]string{
+		AlgorithmSHA1:   enc.EncodeToString([]byte("12345678901234567890")),
+		AlgorithmSHA256: enc.EncodeToString([]byte("12345678901234567890123456789012")),
+		AlgorithmSHA512: enc.EncodeToString([]byte("1234567890123456789012345678901234567890123456789012345678901234")),
+	}
+
+	// Test vectors from RFC 6238 Appendix B
+	tests := []struct {
+		unix      int64
+		algorithm string
+		want      string
+	}{
+		{59, AlgorithmSHA1, "94287082"},
+		{59, AlgorithmSHA256, "46119246"},
+		{59, AlgorithmSHA512, "90693936"},
+		{1111111109, AlgorithmSHA1, "07081804"},
+		{1111111109, AlgorithmSHA256, "68084774"},
+		{1111111109, AlgorithmSHA512, "25091201"},
+		{1234567890, AlgorithmSHA1, "89005924"},
+		{1234567890, AlgorithmSHA256, "91819424"},
+		{1234567890, AlgorithmSHA512, "93441116"},
+		{2000000000, AlgorithmSHA1, "69279037"},
+		{2000000000, AlgorithmSHA256, "90698825"},
+		{2000000000, AlgorithmSHA512, "38618901"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%d", tt.algorithm, tt.unix), func(t *testing.T) {
+			timeNow = func() time.Time { return time.Unix(tt.unix, 0) }
+
+			got, err := GenerateCodeWithParams(secrets[tt.algorithm], Params{
+				Algorithm: tt.algorithm,
+				Digits:    8,
+				Period:    30,
+			})
+			if err != nil {
+				t.Fatalf("GenerateCodeWithParams() unexpected error = %v", err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("GenerateCodeWithParams() = %s, want %s", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateCodeWithParamsPeriod(t *testing.T) {
+	originalTimeNow := timeNow
+	defer func() { timeNow = originalTimeNow }()
+
+	timeNow = func() time.Time { return time.Unix(90, 0) }
+
+	got, err := GenerateCodeWithParams("JBSWY3DPEHPK3PXP", Params{Period: 60})
+	if err != nil {
+		t.Fatalf("GenerateCodeWithParams() unexpected error = %v", err)
+	}
+	if got.Period != 60 || got.RemainingSeconds != 30 {
+		t.Errorf("GenerateCodeWithParams() period = %d remaining = %d, want 60 and 30", got.Period, got.RemainingSeconds)
+	}
+	if got.Digits != DefaultDigits || len(got.String()) != DefaultDigits {
+		t.Errorf("GenerateCodeWithParams() code = %s, want %d digits", got.String(), DefaultDigits)
+	}
+}
+
+func TestParamsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  Params
+		wantErr bool
+	}{
+		{"Defaults", DefaultParams(), false},
+		{"SHA512 ten digits", Params{AlgorithmSHA512, 10, 60}, false},
+		{"Unknown algorithm", Params{"MD5", 6, 30}, true},
+		{"Too few digits", Params{AlgorithmSHA1, 5, 30}, true},
+		{"Too many digits", Params{AlgorithmSHA1, 11, 30}, true},
+		{"Zero period", Params{AlgorithmSHA1, 6, 0}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.params.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
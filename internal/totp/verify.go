@@ -0,0 +1,88 @@
+package totp
+
+import (
+	"crypto/subtle"
+	"errors"
+)
+
+// DefaultSkew is the number of time steps either side of the current one accepted by Verify.
+const DefaultSkew = 1
+
+var (
+	// ErrInvalidCode is returned when a code does not match any step in the skew window.
+	ErrInvalidCode = errors.New("invalid code")
+
+	// ErrReplayedCode is returned when a code matches a step that was already accepted.
+	ErrReplayedCode = errors.New("code has already been used")
+)
+
+// VerifyOptions controls how Verify checks a code.
+type VerifyOptions struct {
+	Params Params
+
+	// Skew is the number of steps before and after the current one that are also accepted.
+	Skew int
+
+	// LastStep is the last time step accepted for this secret. When ReplayGuard
+	// is set, codes for this step or any earlier one are rejected.
+	LastStep    uint64
+	ReplayGuard bool
+}
+
+// Match describes the time step a verified code belongs to.
+type Match struct {
+	// Offset is the matched step relative to the current one, between -Skew and Skew.
+	Offset int
+
+	// Step is the absolute time step of the match.
+	Step uint64
+}
+
+// Verify checks a TOTP code against the secret at the current time.
+func Verify(secret, code string, opts VerifyOptions) (Match, error) {
+	key, params, err := prepare(secret, opts.Params)
+	if err != nil {
+		return Match{}, err
+	}
+
+	if opts.Skew < 0 {
+		return Match{}, errors.New("skew cannot be negative")
+	}
+	if len(code) != params.Digits {
+		return Match{}, ErrInvalidCode
+	}
+
+	// Try the current step first, then steps further away in both directions
+	offsets := []int{0}
+	for distance := 1; distance <= opts.Skew; distance++ {
+		offsets = append(offsets, -distance, distance)
+	}
+
+	current := timeNow().Unix() / int64(params.Period)
+	replayed := false
+	for _, offset := range offsets {
+		step := current + int64(offset)
+		if step < 0 {
+			continue
+		}
+
+		expected, err := computeCode(key, uint64(step), params)
+		if err != nil {
+			return Match{}, err
+		}
+		if subtle.ConstantTimeCompare([]byte(FormatCode(expected, params.Digits)), []byte(code)) != 1 {
+			continue
+		}
+
+		if opts.ReplayGuard && uint64(step) <= opts.LastStep {
+			replayed = true
+			continue
+		}
+		return Match{Offset: offset, Step: uint64(step)}, nil
+	}
+
+	if replayed {
+		return Match{}, ErrReplayedCode
+	}
+	return Match{}, ErrInvalidCode
+}
@@ -0,0 +1,62 @@
+package totp
+
+import (
+	"encoding/base32"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	originalTimeNow := timeNow
+	defer func() { timeNow = originalTimeNow }()
+
+	// RFC 6238 Appendix B: 94287082 is the SHA1 code for step 1 (T = 59)
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	params := Params{Algorithm: AlgorithmSHA1, Digits: 8, Period: 30}
+
+	tests := []struct {
+		name       string
+		unix       int64
+		code       string
+		opts       VerifyOptions
+		wantOffset int
+		wantErr    error
+	}{
+		{"Current step", 59, "94287082", VerifyOptions{Skew: 1}, 0, nil},
+		{"Previous step within skew", 89, "94287082", VerifyOptions{Skew: 1}, -1, nil},
+		{"Next step within skew", 29, "94287082", VerifyOptions{Skew: 1}, 1, nil},
+		{"Outside skew", 119, "94287082", VerifyOptions{Skew: 1}, 0, ErrInvalidCode},
+		{"No skew", 89, "94287082", VerifyOptions{}, 0, ErrInvalidCode},
+		{"Wrong code", 59, "12345678", VerifyOptions{Skew: 1}, 0, ErrInvalidCode},
+		{"Wrong length", 59, "4287082", VerifyOptions{Skew: 1}, 0, ErrInvalidCode},
+		{"Replay guard allows later step", 59, "94287082", VerifyOptions{Skew: 1, ReplayGuard: true, LastStep: 0}, 0, nil},
+		{"Replay guard rejects same step", 59, "94287082", VerifyOptions{Skew: 1, ReplayGuard: true, LastStep: 1}, 0, ErrReplayedCode},
+		{"Replay guard rejects earlier step", 89, "94287082", VerifyOptions{Skew: 1, ReplayGuard: true, LastStep: 2}, 0, ErrReplayedCode},
+		{"Last step ignored without guard", 59, "94287082", VerifyOptions{Skew: 1, LastStep: 5}, 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeNow = func() time.Time { return time.Unix(tt.unix, 0) }
+
+			tt.opts.Params = params
+			got, err := Verify(secret, tt.code, tt.opts)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if got.Offset != tt.wantOffset || got.Step != 1 {
+				t.Errorf("Verify() = %+v, want offset %d at step 1", got, tt.wantOffset)
+			}
+		})
+	}
+}
+
+func TestVerifyNegativeSkew(t *testing.T) {
+	if _, err := Verify("JBSWY3DPEHPK3PXP", "123456", VerifyOptions{Skew: -1}); err == nil {
+		t.Error("Verify() expected error for negative skew")
+	}
+}
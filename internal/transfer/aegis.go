@@ -0,0 +1,424 @@
+package transfer
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bykclk/twocli/internal/crypto"
+	"github.com/bykclk/twocli/internal/totp"
+)
+
+// Aegis backups are JSON files with a header and a database of entries:
+//
+//	{
+//	  "version": 1,
+//	  "header": {"slots": [...], "params": {"nonce": "...", "tag": "..."}},
+//	  "db": {"version": 3, "entries": [...], "groups": [...]}
+//	}
+//
+// In a plaintext backup the slots and params are null and db is an object.
+// In an encrypted one, db is the base64 encoding of the database encrypted
+// with AES-256-GCM under a random master key, with the nonce and tag in the
+// header params. Each password slot holds the master key encrypted under a
+// key derived from the password with scrypt.
+// See https://github.com/beemdevelopment/Aegis/blob/master/docs/vault.md.
+const (
+	aegisVersion   = 1
+	aegisDBVersion = 3
+
+	aegisSlotPassword = 1
+	aegisKeySize      = 32
+	aegisNonceSize    = 12
+	aegisTagSize      = 16
+
+	// Aegis' own scrypt parameters for password slots
+	aegisScryptN = 1 << 15
+	aegisScryptR = 8
+	aegisScryptP = 1
+)
+
+type aegisFile struct {
+	Version int             `json:"version"`
+	Header  aegisHeader     `json:"header"`
+	DB      json.RawMessage `json:"db"`
+}
+
+type aegisHeader struct {
+	Slots  []aegisSlot  `json:"slots"`
+	Params *aegisParams `json:"params"`
+}
+
+type aegisParams struct {
+	Nonce string `json:"nonce"`
+	Tag   string `json:"tag"`
+}
+
+type aegisSlot struct {
+	Type      int         `json:"type"`
+	UUID      string      `json:"uuid"`
+	Key       string      `json:"key"`
+	KeyParams aegisParams `json:"key_params"`
+	N         int         `json:"n,omitempty"`
+	R         int         `json:"r,omitempty"`
+	P         int         `json:"p,omitempty"`
+	Salt      string      `json:"salt,omitempty"`
+	Repaired  bool        `json:"repaired,omitempty"`
+	IsBackup  bool        `json:"is_backup,omitempty"`
+}
+
+type aegisDB struct {
+	Version int          `json:"version"`
+	Entries []aegisEntry `json:"entries"`
+	Groups  []aegisGroup `json:"groups,omitempty"`
+}
+
+type aegisGroup struct {
+	UUID string `json:"uuid"`
+	Name string `json:"name"`
+}
+
+// aegisEntry is an entry of an Aegis database. Databases before version 3
+// have a single group name rather than a list of group UUIDs.
+type aegisEntry struct {
+	Type     string    `json:"type"`
+	UUID     string    `json:"uuid"`
+	Name     string    `json:"name"`
+	Issuer   string    `json:"issuer"`
+	Note     string    `json:"note"`
+	Favorite bool      `json:"favorite"`
+	Icon     *string   `json:"icon"`
+	Info     aegisInfo `json:"info"`
+	Group    string    `json:"group,omitempty"`
+	Groups   []string  `json:"groups,omitempty"`
+}
+
+type aegisInfo struct {
+	Secret  string  `json:"secret"`
+	Algo    string  `json:"algo"`
+	Digits  int     `json:"digits"`
+	Period  int     `json:"period,omitempty"`
+	Counter *uint64 `json:"counter,omitempty"`
+}
+
+// ErrNotAegisBackup is returned when reading a file that is not an Aegis backup.
+var ErrNotAegisBackup = errors.New("not an Aegis backup")
+
+// readAegisFile decodes the outer structure of an Aegis backup.
+func readAegisFile(data []byte) (aegisFile, error) {
+	var f aegisFile
+	if err := json.Unmarshal(data, &f); err != nil || f.Version == 0 || len(f.DB) == 0 {
+		return aegisFile{}, ErrNotAegisBackup
+	}
+	if f.Version > aegisVersion {
+		return aegisFile{}, fmt.Errorf("unsupported Aegis backup version %d", f.Version)
+	}
+	return f, nil
+}
+
+// IsAegisEncrypted reports whether an Aegis backup is encrypted, and needs a
+// password to be read.
+func IsAegisEncrypted(data []byte) bool {
+	f, err := readAegisFile(data)
+	return err == nil && f.Header.Params != nil
+}
+
+// ReadAegis decodes an Aegis backup, decrypting it with the password if it
+// is encrypted. Entries of types twocli does not support, such as Steam, are
+// skipped.
+func ReadAegis(data []byte, password string) (*Backup, error) {
+	f, err := readAegisFile(data)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext := []byte(f.DB)
+	if f.Header.Params != nil {
+		if plaintext, err = decryptAegisDB(f, password); err != nil {
+			return nil, err
+		}
+	}
+
+	var db aegisDB
+	if err = json.Unmarshal(plaintext, &db); err != nil {
+		return nil, fmt.Errorf("invalid Aegis database: %v", err)
+	}
+	if db.Version > aegisDBVersion {
+		return nil, fmt.Errorf("unsupported Aegis database version %d", db.Version)
+	}
+
+	groups := map[string]string{}
+	for _, g := range db.Groups {
+		groups[g.UUID] = g.Name
+	}
+
+	backup := &Backup{}
+	for _, e := range db.Entries {
+		entry, err := aegisEntryToKey(e)
+		if err != nil {
+			backup.Skipped = append(backup.Skipped, fmt.Sprintf("%s: %v", aegisLabel(e), err))
+			continue
+		}
+		if e.Group != "" {
+			entry.Tags = append(entry.Tags, e.Group)
+		}
+		for _, uuid := range e.Groups {
+			if name, ok := groups[uuid]; ok {
+				entry.Tags = append(entry.Tags, name)
+			}
+		}
+		backup.Entries = append(backup.Entries, entry)
+	}
+	return backup, nil
+}
+
+// decryptAegisDB unlocks the master key with the first password slot the
+// password opens, and decrypts the database with it.
+func decryptAegisDB(f aegisFile, password string) ([]byte, error) {
+	var encrypted string
+	if err := json.Unmarshal(f.DB, &encrypted); err != nil {
+		return nil, fmt.Errorf("invalid Aegis database: %v", err)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Aegis database: %v", err)
+	}
+
+	var masterKey []byte
+	hasPasswordSlot := false
+	for _, slot := range f.Header.Slots {
+		if slot.Type != aegisSlotPassword {
+			continue
+		}
+		hasPasswordSlot = true
+
+		salt, err := hex.DecodeString(slot.Salt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Aegis slot: %v", err)
+		}
+		key, err := crypto.ScryptKey(password, salt, slot.N, slot.R, slot.P)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Aegis slot: %v", err)
+		}
+		wrappedKey, err := hex.DecodeString(slot.Key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Aegis slot: %v", err)
+		}
+		if masterKey, err = openAegis(key, slot.KeyParams, wrappedKey); err == nil {
+			break
+		}
+	}
+	if !hasPasswordSlot {
+		return nil, errors.New("the Aegis backup has no password slot, export it with a password")
+	}
+	if masterKey == nil {
+		return nil, ErrIncorrectPassphrase
+	}
+
+	plaintext, err := openAegis(masterKey, *f.Header.Params, ciphertext)
+	if err != nil {
+		return nil, ErrIncorrectPassphrase
+	}
+	return plaintext, nil
+}
+
+// openAegis decrypts data with AES-256-GCM, using the nonce and tag in the params.
+func openAegis(key []byte, params aegisParams, ciphertext []byte) ([]byte, error) {
+	nonce, err := hex.DecodeString(params.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	tag, err := hex.DecodeString(params.Tag)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.OpenGCM(key, nonce, append(ciphertext, tag...))
+}
+
+// aegisLabel describes an Aegis entry in messages.
+func aegisLabel(e aegisEntry) string {
+	if e.Issuer == "" {
+		return e.Name
+	}
+	return e.Issuer + ":" + e.Name
+}
+
+// aegisEntryToKey converts an Aegis entry, without its groups.
+func aegisEntryToKey(e aegisEntry) (Entry, error) {
+	key := &totp.Key{
+		Issuer:      strings.TrimSpace(e.Issuer),
+		AccountName: strings.TrimSpace(e.Name),
+		Secret:      strings.TrimRight(strings.ToUpper(strings.ReplaceAll(e.Info.Secret, " ", "")), "="),
+		Params: totp.Params{
+			Algorithm: totp.NormalizeAlgorithm(e.Info.Algo),
+			Digits:    e.Info.Digits,
+			Period:    e.Info.Period,
+		},
+	}
+
+	switch e.Type {
+	case totp.TypeTOTP:
+		key.Type = totp.TypeTOTP
+	case totp.TypeHOTP:
+		key.Type = totp.TypeHOTP
+		key.Params.Period = 0
+		if e.Info.Counter != nil {
+			key.Counter = *e.Info.Counter
+		}
+	default:
+		return Entry{}, fmt.Errorf("%s entries are not supported", e.Type)
+	}
+
+	if err := totp.ValidateSecret(key.Secret); err != nil {
+		return Entry{}, fmt.Errorf("invalid secret: %v", err)
+	}
+	key.Params = key.Params.WithDefaults()
+	if err := key.Params.Validate(); err != nil {
+		return Entry{}, err
+	}
+	return Entry{Key: key, Notes: e.Note}, nil
+}
+
+// MarshalAegis encodes the entries as a plaintext Aegis backup.
+func MarshalAegis(entries []Entry) ([]byte, error) {
+	db, err := marshalAegisDB(entries)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(aegisFile{Version: aegisVersion, DB: db}, "", "  ")
+}
+
+// MarshalAegisEncrypted encodes the entries as an Aegis backup encrypted
+// under a random master key, with a single password slot.
+func MarshalAegisEncrypted(entries []Entry, password string) ([]byte, error) {
+	db, err := marshalAegisDB(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	masterKey, err := crypto.RandomBytes(aegisKeySize)
+	if err != nil {
+		return nil, err
+	}
+	params, ciphertext, err := sealAegis(masterKey, db)
+	if err != nil {
+		return nil, err
+	}
+
+	salt, err := crypto.RandomBytes(32)
+	if err != nil {
+		return nil, err
+	}
+	slotKey, err := crypto.ScryptKey(password, salt, aegisScryptN, aegisScryptR, aegisScryptP)
+	if err != nil {
+		return nil, err
+	}
+	keyParams, wrappedKey, err := sealAegis(slotKey, masterKey)
+	if err != nil {
+		return nil, err
+	}
+	uuid, err := newUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := json.Marshal(base64.StdEncoding.EncodeToString(ciphertext))
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(aegisFile{
+		Version: aegisVersion,
+		Header: aegisHeader{
+			Slots: []aegisSlot{{
+				Type:      aegisSlotPassword,
+				UUID:      uuid,
+				Key:       hex.EncodeToString(wrappedKey),
+				KeyParams: keyParams,
+				N:         aegisScryptN,
+				R:         aegisScryptR,
+				P:         aegisScryptP,
+				Salt:      hex.EncodeToString(salt),
+				Repaired:  true,
+			}},
+			Params: &params,
+		},
+		DB: encoded,
+	}, "", "  ")
+}
+
+// sealAegis encrypts data with AES-256-GCM under a random nonce, and returns
+// the nonce and tag separately from the ciphertext.
+func sealAegis(key, data []byte) (aegisParams, []byte, error) {
+	nonce, err := crypto.RandomBytes(aegisNonceSize)
+	if err != nil {
+		return aegisParams{}, nil, err
+	}
+	sealed, err := crypto.SealGCM(key, nonce, data)
+	if err != nil {
+		return aegisParams{}, nil, err
+	}
+	ciphertext, tag := sealed[:len(sealed)-aegisTagSize], sealed[len(sealed)-aegisTagSize:]
+	return aegisParams{Nonce: hex.EncodeToString(nonce), Tag: hex.EncodeToString(tag)}, ciphertext, nil
+}
+
+// marshalAegisDB encodes the entries as an Aegis database, with a group for each tag.
+func marshalAegisDB(entries []Entry) ([]byte, error) {
+	db := aegisDB{Version: aegisDBVersion, Entries: []aegisEntry{}}
+	groups := map[string]string{}
+	for _, entry := range entries {
+		key := entry.Key
+		params := key.Params.WithDefaults()
+		e := aegisEntry{
+			Type:   key.Type,
+			Name:   key.AccountName,
+			Issuer: key.Issuer,
+			Note:   entry.Notes,
+			Info: aegisInfo{
+				Secret: key.Secret,
+				Algo:   params.Algorithm,
+				Digits: params.Digits,
+			},
+		}
+		if e.Type == "" {
+			e.Type = totp.TypeTOTP
+		}
+		if e.Type == totp.TypeHOTP {
+			counter := key.Counter
+			e.Info.Counter = &counter
+		} else {
+			e.Info.Period = params.Period
+		}
+		var err error
+		if e.UUID, err = newUUID(); err != nil {
+			return nil, err
+		}
+
+		for _, tag := range entry.Tags {
+			uuid, ok := groups[strings.ToLower(tag)]
+			if !ok {
+				if uuid, err = newUUID(); err != nil {
+					return nil, err
+				}
+				groups[strings.ToLower(tag)] = uuid
+				db.Groups = append(db.Groups, aegisGroup{UUID: uuid, Name: tag})
+			}
+			e.Groups = append(e.Groups, uuid)
+		}
+		db.Entries = append(db.Entries, e)
+	}
+	return json.Marshal(db)
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() (string, error) {
+	b, err := crypto.RandomBytes(16)
+	if err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
@@ -0,0 +1,78 @@
+package transfer
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadAegis(t *testing.T) {
+	testReadBackups(t, []backupFile{{"testdata/aegis_plain.json", false, ""}}, IsAegisEncrypted, ReadAegis,
+		testEntriesWithNotes(), []string{"Steam:gamer: steam entries are not supported"})
+}
+
+func TestAegisRoundTrip(t *testing.T) {
+	data, err := MarshalAegis(testEntriesWithNotes())
+	if err != nil {
+		t.Fatalf("Failed to marshal backup: %v", err)
+	}
+	backup, err := ReadAegis(data, "")
+	if err != nil {
+		t.Fatalf("Failed to read backup: %v", err)
+	}
+	if !reflect.DeepEqual(backup.Entries, testEntriesWithNotes()) {
+		t.Errorf("Entries = %+v, want %+v", backup.Entries, testEntriesWithNotes())
+	}
+}
+
+func TestAegisEncrypted(t *testing.T) {
+	data, err := MarshalAegisEncrypted(testEntriesWithNotes(), "test")
+	if err != nil {
+		t.Fatalf("Failed to marshal backup: %v", err)
+	}
+	if !IsAegisEncrypted(data) {
+		t.Fatalf("Expected an encrypted backup to be reported as encrypted")
+	}
+	if strings.Contains(string(data), "JBSWY3DPEHPK3PXP") {
+		t.Errorf("Expected the encrypted backup not to contain the secret")
+	}
+
+	if _, err = ReadAegis(data, "wrong"); !errors.Is(err, ErrIncorrectPassphrase) {
+		t.Errorf("Expected ErrIncorrectPassphrase, got %v", err)
+	}
+
+	backup, err := ReadAegis(data, "test")
+	if err != nil {
+		t.Fatalf("Failed to read backup: %v", err)
+	}
+	if !reflect.DeepEqual(backup.Entries, testEntriesWithNotes()) {
+		t.Errorf("Entries = %+v, want %+v", backup.Entries, testEntriesWithNotes())
+	}
+}
+
+func TestReadAegisCostlySlot(t *testing.T) {
+	data, err := MarshalAegisEncrypted(testEntriesWithNotes(), "test")
+	if err != nil {
+		t.Fatalf("Failed to marshal backup: %v", err)
+	}
+	costly := strings.Replace(string(data), `"n": 32768`, `"n": 1073741824`, 1)
+	if costly == string(data) {
+		t.Fatalf("Expected the backup to hold a slot with N = 32768")
+	}
+
+	if _, err = ReadAegis([]byte(costly), "test"); err == nil || errors.Is(err, ErrIncorrectPassphrase) {
+		t.Errorf("Expected an invalid slot error, got %v", err)
+	}
+}
+
+func TestReadAegisInvalid(t *testing.T) {
+	for _, data := range []string{
+		`{"format": "twocli-export", "version": 1}`,
+		`not json`,
+	} {
+		if _, err := ReadAegis([]byte(data), ""); !errors.Is(err, ErrNotAegisBackup) {
+			t.Errorf("Expected ErrNotAegisBackup for %s, got %v", data, err)
+		}
+	}
+}
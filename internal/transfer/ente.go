@@ -0,0 +1,148 @@
+package transfer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/bykclk/twocli/internal/crypto"
+	"github.com/bykclk/twocli/internal/totp"
+)
+
+// Ente Auth exports its codes as a text file with one otpauth URI per line.
+// Each URI may carry a codeDisplay parameter, a JSON object with the code's
+// tags and note, and whether it is in Ente's trash. An encrypted export is a
+// JSON document holding that text encrypted with libsodium's secretstream,
+// under a key derived from the password with Argon2id:
+//
+//	{
+//	  "version": 1,
+//	  "kdfParams": {"memLimit": 67108864, "opsLimit": 2, "salt": "base64"},
+//	  "encryptedData": "base64",
+//	  "encryptionNonce": "base64"
+//	}
+const enteVersion = 1
+
+type enteEncrypted struct {
+	Version   int `json:"version"`
+	KDFParams struct {
+		MemLimit uint32 `json:"memLimit"`
+		OpsLimit uint32 `json:"opsLimit"`
+		Salt     string `json:"salt"`
+	} `json:"kdfParams"`
+	EncryptedData   string `json:"encryptedData"`
+	EncryptionNonce string `json:"encryptionNonce"`
+}
+
+type enteCodeDisplay struct {
+	Trashed bool     `json:"trashed"`
+	Tags    []string `json:"tags"`
+	Note    string   `json:"note"`
+}
+
+// readEnteEncrypted decodes the envelope of an encrypted Ente Auth export.
+func readEnteEncrypted(data []byte) (enteEncrypted, bool) {
+	var e enteEncrypted
+	if err := json.Unmarshal(data, &e); err != nil || e.EncryptedData == "" || e.EncryptionNonce == "" {
+		return enteEncrypted{}, false
+	}
+	return e, true
+}
+
+// IsEnteEncrypted reports whether an Ente Auth export is encrypted, and needs
+// a password to be read.
+func IsEnteEncrypted(data []byte) bool {
+	_, ok := readEnteEncrypted(data)
+	return ok
+}
+
+// ReadEnte decodes an Ente Auth export, decrypting it with the password if
+// it is encrypted. Codes in Ente's trash and codes twocli does not support,
+// such as Steam codes, are skipped.
+func ReadEnte(data []byte, password string) (*Backup, error) {
+	if e, ok := readEnteEncrypted(data); ok {
+		var err error
+		if data, err = decryptEnte(e, password); err != nil {
+			return nil, err
+		}
+	}
+
+	backup := &Backup{}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(strings.ToLower(line), "otpauth://") {
+			return nil, fmt.Errorf("not an Ente Auth export: line %d is not an otpauth URI", i+1)
+		}
+
+		entry, err := readEnteCode(line)
+		if err != nil {
+			backup.Skipped = append(backup.Skipped, fmt.Sprintf("line %d: %v", i+1, err))
+			continue
+		}
+		backup.Entries = append(backup.Entries, entry)
+	}
+	return backup, nil
+}
+
+// readEnteCode parses an otpauth URI from an Ente Auth export, failing for
+// codes in Ente's trash.
+func readEnteCode(uri string) (Entry, error) {
+	key, err := totp.ParseURI(uri)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	entry := Entry{Key: key}
+	if u, err := url.Parse(uri); err == nil {
+		if display := u.Query().Get("codeDisplay"); display != "" {
+			var d enteCodeDisplay
+			if err = json.Unmarshal([]byte(display), &d); err != nil {
+				return Entry{}, fmt.Errorf("%s: invalid codeDisplay: %v", key.Label(), err)
+			}
+			if d.Trashed {
+				return Entry{}, fmt.Errorf("%s is in Ente's trash", key.Label())
+			}
+			entry.Tags, entry.Notes = d.Tags, d.Note
+		}
+	}
+	return entry, nil
+}
+
+// decryptEnte decrypts an encrypted Ente Auth export.
+func decryptEnte(e enteEncrypted, password string) ([]byte, error) {
+	if e.Version > enteVersion {
+		return nil, fmt.Errorf("unsupported Ente Auth export version %d", e.Version)
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(e.KDFParams.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Ente Auth export: %v", err)
+	}
+	header, err := base64.StdEncoding.DecodeString(e.EncryptionNonce)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Ente Auth export: %v", err)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(e.EncryptedData)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Ente Auth export: %v", err)
+	}
+	if len(header) != crypto.SecretStreamHeaderSize {
+		return nil, errors.New("invalid Ente Auth export: bad nonce size")
+	}
+
+	key, err := crypto.SodiumKey(password, salt, e.KDFParams.OpsLimit, e.KDFParams.MemLimit)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Ente Auth export: %v", err)
+	}
+	plaintext, err := crypto.OpenSecretStream(key, header, ciphertext)
+	if err != nil {
+		return nil, ErrIncorrectPassphrase
+	}
+	return plaintext, nil
+}
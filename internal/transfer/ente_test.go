@@ -0,0 +1,34 @@
+package transfer
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestReadEnte(t *testing.T) {
+	// ente_encrypted.json holds ente_plain.txt, encrypted with libsodium
+	files := []backupFile{
+		{"testdata/ente_plain.txt", false, ""},
+		{"testdata/ente_encrypted.json", true, "test"},
+	}
+	skipped := []string{
+		`line 4: invalid otpauth URI: unsupported type "steam"`,
+		"line 5: Old:carol is in Ente's trash",
+	}
+	testReadBackups(t, files, IsEnteEncrypted, ReadEnte, testEntriesWithNotes(), skipped)
+}
+
+func TestReadEnteInvalid(t *testing.T) {
+	data, err := os.ReadFile("testdata/ente_encrypted.json")
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	if _, err = ReadEnte(data, "wrong"); !errors.Is(err, ErrIncorrectPassphrase) {
+		t.Errorf("Expected ErrIncorrectPassphrase, got %v", err)
+	}
+
+	if _, err = ReadEnte([]byte(`{"services": []}`), ""); err == nil {
+		t.Errorf("Expected a file that is not an Ente Auth export to fail")
+	}
+}
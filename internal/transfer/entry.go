@@ -0,0 +1,18 @@
+package transfer
+
+import "github.com/bykclk/twocli/internal/totp"
+
+// Entry is an account in the backup of another authenticator.
+type Entry struct {
+	Key   *totp.Key
+	Tags  []string
+	Notes string
+}
+
+// Backup is the content of another authenticator's backup.
+type Backup struct {
+	Entries []Entry
+
+	// Skipped describes the entries that twocli cannot use.
+	Skipped []string
+}
@@ -0,0 +1,81 @@
+package transfer
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/bykclk/twocli/internal/totp"
+)
+
+// testEntries are the supported entries in the backups under testdata, and
+// those written by the round trip tests.
+func testEntries() []Entry {
+	return []Entry{
+		{
+			Key: &totp.Key{
+				Type:        totp.TypeTOTP,
+				Issuer:      "GitHub",
+				AccountName: "alice@example.com",
+				Secret:      "JBSWY3DPEHPK3PXP",
+				Params:      totp.DefaultParams(),
+			},
+			Tags: []string{"Work"},
+		},
+		{
+			Key: &totp.Key{
+				Type:        totp.TypeHOTP,
+				Issuer:      "VPN",
+				AccountName: "bob",
+				Secret:      "GEZDGNBVGY3TQOJQ",
+				Params:      totp.Params{Algorithm: totp.AlgorithmSHA256, Digits: 8, Period: 30},
+				Counter:     12,
+			},
+		},
+	}
+}
+
+// testEntriesWithNotes are testEntries as read from the formats that keep
+// notes.
+func testEntriesWithNotes() []Entry {
+	entries := testEntries()
+	entries[0].Notes = "personal"
+	return entries
+}
+
+// backupFile is a backup under testdata, and the password it is encrypted with.
+type backupFile struct {
+	file      string
+	encrypted bool
+	password  string
+}
+
+// testReadBackups reads each of the files, and checks that it is reported as
+// encrypted or not and holds the wanted entries and skipped ones.
+func testReadBackups(t *testing.T, files []backupFile, isEncrypted func([]byte) bool,
+	read func([]byte, string) (*Backup, error), want []Entry, skipped []string) {
+	t.Helper()
+
+	for _, f := range files {
+		t.Run(f.file, func(t *testing.T) {
+			data, err := os.ReadFile(f.file)
+			if err != nil {
+				t.Fatalf("Failed to read test data: %v", err)
+			}
+			if got := isEncrypted(data); got != f.encrypted {
+				t.Errorf("Reported as encrypted = %v, want %v", got, f.encrypted)
+			}
+
+			backup, err := read(data, f.password)
+			if err != nil {
+				t.Fatalf("Failed to read backup: %v", err)
+			}
+			if !reflect.DeepEqual(backup.Entries, want) {
+				t.Errorf("Entries = %+v, want %+v", backup.Entries, want)
+			}
+			if !reflect.DeepEqual(backup.Skipped, skipped) {
+				t.Errorf("Skipped = %q, want %q", backup.Skipped, skipped)
+			}
+		})
+	}
+}
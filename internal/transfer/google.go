@@ -0,0 +1,364 @@
+package transfer
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/url"
+	"strings"
+
+	"github.com/bykclk/twocli/internal/totp"
+)
+
+// Google Authenticator exports accounts as otpauth-migration://offline?data=
+// URIs, where data is the base64 encoding of a MigrationPayload message:
+//
+//	message MigrationPayload {
+//	  repeated OtpParameters otp_parameters = 1;
+//	  int32 version = 2;
+//	  int32 batch_size = 3;
+//	  int32 batch_index = 4;
+//	  int32 batch_id = 5;
+//	}
+//
+//	message OtpParameters {
+//	  bytes secret = 1;
+//	  string name = 2;
+//	  string issuer = 3;
+//	  Algorithm algorithm = 4;  // 1 SHA1, 2 SHA256, 3 SHA512, 4 MD5
+//	  DigitCount digits = 5;    // 1 six, 2 eight
+//	  OtpType type = 6;         // 1 HOTP, 2 TOTP
+//	  int64 counter = 7;
+//	}
+//
+// Zero enum values are unspecified and mean the defaults. The period is not
+// exported, and is always 30 seconds. Exports with many accounts are split
+// into batches, numbered from 0, that share a batch ID.
+const migrationScheme = "otpauth-migration"
+
+// Google Authenticator enum values
+const (
+	googleAlgorithmSHA1   = 1
+	googleAlgorithmSHA256 = 2
+	googleAlgorithmSHA512 = 3
+	googleAlgorithmMD5    = 4
+
+	googleDigitsSix   = 1
+	googleDigitsEight = 2
+
+	googleTypeHOTP = 1
+	googleTypeTOTP = 2
+)
+
+// maxMigrationBatches is the largest number of batches accepted in an export,
+// far more than Google Authenticator needs for any real collection of accounts.
+const maxMigrationBatches = 1000
+
+// maxListedBatches is the number of missing batches of an export that
+// MissingBatches names one by one before summing them up.
+const maxListedBatches = 5
+
+// ErrNotMigrationURI is returned when parsing a URI that is not an otpauth-migration URI.
+var ErrNotMigrationURI = errors.New("not an otpauth-migration URI")
+
+// MigrationBatch is one otpauth-migration URI exported by Google Authenticator.
+type MigrationBatch struct {
+	Keys []*totp.Key
+
+	// Skipped describes the accounts in the batch that twocli cannot use.
+	Skipped []string
+
+	Version int
+	Size    int
+	Index   int
+	ID      int
+}
+
+// ParseMigrationURI parses an otpauth-migration URI exported by Google Authenticator.
+func ParseMigrationURI(uri string) (*MigrationBatch, error) {
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil || !strings.EqualFold(u.Scheme, migrationScheme) {
+		return nil, ErrNotMigrationURI
+	}
+
+	// Some scanners leave the data unescaped, which turns '+' into a space
+	data := strings.ReplaceAll(u.Query().Get("data"), " ", "+")
+	if data == "" {
+		return nil, errors.New("invalid otpauth-migration URI: no data")
+	}
+	payload, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		if payload, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(data, "=")); err != nil {
+			return nil, fmt.Errorf("invalid otpauth-migration URI data: %v", err)
+		}
+	}
+
+	batch := &MigrationBatch{}
+	err = readProto(payload, func(f protoField) error {
+		switch {
+		case f.Number == 1 && f.WireType == wireBytes:
+			key, err := parseOtpParameters(f.Bytes)
+			if err != nil {
+				var skipped *skippedError
+				if errors.As(err, &skipped) {
+					batch.Skipped = append(batch.Skipped, skipped.Error())
+					return nil
+				}
+				return err
+			}
+			batch.Keys = append(batch.Keys, key)
+		case f.Number == 2 && f.WireType == wireVarint:
+			batch.Version = int(int32(f.Varint))
+		case f.Number == 3 && f.WireType == wireVarint:
+			batch.Size = int(int32(f.Varint))
+		case f.Number == 4 && f.WireType == wireVarint:
+			batch.Index = int(int32(f.Varint))
+		case f.Number == 5 && f.WireType == wireVarint:
+			batch.ID = int(int32(f.Varint))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid otpauth-migration payload: %v", err)
+	}
+
+	// The batch numbers are only used for reporting missing batches, but a
+	// crafted size must not make that report endless
+	if batch.Size < 0 || batch.Size > maxMigrationBatches || batch.Index < 0 || (batch.Size > 0 && batch.Index >= batch.Size) {
+		return nil, fmt.Errorf("invalid otpauth-migration payload: batch %d of %d", batch.Index+1, batch.Size)
+	}
+	return batch, nil
+}
+
+// skippedError is returned for an account that is valid but cannot be used by twocli.
+type skippedError struct {
+	label  string
+	reason string
+}
+
+func (e *skippedError) Error() string {
+	return fmt.Sprintf("%s: %s", e.label, e.reason)
+}
+
+// parseOtpParameters decodes an OtpParameters message into a key.
+func parseOtpParameters(data []byte) (*totp.Key, error) {
+	var secret []byte
+	var name, issuer string
+	var algorithm, digits, otpType, counter uint64
+	err := readProto(data, func(f protoField) error {
+		switch {
+		case f.Number == 1 && f.WireType == wireBytes:
+			secret = f.Bytes
+		case f.Number == 2 && f.WireType == wireBytes:
+			name = string(f.Bytes)
+		case f.Number == 3 && f.WireType == wireBytes:
+			issuer = string(f.Bytes)
+		case f.Number == 4 && f.WireType == wireVarint:
+			algorithm = f.Varint
+		case f.Number == 5 && f.WireType == wireVarint:
+			digits = f.Varint
+		case f.Number == 6 && f.WireType == wireVarint:
+			otpType = f.Varint
+		case f.Number == 7 && f.WireType == wireVarint:
+			counter = f.Varint
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The name is a label, which may start with the issuer
+	key := &totp.Key{Issuer: strings.TrimSpace(issuer)}
+	switch {
+	case key.Issuer != "" && strings.HasPrefix(name, key.Issuer+":"):
+		key.AccountName = strings.TrimSpace(strings.TrimPrefix(name, key.Issuer+":"))
+	case key.Issuer == "" && strings.Contains(name, ":"):
+		prefix, account, _ := strings.Cut(name, ":")
+		key.Issuer = strings.TrimSpace(prefix)
+		key.AccountName = strings.TrimSpace(account)
+	default:
+		key.AccountName = strings.TrimSpace(name)
+	}
+	label := key.Label()
+
+	if len(secret) == 0 {
+		return nil, &skippedError{label, "no secret"}
+	}
+	key.Secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secret)
+
+	switch algorithm {
+	case 0, googleAlgorithmSHA1:
+		key.Params.Algorithm = totp.AlgorithmSHA1
+	case googleAlgorithmSHA256:
+		key.Params.Algorithm = totp.AlgorithmSHA256
+	case googleAlgorithmSHA512:
+		key.Params.Algorithm = totp.AlgorithmSHA512
+	case googleAlgorithmMD5:
+		return nil, &skippedError{label, "MD5 codes are not supported"}
+	default:
+		return nil, &skippedError{label, fmt.Sprintf("unknown algorithm %d", algorithm)}
+	}
+
+	switch digits {
+	case 0, googleDigitsSix:
+		key.Params.Digits = 6
+	case googleDigitsEight:
+		key.Params.Digits = 8
+	default:
+		return nil, &skippedError{label, fmt.Sprintf("unknown digit count %d", digits)}
+	}
+
+	switch otpType {
+	case 0, googleTypeTOTP:
+		key.Type = totp.TypeTOTP
+	case googleTypeHOTP:
+		key.Type = totp.TypeHOTP
+		key.Counter = counter
+	default:
+		return nil, &skippedError{label, fmt.Sprintf("unknown type %d", otpType)}
+	}
+
+	key.Params = key.Params.WithDefaults()
+	return key, nil
+}
+
+// MissingBatches describes the batches of each Google Authenticator export
+// that are not among the given ones, so a partial import can be reported.
+func MissingBatches(batches []*MigrationBatch) []string {
+	type export struct {
+		size int
+		seen map[int]bool
+	}
+	exports := map[int]*export{}
+	var ids []int
+	for _, b := range batches {
+		e, ok := exports[b.ID]
+		if !ok {
+			e = &export{seen: map[int]bool{}}
+			exports[b.ID] = e
+			ids = append(ids, b.ID)
+		}
+		e.size = max(e.size, b.Size)
+		e.seen[b.Index] = true
+	}
+
+	var missing []string
+	for _, id := range ids {
+		e := exports[id]
+		if n := e.size - len(e.seen); n > maxListedBatches {
+			missing = append(missing, fmt.Sprintf("%d batches of %d", n, e.size))
+			continue
+		}
+		for i := 0; i < e.size; i++ {
+			if !e.seen[i] {
+				missing = append(missing, fmt.Sprintf("batch %d of %d", i+1, e.size))
+			}
+		}
+	}
+	return missing
+}
+
+// GoogleUnsupported returns why Google Authenticator cannot use the key, or
+// an empty string if it can.
+func GoogleUnsupported(key *totp.Key) string {
+	params := key.Params.WithDefaults()
+	switch {
+	case params.Digits != 6 && params.Digits != 8:
+		return fmt.Sprintf("%d-digit codes are not supported", params.Digits)
+	case key.Type != totp.TypeHOTP && params.Period != totp.DefaultPeriod:
+		return fmt.Sprintf("a %d-second period is not supported", params.Period)
+	}
+	if _, err := decodeSecret(key.Secret); err != nil {
+		return fmt.Sprintf("invalid secret: %v", err)
+	}
+	return ""
+}
+
+// MigrationURIs encodes the keys as otpauth-migration URIs that Google
+// Authenticator can scan, with at most perBatch keys in each. The batches
+// share a random batch ID and are numbered in order. Keys must be supported
+// by Google Authenticator, as reported by GoogleUnsupported.
+func MigrationURIs(keys []*totp.Key, perBatch int) ([]string, error) {
+	if perBatch < 1 {
+		return nil, errors.New("batch size must be at least 1")
+	}
+
+	var params [][]byte
+	for _, key := range keys {
+		if reason := GoogleUnsupported(key); reason != "" {
+			return nil, fmt.Errorf("%s: %s", key.Label(), reason)
+		}
+		p, err := marshalOtpParameters(key)
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, p)
+	}
+
+	size := (len(params) + perBatch - 1) / perBatch
+	id := rand.Uint32() >> 1
+	uris := make([]string, 0, size)
+	for index := 0; index < size; index++ {
+		var payload []byte
+		for _, p := range params[index*perBatch : min((index+1)*perBatch, len(params))] {
+			payload = appendProtoBytes(payload, 1, p)
+		}
+		payload = appendProtoVarint(payload, 2, 1)
+		payload = appendProtoVarint(payload, 3, uint64(size))
+		payload = appendProtoVarint(payload, 4, uint64(index))
+		payload = appendProtoVarint(payload, 5, uint64(id))
+
+		uris = append(uris, migrationScheme+"://offline?data="+url.QueryEscape(base64.StdEncoding.EncodeToString(payload)))
+	}
+	return uris, nil
+}
+
+// marshalOtpParameters encodes a key as an OtpParameters message.
+func marshalOtpParameters(key *totp.Key) ([]byte, error) {
+	secret, err := decodeSecret(key.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	params := key.Params.WithDefaults()
+	var algorithm uint64
+	switch params.Algorithm {
+	case totp.AlgorithmSHA1:
+		algorithm = googleAlgorithmSHA1
+	case totp.AlgorithmSHA256:
+		algorithm = googleAlgorithmSHA256
+	case totp.AlgorithmSHA512:
+		algorithm = googleAlgorithmSHA512
+	default:
+		return nil, fmt.Errorf("%s: unsupported algorithm %s", key.Label(), params.Algorithm)
+	}
+	digits := uint64(googleDigitsSix)
+	if params.Digits == 8 {
+		digits = googleDigitsEight
+	}
+
+	var b []byte
+	b = appendProtoBytes(b, 1, secret)
+	b = appendProtoBytes(b, 2, []byte(key.AccountName))
+	if key.Issuer != "" {
+		b = appendProtoBytes(b, 3, []byte(key.Issuer))
+	}
+	b = appendProtoVarint(b, 4, algorithm)
+	b = appendProtoVarint(b, 5, digits)
+	if key.Type == totp.TypeHOTP {
+		b = appendProtoVarint(b, 6, googleTypeHOTP)
+		b = appendProtoVarint(b, 7, key.Counter)
+	} else {
+		b = appendProtoVarint(b, 6, googleTypeTOTP)
+	}
+	return b, nil
+}
+
+// decodeSecret decodes a base32 secret, ignoring case, spaces and padding.
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.TrimRight(strings.ToUpper(strings.ReplaceAll(secret, " ", "")), "=")
+	return base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+}
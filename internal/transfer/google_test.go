@@ -0,0 +1,193 @@
+package transfer
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/bykclk/twocli/internal/totp"
+)
+
+// A three-account export, as found in public Google Authenticator examples
+const googleExample = "otpauth-migration://offline?data=CjEKCkhlbGxvId6tvu8SGFRlc3QxOnRlc3QxQGV4YW1wbGUxLmNvbRoFVGVzdDEgASgBMAIKMQoKSGVsbG8h3q2%2B8BIYVGVzdDI6dGVzdDJAZXhhbXBsZTIuY29tGgVUZXN0MiABKAEwAgoxCgpIZWxsbyHerb7xEhhUZXN0Mzp0ZXN0M0BleGFtcGxlMy5jb20aBVRlc3QzIAEoATACEAEYASAAKI3orYEE"
+
+func migrationURI(payload []byte) string {
+	return "otpauth-migration://offline?data=" + url.QueryEscape(base64.StdEncoding.EncodeToString(payload))
+}
+
+func TestParseMigrationURI(t *testing.T) {
+	batch, err := ParseMigrationURI(googleExample)
+	if err != nil {
+		t.Fatalf("Failed to parse migration URI: %v", err)
+	}
+
+	want := []totp.Key{
+		{Type: "totp", Issuer: "Test1", AccountName: "test1@example1.com", Secret: "JBSWY3DPEHPK3PXP", Params: totp.DefaultParams()},
+		{Type: "totp", Issuer: "Test2", AccountName: "test2@example2.com", Secret: "JBSWY3DPEHPK3PXQ", Params: totp.DefaultParams()},
+		{Type: "totp", Issuer: "Test3", AccountName: "test3@example3.com", Secret: "JBSWY3DPEHPK3PXR", Params: totp.DefaultParams()},
+	}
+	if len(batch.Keys) != len(want) {
+		t.Fatalf("Expected %d keys, got %d", len(want), len(batch.Keys))
+	}
+	for i, w := range want {
+		if *batch.Keys[i] != w {
+			t.Errorf("Key %d = %+v, want %+v", i, *batch.Keys[i], w)
+		}
+	}
+	if batch.Version != 1 || batch.Size != 1 || batch.Index != 0 {
+		t.Errorf("Unexpected batch version %d, size %d, index %d", batch.Version, batch.Size, batch.Index)
+	}
+}
+
+func TestParseMigrationURIParameters(t *testing.T) {
+	secret := []byte("12345678901234567890")
+
+	var hotp []byte
+	hotp = appendProtoBytes(hotp, 1, secret)
+	hotp = appendProtoBytes(hotp, 2, []byte("alice@example.com"))
+	hotp = appendProtoBytes(hotp, 3, []byte("VPN"))
+	hotp = appendProtoVarint(hotp, 4, googleAlgorithmSHA256)
+	hotp = appendProtoVarint(hotp, 5, googleDigitsEight)
+	hotp = appendProtoVarint(hotp, 6, googleTypeHOTP)
+	hotp = appendProtoVarint(hotp, 7, 42)
+
+	var md5 []byte
+	md5 = appendProtoBytes(md5, 1, secret)
+	md5 = appendProtoBytes(md5, 2, []byte("Legacy:bob"))
+	md5 = appendProtoVarint(md5, 4, googleAlgorithmMD5)
+
+	var payload []byte
+	payload = appendProtoBytes(payload, 1, hotp)
+	payload = appendProtoBytes(payload, 1, md5)
+	payload = appendProtoVarint(payload, 2, 1)
+	payload = appendProtoVarint(payload, 3, 2)
+	payload = appendProtoVarint(payload, 4, 1)
+	payload = appendProtoVarint(payload, 5, 7)
+	// Unknown fields are ignored
+	payload = appendProtoBytes(payload, 9, []byte("future"))
+
+	batch, err := ParseMigrationURI(migrationURI(payload))
+	if err != nil {
+		t.Fatalf("Failed to parse migration URI: %v", err)
+	}
+
+	want := &MigrationBatch{
+		Keys: []*totp.Key{{
+			Type:        totp.TypeHOTP,
+			Issuer:      "VPN",
+			AccountName: "alice@example.com",
+			Secret:      "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+			Params:      totp.Params{Algorithm: totp.AlgorithmSHA256, Digits: 8, Period: 30},
+			Counter:     42,
+		}},
+		Skipped: []string{"Legacy:bob: MD5 codes are not supported"},
+		Version: 1,
+		Size:    2,
+		Index:   1,
+		ID:      7,
+	}
+	if !reflect.DeepEqual(batch, want) {
+		t.Errorf("ParseMigrationURI = %+v, want %+v", batch, want)
+	}
+
+	if missing := MissingBatches([]*MigrationBatch{batch}); !reflect.DeepEqual(missing, []string{"batch 1 of 2"}) {
+		t.Errorf("MissingBatches = %v, want [batch 1 of 2]", missing)
+	}
+
+	// Many missing batches are summed up
+	large := &MigrationBatch{Size: 40, Index: 3, ID: 8}
+	if missing := MissingBatches([]*MigrationBatch{large}); !reflect.DeepEqual(missing, []string{"39 batches of 40"}) {
+		t.Errorf("MissingBatches = %v, want [39 batches of 40]", missing)
+	}
+}
+
+func TestParseMigrationURIInvalid(t *testing.T) {
+	if _, err := ParseMigrationURI("otpauth://totp/GitHub?secret=JBSWY3DPEHPK3PXP"); !errors.Is(err, ErrNotMigrationURI) {
+		t.Errorf("Expected ErrNotMigrationURI, got %v", err)
+	}
+
+	var tooManyBatches, outOfRange []byte
+	tooManyBatches = appendProtoVarint(tooManyBatches, 3, 1<<31-1)
+	outOfRange = appendProtoVarint(outOfRange, 3, 2)
+	outOfRange = appendProtoVarint(outOfRange, 4, 2)
+
+	for _, uri := range []string{
+		"otpauth-migration://offline",
+		"otpauth-migration://offline?data=not*base64",
+		migrationURI([]byte{0x0a, 0x05, 0x0a}),
+		migrationURI(tooManyBatches),
+		migrationURI(outOfRange),
+	} {
+		if _, err := ParseMigrationURI(uri); err == nil {
+			t.Errorf("Expected %s to fail", uri)
+		}
+	}
+}
+
+func TestMigrationURIs(t *testing.T) {
+	keys := []*totp.Key{
+		{Type: totp.TypeTOTP, Issuer: "GitHub", AccountName: "alice", Secret: "JBSWY3DPEHPK3PXP", Params: totp.DefaultParams()},
+		{Type: totp.TypeHOTP, Issuer: "VPN", AccountName: "alice", Secret: "GEZDGNBVGY3TQOJQ", Params: totp.Params{Algorithm: totp.AlgorithmSHA512, Digits: 8, Period: 30}, Counter: 7},
+		{Type: totp.TypeTOTP, AccountName: "bob@example.com", Secret: "jbsw y3dp ehpk 3pxp", Params: totp.Params{Algorithm: totp.AlgorithmSHA256, Digits: 6, Period: 30}},
+	}
+
+	uris, err := MigrationURIs(keys, 2)
+	if err != nil {
+		t.Fatalf("Failed to build migration URIs: %v", err)
+	}
+	if len(uris) != 2 {
+		t.Fatalf("Expected 2 batches, got %d", len(uris))
+	}
+
+	var batches []*MigrationBatch
+	var parsed []*totp.Key
+	for i, uri := range uris {
+		batch, err := ParseMigrationURI(uri)
+		if err != nil {
+			t.Fatalf("Failed to parse migration URI: %v", err)
+		}
+		if batch.Size != 2 || batch.Index != i || batch.Version != 1 {
+			t.Errorf("Batch %d has size %d, index %d, version %d", i, batch.Size, batch.Index, batch.Version)
+		}
+		if i > 0 && batch.ID != batches[0].ID {
+			t.Errorf("Expected the batches to share an ID")
+		}
+		batches = append(batches, batch)
+		parsed = append(parsed, batch.Keys...)
+	}
+	if missing := MissingBatches(batches); len(missing) != 0 {
+		t.Errorf("Expected no missing batches, got %v", missing)
+	}
+
+	keys[2].Secret = "JBSWY3DPEHPK3PXP"
+	if len(parsed) != len(keys) {
+		t.Fatalf("Expected %d keys, got %d", len(keys), len(parsed))
+	}
+	for i, key := range keys {
+		if *parsed[i] != *key {
+			t.Errorf("Key %d = %+v, want %+v", i, *parsed[i], *key)
+		}
+	}
+}
+
+func TestMigrationURIsUnsupported(t *testing.T) {
+	tests := []struct {
+		key  *totp.Key
+		want string
+	}{
+		{&totp.Key{Secret: "JBSWY3DPEHPK3PXP", Params: totp.Params{Digits: 7}}, "7-digit codes are not supported"},
+		{&totp.Key{Secret: "JBSWY3DPEHPK3PXP", Params: totp.Params{Period: 60}}, "a 60-second period is not supported"},
+		{&totp.Key{Type: totp.TypeHOTP, Secret: "JBSWY3DPEHPK3PXP", Params: totp.Params{Period: 60}}, ""},
+	}
+	for _, tt := range tests {
+		if got := GoogleUnsupported(tt.key); got != tt.want {
+			t.Errorf("GoogleUnsupported(%+v) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+
+	if _, err := MigrationURIs([]*totp.Key{tests[0].key}, 10); err == nil {
+		t.Errorf("Expected an unsupported key to fail")
+	}
+}
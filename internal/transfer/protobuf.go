@@ -0,0 +1,91 @@
+package transfer
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Protocol buffer wire types. Only the varint and length-delimited types are
+// used by the messages twocli reads, the others are skipped.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("truncated protocol buffer")
+
+// protoField is a field of a protocol buffer message: its number, wire type
+// and value, which is in Varint for varint fields and Bytes for
+// length-delimited ones.
+type protoField struct {
+	Number   int
+	WireType int
+	Varint   uint64
+	Bytes    []byte
+}
+
+// readProto calls fn with every field of a protocol buffer message in the
+// order they appear, so messages can be decoded without generated code.
+func readProto(data []byte, fn func(f protoField) error) error {
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errTruncated
+		}
+		data = data[n:]
+
+		f := protoField{Number: int(tag >> 3), WireType: int(tag & 7)}
+		if f.Number == 0 {
+			return errors.New("invalid protocol buffer field number 0")
+		}
+
+		switch f.WireType {
+		case wireVarint:
+			if f.Varint, n = binary.Uvarint(data); n <= 0 {
+				return errTruncated
+			}
+			data = data[n:]
+		case wireBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || size > uint64(len(data)-n) {
+				return errTruncated
+			}
+			f.Bytes = data[n : n+int(size)]
+			data = data[n+int(size):]
+		case wireFixed64, wireFixed32:
+			size := 8
+			if f.WireType == wireFixed32 {
+				size = 4
+			}
+			if len(data) < size {
+				return errTruncated
+			}
+			f.Bytes = data[:size]
+			data = data[size:]
+		default:
+			return fmt.Errorf("unsupported protocol buffer wire type %d", f.WireType)
+		}
+
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendProtoVarint appends a varint field to a protocol buffer message.
+func appendProtoVarint(b []byte, number int, value uint64) []byte {
+	b = binary.AppendUvarint(b, uint64(number)<<3|wireVarint)
+	return binary.AppendUvarint(b, value)
+}
+
+// appendProtoBytes appends a length-delimited field, such as a string or an
+// embedded message, to a protocol buffer message.
+func appendProtoBytes(b []byte, number int, value []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(number)<<3|wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(value)))
+	return append(b, value...)
+}
@@ -0,0 +1,198 @@
+// Package transfer reads and writes files for moving accounts into and out
+// of twocli.
+package transfer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bykclk/twocli/internal/crypto"
+	"github.com/bykclk/twocli/internal/storage"
+	"github.com/bykclk/twocli/internal/totp"
+)
+
+// The twocli export format is a JSON document listing every account with all
+// of its fields, including its secret and notes:
+//
+//	{
+//	  "format": "twocli-export",
+//	  "version": 1,
+//	  "exported_at": "2024-05-01T12:00:00Z",
+//	  "accounts": [
+//	    {"name": "GitHub", "secret": "JBSWY3DPEHPK3PXP", "type": "totp", ...}
+//	  ]
+//	}
+//
+// An encrypted export is the same document sealed like a vault: a header
+// recording the Argon2id parameters used to derive a key from the export
+// passphrase, followed by the document encrypted with AES-256-GCM.
+const (
+	FormatName    = "twocli-export"
+	FormatVersion = 1
+)
+
+var (
+	// ErrNotTwocliExport is returned when reading a file that is not a twocli export.
+	ErrNotTwocliExport = errors.New("not a twocli export file")
+
+	// ErrIncorrectPassphrase is returned when an encrypted file cannot be
+	// decrypted with the passphrase.
+	ErrIncorrectPassphrase = errors.New("incorrect passphrase or corrupted file")
+
+	// ErrNewerExport is returned when reading an export written by a newer version of twocli.
+	ErrNewerExport = errors.New("export was written by a newer version of twocli, upgrade twocli to import it")
+)
+
+// Export is a twocli export document.
+type Export struct {
+	Format     string          `json:"format"`
+	Version    int             `json:"version"`
+	ExportedAt time.Time       `json:"exported_at"`
+	Accounts   []ExportAccount `json:"accounts"`
+}
+
+// ExportAccount is an account in a twocli export. Type, algorithm, digits
+// and period are always written out, with defaults filled in.
+type ExportAccount struct {
+	Name       string    `json:"name"`
+	Secret     string    `json:"secret"`
+	Type       string    `json:"type"`
+	Algorithm  string    `json:"algorithm"`
+	Digits     int       `json:"digits"`
+	Period     int       `json:"period"`
+	Counter    uint64    `json:"counter,omitempty"`
+	LastStep   uint64    `json:"last_step,omitempty"`
+	Issuer     string    `json:"issuer,omitempty"`
+	Label      string    `json:"label,omitempty"`
+	Tags       []string  `json:"tags,omitempty"`
+	Aliases    []string  `json:"aliases,omitempty"`
+	Notes      string    `json:"notes,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+// MarshalTwocli encodes the accounts as a twocli export document.
+func MarshalTwocli(accounts []storage.Account) ([]byte, error) {
+	export := Export{
+		Format:     FormatName,
+		Version:    FormatVersion,
+		ExportedAt: time.Now().UTC(),
+		Accounts:   make([]ExportAccount, len(accounts)),
+	}
+	for i, acc := range accounts {
+		params := acc.Params()
+		otpType := acc.Type
+		if otpType == "" {
+			otpType = totp.TypeTOTP
+		}
+		export.Accounts[i] = ExportAccount{
+			Name:       acc.Name,
+			Secret:     acc.Secret,
+			Type:       otpType,
+			Algorithm:  params.Algorithm,
+			Digits:     params.Digits,
+			Period:     params.Period,
+			Counter:    acc.Counter,
+			LastStep:   acc.LastStep,
+			Issuer:     acc.Issuer,
+			Label:      acc.Label,
+			Tags:       acc.Tags,
+			Aliases:    acc.Aliases,
+			Notes:      acc.Notes,
+			CreatedAt:  acc.CreatedAt,
+			UpdatedAt:  acc.UpdatedAt,
+			LastUsedAt: acc.LastUsedAt,
+		}
+	}
+	return json.MarshalIndent(export, "", "  ")
+}
+
+// EncryptTwocli encrypts an export document under a key derived from the
+// passphrase with the given parameters.
+func EncryptTwocli(data []byte, passphrase string, kdf crypto.KDFParams) ([]byte, error) {
+	key, err := crypto.NewVaultKey(passphrase, kdf)
+	if err != nil {
+		return nil, err
+	}
+	return key.Seal(data)
+}
+
+// IsEncrypted reports whether a twocli export is encrypted, and needs a
+// passphrase to be read.
+func IsEncrypted(data []byte) bool {
+	_, ok, err := crypto.ReadVaultKDF(data)
+	return ok && err == nil
+}
+
+// ReadTwocli decodes a twocli export, decrypting it with the passphrase if it
+// is encrypted.
+func ReadTwocli(data []byte, passphrase string) ([]storage.Account, error) {
+	if IsEncrypted(data) {
+		kdf, _, err := crypto.ReadVaultKDF(data)
+		if err != nil {
+			return nil, err
+		}
+		// The header comes from the file, so its cost is bounded before deriving
+		if err := kdf.ValidateUntrusted(); err != nil {
+			return nil, fmt.Errorf("invalid export key derivation: %v", err)
+		}
+		if data, _, err = crypto.OpenVault(data, passphrase); err != nil {
+			if errors.Is(err, crypto.ErrUnsupportedVault) {
+				return nil, ErrNewerExport
+			}
+			return nil, ErrIncorrectPassphrase
+		}
+	}
+
+	var export Export
+	if err := json.Unmarshal(data, &export); err != nil || export.Format != FormatName {
+		return nil, ErrNotTwocliExport
+	}
+	if export.Version > FormatVersion {
+		return nil, ErrNewerExport
+	}
+	if export.Version < 1 {
+		return nil, fmt.Errorf("invalid export version %d", export.Version)
+	}
+
+	accounts := make([]storage.Account, len(export.Accounts))
+	for i, e := range export.Accounts {
+		acc := storage.Account{
+			Name:       e.Name,
+			Secret:     e.Secret,
+			Counter:    e.Counter,
+			LastStep:   e.LastStep,
+			Issuer:     e.Issuer,
+			Label:      e.Label,
+			Tags:       e.Tags,
+			Aliases:    e.Aliases,
+			Notes:      e.Notes,
+			CreatedAt:  e.CreatedAt,
+			UpdatedAt:  e.UpdatedAt,
+			LastUsedAt: e.LastUsedAt,
+		}
+		switch e.Type {
+		case "", totp.TypeTOTP:
+		case totp.TypeHOTP:
+			acc.Type = totp.TypeHOTP
+		default:
+			return nil, fmt.Errorf("account '%s' has an unknown type: %s", e.Name, e.Type)
+		}
+
+		// Exports may be edited by hand, so parameters are read like add's flags
+		params := totp.Params{
+			Algorithm: totp.NormalizeAlgorithm(e.Algorithm),
+			Digits:    e.Digits,
+			Period:    e.Period,
+		}.WithDefaults()
+		if err := params.Validate(); err != nil {
+			return nil, fmt.Errorf("account '%s' has invalid code parameters: %v", e.Name, err)
+		}
+		acc.SetParams(params)
+		accounts[i] = acc
+	}
+	return accounts, nil
+}
@@ -0,0 +1,153 @@
+package transfer
+
+import (
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bykclk/twocli/internal/crypto"
+	"github.com/bykclk/twocli/internal/storage"
+)
+
+var testKDF = crypto.KDFParams{Algorithm: crypto.KDFArgon2id, Time: 1, Memory: 8 * 1024, Threads: 1}
+
+func testAccounts() []storage.Account {
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	return []storage.Account{
+		{
+			Name:      "GitHub",
+			Secret:    "JBSWY3DPEHPK3PXP",
+			Algorithm: "SHA1",
+			Digits:    6,
+			Period:    30,
+			Issuer:    "GitHub",
+			Label:     "alice@example.com",
+			Tags:      []string{"work"},
+			Aliases:   []string{"gh"},
+			Notes:     "recovery codes in the safe",
+			CreatedAt: created,
+			UpdatedAt: created,
+		},
+		{
+			Name:      "VPN",
+			Secret:    "GEZDGNBVGY3TQOJQ",
+			Type:      "hotp",
+			Algorithm: "SHA256",
+			Digits:    8,
+			Period:    30,
+			Counter:   42,
+			CreatedAt: created,
+			UpdatedAt: created,
+		},
+	}
+}
+
+func TestTwocliRoundTrip(t *testing.T) {
+	data, err := MarshalTwocli(testAccounts())
+	if err != nil {
+		t.Fatalf("Failed to marshal export: %v", err)
+	}
+	if !strings.Contains(string(data), `"format": "twocli-export"`) {
+		t.Errorf("Expected the export to name its format, got %s", data)
+	}
+	if IsEncrypted(data) {
+		t.Errorf("Expected a plaintext export not to be reported as encrypted")
+	}
+
+	accounts, err := ReadTwocli(data, "")
+	if err != nil {
+		t.Fatalf("Failed to read export: %v", err)
+	}
+	if !reflect.DeepEqual(accounts, testAccounts()) {
+		t.Errorf("ReadTwocli = %+v, want %+v", accounts, testAccounts())
+	}
+}
+
+func TestTwocliEncrypted(t *testing.T) {
+	data, err := MarshalTwocli(testAccounts())
+	if err != nil {
+		t.Fatalf("Failed to marshal export: %v", err)
+	}
+	encrypted, err := EncryptTwocli(data, "export passphrase", testKDF)
+	if err != nil {
+		t.Fatalf("Failed to encrypt export: %v", err)
+	}
+	if !IsEncrypted(encrypted) {
+		t.Fatalf("Expected an encrypted export to be reported as encrypted")
+	}
+	if strings.Contains(string(encrypted), "JBSWY3DPEHPK3PXP") {
+		t.Errorf("Expected the encrypted export not to contain the secret")
+	}
+
+	if _, err = ReadTwocli(encrypted, "wrong passphrase"); !errors.Is(err, ErrIncorrectPassphrase) {
+		t.Errorf("Expected ErrIncorrectPassphrase, got %v", err)
+	}
+
+	accounts, err := ReadTwocli(encrypted, "export passphrase")
+	if err != nil {
+		t.Fatalf("Failed to read export: %v", err)
+	}
+	if !reflect.DeepEqual(accounts, testAccounts()) {
+		t.Errorf("ReadTwocli = %+v, want %+v", accounts, testAccounts())
+	}
+}
+
+func TestReadTwocliCostlyKDF(t *testing.T) {
+	data, err := MarshalTwocli(testAccounts())
+	if err != nil {
+		t.Fatalf("Failed to marshal export: %v", err)
+	}
+	encrypted, err := EncryptTwocli(data, "export passphrase", testKDF)
+	if err != nil {
+		t.Fatalf("Failed to encrypt export: %v", err)
+	}
+
+	// Argon2id time in the header, after the magic, version and kdf id
+	binary.BigEndian.PutUint32(encrypted[6:], 1<<20)
+	if _, err = ReadTwocli(encrypted, "export passphrase"); err == nil || errors.Is(err, ErrIncorrectPassphrase) {
+		t.Errorf("Expected a key derivation cost error, got %v", err)
+	}
+}
+
+func TestReadTwocliInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want error
+	}{
+		{"not JSON", "otpauth://totp/GitHub?secret=JBSWY3DPEHPK3PXP", ErrNotTwocliExport},
+		{"other format", `{"format": "aegis", "version": 1}`, ErrNotTwocliExport},
+		{"newer version", `{"format": "twocli-export", "version": 2}`, ErrNewerExport},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadTwocli([]byte(tt.data), ""); !errors.Is(err, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+
+	data := `{"format": "twocli-export", "version": 1, "accounts": [{"name": "X", "secret": "JBSWY3DPEHPK3PXP", "type": "motp"}]}`
+	if _, err := ReadTwocli([]byte(data), ""); err == nil {
+		t.Errorf("Expected an unknown account type to fail")
+	}
+
+	data = `{"format": "twocli-export", "version": 1, "accounts": [{"name": "X", "secret": "JBSWY3DPEHPK3PXP", "digits": 12}]}`
+	if _, err := ReadTwocli([]byte(data), ""); err == nil {
+		t.Errorf("Expected invalid code parameters to fail")
+	}
+}
+
+func TestReadTwocliNormalizesParams(t *testing.T) {
+	data := `{"format": "twocli-export", "version": 1, "accounts": [{"name": "X", "secret": "JBSWY3DPEHPK3PXP", "algorithm": "sha-256"}]}`
+	accounts, err := ReadTwocli([]byte(data), "")
+	if err != nil {
+		t.Fatalf("Failed to read export: %v", err)
+	}
+	if acc := accounts[0]; acc.Algorithm != "SHA256" || acc.Digits != 6 || acc.Period != 30 {
+		t.Errorf("Expected normalized parameters SHA256, 6, 30, got %s, %d, %d", acc.Algorithm, acc.Digits, acc.Period)
+	}
+}
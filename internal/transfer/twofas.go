@@ -0,0 +1,189 @@
+package transfer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bykclk/twocli/internal/crypto"
+	"github.com/bykclk/twocli/internal/totp"
+)
+
+// 2FAS backups (.2fas files) are JSON documents listing services, each with
+// its secret and OTP parameters, and the groups they belong to:
+//
+//	{
+//	  "schemaVersion": 4,
+//	  "services": [{"name": "GitHub", "secret": "...", "otp": {...}, "groupId": "..."}],
+//	  "groups": [{"id": "...", "name": "Work"}]
+//	}
+//
+// In an encrypted backup, services is empty and servicesEncrypted holds the
+// services encrypted with AES-256-GCM, as "ciphertext:salt:iv" in base64,
+// under a key derived from the password with PBKDF2-SHA256.
+const (
+	twoFASMaxSchemaVersion = 4
+	twoFASIterations       = 10000
+)
+
+type twoFASBackup struct {
+	SchemaVersion     int             `json:"schemaVersion"`
+	Services          []twoFASService `json:"services"`
+	ServicesEncrypted string          `json:"servicesEncrypted"`
+	Groups            []twoFASGroup   `json:"groups"`
+}
+
+type twoFASGroup struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type twoFASService struct {
+	Name    string    `json:"name"`
+	Secret  string    `json:"secret"`
+	OTP     twoFASOTP `json:"otp"`
+	GroupID string    `json:"groupId"`
+}
+
+type twoFASOTP struct {
+	Label     string `json:"label"`
+	Account   string `json:"account"`
+	Issuer    string `json:"issuer"`
+	Digits    int    `json:"digits"`
+	Period    int    `json:"period"`
+	Algorithm string `json:"algorithm"`
+	Counter   uint64 `json:"counter"`
+	TokenType string `json:"tokenType"`
+}
+
+// ErrNot2FASBackup is returned when reading a file that is not a 2FAS backup.
+var ErrNot2FASBackup = errors.New("not a 2FAS backup")
+
+// read2FASFile decodes the outer structure of a 2FAS backup.
+func read2FASFile(data []byte) (twoFASBackup, error) {
+	var b twoFASBackup
+	if err := json.Unmarshal(data, &b); err != nil || b.SchemaVersion == 0 {
+		return twoFASBackup{}, ErrNot2FASBackup
+	}
+	if b.SchemaVersion > twoFASMaxSchemaVersion {
+		return twoFASBackup{}, fmt.Errorf("unsupported 2FAS backup schema version %d", b.SchemaVersion)
+	}
+	return b, nil
+}
+
+// Is2FASEncrypted reports whether a 2FAS backup is encrypted, and needs a
+// password to be read.
+func Is2FASEncrypted(data []byte) bool {
+	b, err := read2FASFile(data)
+	return err == nil && b.ServicesEncrypted != ""
+}
+
+// Read2FAS decodes a 2FAS backup, decrypting it with the password if it is
+// encrypted. Services of types twocli does not support, such as Steam, are
+// skipped.
+func Read2FAS(data []byte, password string) (*Backup, error) {
+	b, err := read2FASFile(data)
+	if err != nil {
+		return nil, err
+	}
+
+	services := b.Services
+	if b.ServicesEncrypted != "" {
+		plaintext, err := decrypt2FAS(b.ServicesEncrypted, password)
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(plaintext, &services); err != nil {
+			return nil, fmt.Errorf("invalid 2FAS services: %v", err)
+		}
+	}
+
+	groups := map[string]string{}
+	for _, g := range b.Groups {
+		groups[g.ID] = g.Name
+	}
+
+	backup := &Backup{}
+	for _, s := range services {
+		key, err := twoFASServiceToKey(s)
+		if err != nil {
+			backup.Skipped = append(backup.Skipped, err.Error())
+			continue
+		}
+		entry := Entry{Key: key}
+		if name, ok := groups[s.GroupID]; ok {
+			entry.Tags = []string{name}
+		}
+		backup.Entries = append(backup.Entries, entry)
+	}
+	return backup, nil
+}
+
+// decrypt2FAS decrypts the services of an encrypted 2FAS backup.
+func decrypt2FAS(encrypted, password string) ([]byte, error) {
+	parts := strings.Split(encrypted, ":")
+	if len(parts) != 3 {
+		return nil, errors.New("invalid 2FAS encrypted services")
+	}
+	var decoded [3][]byte
+	for i, part := range parts {
+		var err error
+		if decoded[i], err = base64.StdEncoding.DecodeString(part); err != nil {
+			return nil, fmt.Errorf("invalid 2FAS encrypted services: %v", err)
+		}
+	}
+	ciphertext, salt, iv := decoded[0], decoded[1], decoded[2]
+
+	key, err := crypto.PBKDF2Key(password, salt, twoFASIterations)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := crypto.OpenGCM(key, iv, ciphertext)
+	if err != nil {
+		return nil, ErrIncorrectPassphrase
+	}
+	return plaintext, nil
+}
+
+// twoFASServiceToKey converts a 2FAS service, without its group.
+func twoFASServiceToKey(s twoFASService) (*totp.Key, error) {
+	key := &totp.Key{
+		Issuer:      strings.TrimSpace(s.OTP.Issuer),
+		AccountName: strings.TrimSpace(s.OTP.Account),
+		Secret:      strings.TrimRight(strings.ToUpper(strings.ReplaceAll(s.Secret, " ", "")), "="),
+		Params: totp.Params{
+			Algorithm: totp.NormalizeAlgorithm(s.OTP.Algorithm),
+			Digits:    s.OTP.Digits,
+			Period:    s.OTP.Period,
+		},
+	}
+	if key.Issuer == "" {
+		key.Issuer = strings.TrimSpace(s.Name)
+	}
+	if key.AccountName == "" {
+		key.AccountName = strings.TrimSpace(s.OTP.Label)
+	}
+	label := key.Label()
+
+	switch strings.ToUpper(s.OTP.TokenType) {
+	case "", "TOTP":
+		key.Type = totp.TypeTOTP
+	case "HOTP":
+		key.Type = totp.TypeHOTP
+		key.Counter = s.OTP.Counter
+		key.Params.Period = 0
+	default:
+		return nil, fmt.Errorf("%s: %s tokens are not supported", label, s.OTP.TokenType)
+	}
+
+	if err := totp.ValidateSecret(key.Secret); err != nil {
+		return nil, fmt.Errorf("%s: invalid secret: %v", label, err)
+	}
+	key.Params = key.Params.WithDefaults()
+	if err := key.Params.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", label, err)
+	}
+	return key, nil
+}
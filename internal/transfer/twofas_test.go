@@ -0,0 +1,30 @@
+package transfer
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestRead2FAS(t *testing.T) {
+	files := []backupFile{
+		{"testdata/2fas_plain.2fas", false, ""},
+		{"testdata/2fas_encrypted.2fas", true, "test"},
+	}
+	testReadBackups(t, files, Is2FASEncrypted, Read2FAS,
+		testEntries(), []string{"Steam:gamer: STEAM tokens are not supported"})
+}
+
+func TestRead2FASInvalid(t *testing.T) {
+	data, err := os.ReadFile("testdata/2fas_encrypted.2fas")
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	if _, err = Read2FAS(data, "wrong"); !errors.Is(err, ErrIncorrectPassphrase) {
+		t.Errorf("Expected ErrIncorrectPassphrase, got %v", err)
+	}
+
+	if _, err = Read2FAS([]byte(`{"version": 1, "db": {}}`), ""); !errors.Is(err, ErrNot2FASBackup) {
+		t.Errorf("Expected ErrNot2FASBackup, got %v", err)
+	}
+}